@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/example/goframe/db"
+)
+
+// AccessTokenPrefix marks a bearer token as a personal access token rather than a JWT
+const AccessTokenPrefix = "gfp_"
+
+// ErrAccessTokenNotFound is returned when revoking a token the user does not own
+var ErrAccessTokenNotFound = errors.New("access token not found")
+
+type accessTokenKey struct{}
+type scopesKey struct{}
+
+// PersonalAccessToken is a long-lived API credential for scripts and CI jobs.
+// Only a SHA-256 hash of the token is stored.
+type PersonalAccessToken struct {
+	db.Entity
+	UserID     uint       `db:"user_id" json:"user_id"`
+	Name       string     `db:"name" json:"name"`
+	TokenHash  string     `db:"token_hash" json:"-"`
+	Abilities  string     `db:"abilities" json:"-"` // JSON array of scopes
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// TableName returns the table name for the model
+func (PersonalAccessToken) TableName() string {
+	return "personal_access_tokens"
+}
+
+// Scopes returns the abilities granted to the token
+func (t *PersonalAccessToken) Scopes() []string {
+	var scopes []string
+	json.Unmarshal([]byte(t.Abilities), &scopes)
+	return scopes
+}
+
+// Expired reports whether the token has passed its expiry at now
+func (t *PersonalAccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && now.After(*t.ExpiresAt)
+}
+
+// MarshalJSON includes the decoded scopes in the JSON form of the token
+func (t PersonalAccessToken) MarshalJSON() ([]byte, error) {
+	type token PersonalAccessToken
+	return json.Marshal(struct {
+		token
+		Abilities []string `json:"abilities"`
+	}{token(t), t.Scopes()})
+}
+
+// PersonalAccessTokenRepository provides methods to interact with access tokens
+type PersonalAccessTokenRepository struct {
+	db   *db.Database
+	repo *db.Repository[PersonalAccessToken]
+}
+
+// NewPersonalAccessTokenRepository creates a new access token repository
+func NewPersonalAccessTokenRepository(database *db.Database) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{
+		db:   database,
+		repo: db.NewRepository[PersonalAccessToken](database),
+	}
+}
+
+// Create stores a new token and returns it together with its plain-text value,
+// which is never retrievable again
+func (r *PersonalAccessTokenRepository) Create(ctx context.Context, userID uint, name string, abilities []string, expiresAt *time.Time) (*PersonalAccessToken, string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	plain := AccessTokenPrefix + secret
+
+	if len(abilities) == 0 {
+		abilities = []string{"*"}
+	}
+	encoded, err := json.Marshal(abilities)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(plain),
+		Abilities: string(encoded),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := r.repo.CreateContext(ctx, token); err != nil {
+		return nil, "", err
+	}
+
+	return token, plain, nil
+}
+
+// FindByToken finds a token by its plain-text value
+func (r *PersonalAccessTokenRepository) FindByToken(ctx context.Context, plain string) (*PersonalAccessToken, error) {
+	var token PersonalAccessToken
+	if err := r.repo.FindByStringContext(ctx, "token_hash", &token, hashToken(plain)); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ForUser lists the tokens belonging to a user
+func (r *PersonalAccessTokenRepository) ForUser(ctx context.Context, userID uint) ([]PersonalAccessToken, error) {
+	var tokens []PersonalAccessToken
+	if err := r.repo.FindAllContext(ctx, &tokens, "user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Delete revokes one of the user's tokens. It returns ErrAccessTokenNotFound
+// when the user has no token with that ID.
+func (r *PersonalAccessTokenRepository) Delete(ctx context.Context, userID, id uint) error {
+	deleted, err := db.NewQueryBuilder(r.db, PersonalAccessToken{}.TableName()).
+		WithContext(ctx).
+		Where("id", "=", id).
+		Where("user_id", "=", userID).
+		DeleteCount()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+// Touch records that the token was used at now. Writes are skipped when the
+// stored timestamp is recent, so busy tokens do not cause a write per request.
+func (r *PersonalAccessTokenRepository) Touch(ctx context.Context, token *PersonalAccessToken, now time.Time) error {
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < time.Minute {
+		return nil
+	}
+	token.LastUsedAt = &now
+
+	return db.NewQueryBuilder(r.db, token.TableName()).
+		WithContext(ctx).
+		Where("id", "=", token.ID).
+		Update(map[string]interface{}{"last_used_at": now})
+}
+
+// CreateAccessToken issues a personal access token for the user
+func (p *Provider) CreateAccessToken(ctx context.Context, user *UserModel, name string, abilities []string, expiresAt *time.Time) (*PersonalAccessToken, string, error) {
+	return p.accessTokens.Create(ctx, user.ID, name, abilities, expiresAt)
+}
+
+// AccessTokens lists the user's personal access tokens
+func (p *Provider) AccessTokens(ctx context.Context, user *UserModel) ([]PersonalAccessToken, error) {
+	return p.accessTokens.ForUser(ctx, user.ID)
+}
+
+// RevokeAccessToken deletes one of the user's personal access tokens. It
+// returns ErrAccessTokenNotFound when the user has no token with that ID.
+func (p *Provider) RevokeAccessToken(ctx context.Context, user *UserModel, id uint) error {
+	return p.accessTokens.Delete(ctx, user.ID, id)
+}
+
+// CurrentAccessToken returns the personal access token used for the request, if any
+func CurrentAccessToken(ctx context.Context) *PersonalAccessToken {
+	token, ok := ctx.Value(accessTokenKey{}).(*PersonalAccessToken)
+	if !ok {
+		return nil
+	}
+	return token
+}
+
+// Scopes returns the abilities granted to the request's credentials. Requests
+// authenticated with a JWT carry the full "*" scope.
+func Scopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey{}).([]string)
+	return scopes
+}
+
+// TokenCan reports whether the request's credentials grant ability. Scopes
+// may use "*" for everything or "posts:*" for every ability with that prefix.
+func TokenCan(ctx context.Context, ability string) bool {
+	for _, scope := range Scopes(ctx) {
+		if scope == "*" || scope == ability {
+			return true
+		}
+		if strings.HasSuffix(scope, ":*") && strings.HasPrefix(ability, strings.TrimSuffix(scope, "*")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/example/goframe/db"
+)
+
+// newAccessTokenProvider returns a provider on a migrated in-memory database
+// with one user, whose clock is fixed at now
+func newAccessTokenProvider(t *testing.T, now *time.Time) (*Provider, *UserModel) {
+	t.Helper()
+	database, err := db.NewDatabase(&db.DatabaseConfig{Driver: "sqlite", Name: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if _, err := db.NewMigrator(database).RunMigrations(db.RegisteredMigrations()); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := NewUserRepository(database).Create("Owner", "owner@example.test", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProvider(database, AuthConfig{Secret: "test-secret"})
+	p.SetClock(func() time.Time { return *now })
+	return p, user
+}
+
+func TestRevokeTokenNotFound(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p, user := newAccessTokenProvider(t, &now)
+	c := NewController(p, NewUserRepository(p.db), nil)
+
+	token, _, err := p.CreateAccessToken(context.Background(), user, "ci", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoke := func(id uint) int {
+		r := httptest.NewRequest(http.MethodDelete, "/api/tokens?id="+strconv.FormatUint(uint64(id), 10), nil)
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+		w := httptest.NewRecorder()
+		c.RevokeToken(w, r)
+		return w.Code
+	}
+
+	if code := revoke(token.ID + 1); code != http.StatusNotFound {
+		t.Errorf("unknown token: status %d, want 404", code)
+	}
+	if code := revoke(token.ID); code != http.StatusNoContent {
+		t.Errorf("own token: status %d, want 204", code)
+	}
+	if code := revoke(token.ID); code != http.StatusNotFound {
+		t.Errorf("revoked token: status %d, want 404", code)
+	}
+}
+
+func TestAccessTokenExpiryUsesProviderClock(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p, user := newAccessTokenProvider(t, &now)
+
+	expiresAt := now.Add(time.Hour)
+	_, plain, err := p.CreateAccessToken(context.Background(), user, "ci", nil, &expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.authenticateAccessToken(context.Background(), plain); err != nil {
+		t.Fatalf("token rejected before expiry: %v", err)
+	}
+
+	now = expiresAt.Add(time.Second)
+	if _, err := p.authenticateAccessToken(context.Background(), plain); err == nil {
+		t.Fatal("token accepted after expiry")
+	}
+}
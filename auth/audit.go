@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/example/goframe/db"
+	"github.com/example/goframe/events"
+)
+
+// ViewAuthEventsPermission is the permission needed to read the audit log
+const ViewAuthEventsPermission = "auth_events.view"
+
+// maxUserAgentLength caps the user agent stored with an auth event
+const maxUserAgentLength = 512
+
+// AuthEvent is a row of the auth_events audit log
+type AuthEvent struct {
+	ID             uint      `json:"id" db:"id"`
+	Type           string    `json:"type" db:"type"`
+	UserID         *uint     `json:"user_id" db:"user_id"`
+	ImpersonatorID *uint     `json:"impersonator_id,omitempty" db:"impersonator_id"`
+	Email          string    `json:"email,omitempty" db:"email"`
+	IP             string    `json:"ip,omitempty" db:"ip"`
+	UserAgent      string    `json:"user_agent,omitempty" db:"user_agent"`
+	Details        string    `json:"-" db:"details"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// MarshalJSON includes Details as an object rather than a JSON string
+func (e AuthEvent) MarshalJSON() ([]byte, error) {
+	type event AuthEvent
+	out := struct {
+		event
+		Details json.RawMessage `json:"details,omitempty"`
+	}{event: event(e)}
+	if e.Details != "" && json.Valid([]byte(e.Details)) {
+		out.Details = json.RawMessage(e.Details)
+	}
+	return json.Marshal(out)
+}
+
+// AuditQuery filters the auth events returned by AuditLog.Query. Zero values
+// match everything.
+type AuditQuery struct {
+	UserID         uint
+	ImpersonatorID uint
+	Type           string // e.g. "auth.login_failed"
+	Since          time.Time
+	Until          time.Time
+	Limit          int // Defaults to 50, at most 500
+	Offset         int
+}
+
+// AuditLog records auth events in the auth_events table
+type AuditLog struct {
+	db    *db.Database
+	table string
+}
+
+// NewAuditLog creates a new audit log
+func NewAuditLog(database *db.Database) *AuditLog {
+	return &AuditLog{
+		db:    database,
+		table: "auth_events",
+	}
+}
+
+// Listen records every auth event dispatched on d
+func (a *AuditLog) Listen(d *events.Dispatcher) {
+	d.Listen("*", func(event events.Event) {
+		if err := a.Record(event); err != nil {
+			log.Printf("Failed to record %s: %v", event.EventName(), err)
+		}
+	})
+}
+
+// Record stores event if it is one of the auth events; others are ignored
+func (a *AuditLog) Record(event events.Event) error {
+	var (
+		userID  uint
+		email   string
+		info    RequestInfo
+		details map[string]interface{}
+	)
+
+	switch e := event.(type) {
+	case Login:
+		userID, email, info = e.UserID, e.Email, e.RequestInfo
+		details = map[string]interface{}{"method": e.Method}
+	case LoginFailed:
+		userID, email, info = e.UserID, e.Email, e.RequestInfo
+		details = map[string]interface{}{"reason": e.Reason}
+	case Logout:
+		userID, info = e.UserID, e.RequestInfo
+	case PasswordChanged:
+		userID, info = e.UserID, e.RequestInfo
+	case TokenCreated:
+		userID, info = e.UserID, e.RequestInfo
+		details = map[string]interface{}{"token_id": e.TokenID, "name": e.Name}
+	case TwoFactorEnabled:
+		userID, info = e.UserID, e.RequestInfo
+	case TwoFactorDisabled:
+		userID, info = e.UserID, e.RequestInfo
+	case RecoveryCodesRegenerated:
+		userID, info = e.UserID, e.RequestInfo
+	case ImpersonationStarted:
+		userID, info = e.UserID, e.RequestInfo
+		info.ImpersonatorID = e.ImpersonatorID
+	case ImpersonationEnded:
+		userID, info = e.UserID, e.RequestInfo
+	case ImpersonatedRequest:
+		userID, info = e.UserID, e.RequestInfo
+		details = map[string]interface{}{"method": e.Method, "path": e.Path}
+	case Lockout:
+		email, info.IP = e.Email, e.IP
+		details = map[string]interface{}{"attempts": e.Attempts, "until": e.Until}
+	case Unlock:
+		email, info.IP = e.Email, e.IP
+	default:
+		return nil
+	}
+
+	detailsJSON := ""
+	if details != nil {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		detailsJSON = string(encoded)
+	}
+
+	userAgent := info.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	return db.NewQueryBuilder(a.db, a.table).Insert(map[string]interface{}{
+		"type":            event.EventName(),
+		"user_id":         nullableID(userID),
+		"impersonator_id": nullableID(info.ImpersonatorID),
+		"email":           email,
+		"ip":              info.IP,
+		"user_agent":      userAgent,
+		"details":         detailsJSON,
+		"created_at":      time.Now(),
+	})
+}
+
+// Query returns the matching auth events, newest first
+func (a *AuditLog) Query(q AuditQuery) ([]AuthEvent, error) {
+	return a.QueryContext(context.Background(), q)
+}
+
+// QueryContext is Query, cancelled when ctx is done
+func (a *AuditLog) QueryContext(ctx context.Context, q AuditQuery) ([]AuthEvent, error) {
+	query := db.NewQueryBuilder(a.db, a.table).WithContext(ctx)
+	if q.UserID != 0 {
+		query.Where("user_id", "=", q.UserID)
+	}
+	if q.ImpersonatorID != 0 {
+		query.Where("impersonator_id", "=", q.ImpersonatorID)
+	}
+	if q.Type != "" {
+		query.Where("type", "=", q.Type)
+	}
+	if !q.Since.IsZero() {
+		query.Where("created_at", ">=", q.Since)
+	}
+	if !q.Until.IsZero() {
+		query.Where("created_at", "<", q.Until)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	} else if limit > 500 {
+		limit = 500
+	}
+	query.OrderBy("created_at", "DESC").OrderBy("id", "DESC").Limit(limit)
+	if q.Offset > 0 {
+		query.Offset(q.Offset)
+	}
+
+	var found []AuthEvent
+	if err := query.Get(&found); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// nullableID stores a zero ID as NULL
+func nullableID(id uint) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SetAuditLog enables the AuthEvents endpoint
+func (c *Controller) SetAuditLog(audit *AuditLog) {
+	c.audit = audit
+}
+
+// AuthEvents lists recorded auth events, newest first. It accepts the
+// user_id, impersonator_id, type, since, until (RFC 3339), limit and offset
+// query parameters.
+func (c *Controller) AuthEvents(w http.ResponseWriter, r *http.Request) {
+	if c.audit == nil {
+		http.Error(w, "Audit log is not enabled", http.StatusNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+	query := AuditQuery{Type: params.Get("type")}
+
+	uints := map[string]*uint{"user_id": &query.UserID, "impersonator_id": &query.ImpersonatorID}
+	for name, dest := range uints {
+		if value := params.Get(name); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dest = uint(id)
+		}
+	}
+
+	ints := map[string]*int{"limit": &query.Limit, "offset": &query.Offset}
+	for name, dest := range ints {
+		if value := params.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dest = n
+		}
+	}
+
+	times := map[string]*time.Time{"since": &query.Since, "until": &query.Until}
+	for name, dest := range times {
+		if value := params.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dest = t
+		}
+	}
+
+	found, err := c.audit.QueryContext(r.Context(), query)
+	if err != nil {
+		http.Error(w, "Failed to fetch auth events", http.StatusInternalServerError)
+		return
+	}
+	if found == nil {
+		found = []AuthEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(found)
+}
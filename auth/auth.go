@@ -3,163 +3,325 @@ package auth
 import (
 	"context"
 	"errors"
+	"log"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/example/goframe/db"
+	"github.com/example/goframe/events"
+	"github.com/example/goframe/hashing"
 	"github.com/golang-jwt/jwt/v4"
 )
 
 type userKey struct{}
+type claimsKey struct{}
 
-type User struct {
-	ID    uint   `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}
+// ErrInvalidCredentials is returned when an email/password pair does not match
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+var errInvalidToken = errors.New("invalid token")
 
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID         uint   `json:"user_id"`
+	Purpose        string `json:"purpose,omitempty"`         // Empty for access tokens
+	ImpersonatorID uint   `json:"impersonator_id,omitempty"` // Admin acting as the user, if any
 	jwt.RegisteredClaims
 }
 
 type AuthConfig struct {
-	Secret   string
-	Duration time.Duration
+	Secret                string        // Signs HS256 tokens when no key set is configured, and signed URLs
+	Issuer                string        // iss claim set on and required of every token
+	Audience              string        // aud claim set on and required of every token
+	Duration              time.Duration // Access token lifetime
+	RefreshDuration       time.Duration // Refresh token lifetime
+	PasswordResetExpire   time.Duration // How long a password reset link stays valid
+	PasswordResetThrottle time.Duration // Minimum delay between reset emails per address
+	VerificationExpire    time.Duration // How long an email verification link stays valid
+	AppURL                string        // Base URL used to build links in emails
+	AppName               string        // Shown in authenticator apps during 2FA enrollment
+	TwoFactorChallenge    time.Duration // How long a login may wait for its second factor
+	MagicLinkExpire       time.Duration // How long an emailed login link stays valid
+	MagicLinkThrottle     time.Duration // Minimum delay between login link emails per address
+	ImpersonationDuration time.Duration // Lifetime of an impersonation token; it cannot be refreshed
+	PasswordMinLength     int           // Shortest password accepted on registration and reset
+	PasswordMaxLength     int           // Longest password in bytes; bcrypt ignores bytes past 72
+
+	LoginMaxAttempts        int           // Failed logins per email+IP before a lockout
+	LoginMaxAccountAttempts int           // Failed logins per account, from any IP, before a lockout
+	LoginLockout            time.Duration // First lockout; doubles with each further failure
+	LoginMaxLockout         time.Duration // Longest lockout
+	LoginDecay              time.Duration // Failures are forgotten after this long without another
 }
 
 type Provider struct {
-	db     *db.Database
-	config AuthConfig
+	db             *db.Database
+	config         AuthConfig
+	users          UserProvider
+	refreshTokens  *RefreshTokenRepository
+	passwordResets *PasswordResetRepository
+	magicLinks     *MagicLinkRepository
+	accessTokens   *PersonalAccessTokenRepository
+	keys           *KeySet
+	denylist       Denylist
+	sessions       *SessionGuard
+	guards         map[string]Guard
+	attempts       AttemptStore
+	events         *events.Dispatcher
+	clock          func() time.Time
 }
 
-// NewProvider creates a new auth provider
+// NewProvider creates a new auth provider backed by the users table
 func NewProvider(database *db.Database, config AuthConfig) *Provider {
-	return &Provider{
-		db:     database,
-		config: config,
+	if config.Duration == 0 {
+		config.Duration = 15 * time.Minute
+	}
+	if config.RefreshDuration == 0 {
+		config.RefreshDuration = 30 * 24 * time.Hour
+	}
+	if config.PasswordResetExpire == 0 {
+		config.PasswordResetExpire = time.Hour
+	}
+	if config.PasswordResetThrottle == 0 {
+		config.PasswordResetThrottle = time.Minute
+	}
+	if config.VerificationExpire == 0 {
+		config.VerificationExpire = time.Hour
+	}
+	if config.AppName == "" {
+		config.AppName = "GoFrame"
+	}
+	if config.TwoFactorChallenge == 0 {
+		config.TwoFactorChallenge = 5 * time.Minute
+	}
+	if config.ImpersonationDuration == 0 {
+		config.ImpersonationDuration = 30 * time.Minute
+	}
+	if config.MagicLinkExpire == 0 {
+		config.MagicLinkExpire = 15 * time.Minute
+	}
+	if config.MagicLinkThrottle == 0 {
+		config.MagicLinkThrottle = time.Minute
+	}
+	if config.PasswordMinLength == 0 {
+		config.PasswordMinLength = 8
+	}
+	if config.PasswordMaxLength == 0 {
+		config.PasswordMaxLength = 72
+	}
+	if config.LoginMaxAttempts == 0 {
+		config.LoginMaxAttempts = 5
+	}
+	if config.LoginMaxAccountAttempts == 0 {
+		config.LoginMaxAccountAttempts = 20
+	}
+	if config.LoginLockout == 0 {
+		config.LoginLockout = time.Minute
+	}
+	if config.LoginMaxLockout == 0 {
+		config.LoginMaxLockout = time.Hour
+	}
+	if config.LoginDecay == 0 {
+		config.LoginDecay = time.Hour
 	}
+
+	p := &Provider{
+		db:             database,
+		config:         config,
+		users:          NewDatabaseUserProvider(NewUserRepository(database)),
+		refreshTokens:  NewRefreshTokenRepository(database),
+		passwordResets: NewPasswordResetRepository(database),
+		magicLinks:     NewMagicLinkRepository(database),
+		accessTokens:   NewPersonalAccessTokenRepository(database),
+		denylist:       NewMemoryDenylist(),
+		guards:         make(map[string]Guard),
+		attempts:       NewMemoryAttemptStore(),
+		events:         events.Default(),
+		clock:          time.Now,
+	}
+	p.sessions = NewSessionGuard(p)
+	p.RegisterGuard("api", &TokenGuard{provider: p})
+	p.RegisterGuard("web", p.sessions)
+
+	return p
+}
+
+// Config returns the provider configuration
+func (p *Provider) Config() AuthConfig {
+	return p.config
+}
+
+// SetUserProvider replaces the provider used to look up users
+func (p *Provider) SetUserProvider(users UserProvider) {
+	p.users = users
+}
+
+// SetDenylist replaces the store used to track revoked access tokens
+func (p *Provider) SetDenylist(denylist Denylist) {
+	p.denylist = denylist
 }
 
-// Middleware creates a middleware that authenticates requests
+// SetEvents replaces the dispatcher that auth events such as Lockout are sent to
+func (p *Provider) SetEvents(dispatcher *events.Dispatcher) {
+	p.events = dispatcher
+}
+
+// SetClock replaces the time source used for token and one-time code checks
+func (p *Provider) SetClock(clock func() time.Time) {
+	p.clock = clock
+}
+
+// now returns the current time from the provider's clock
+func (p *Provider) now() time.Time {
+	return p.clock()
+}
+
+// Middleware creates a middleware that authenticates requests carrying either
+// a JWT or a personal access token in the Authorization header. It is
+// shorthand for Guard("api").
 func (p *Provider) Middleware() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get the token from the Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Authorization header required", http.StatusUnauthorized)
-				return
-			}
-			
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			
-			// Parse the token
-			token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, errors.New("unexpected signing method")
-				}
-				return []byte(p.config.Secret), nil
-			})
-			
-			if err != nil {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-				return
-			}
-			
-			if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-				// Get the user from the database
-				user, err := p.GetUserByID(claims.UserID)
-				if err != nil {
-					http.Error(w, "User not found", http.StatusUnauthorized)
-					return
-				}
-				
-				// Add the user to the context
-				ctx := context.WithValue(r.Context(), userKey{}, user)
-				next.ServeHTTP(w, r.WithContext(ctx))
-			} else {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-			}
-		})
-	}
-}
-
-// Login authenticates a user and returns a token
-func (p *Provider) Login(email, password string) (string, error) {
-	// Get the user from the database
-	user, err := p.GetUserByEmail(email)
+	return p.Guard("api")
+}
+
+// authenticateJWT validates an access token and stores its user and claims in ctx
+func (p *Provider) authenticateJWT(ctx context.Context, tokenString string) (context.Context, error) {
+	claims, err := p.parseAccessToken(tokenString)
 	if err != nil {
-		return "", errors.New("invalid credentials")
-	}
-	
-	// Check the password (this would use a proper password comparison in a real app)
-	if !p.CheckPassword(user.ID, password) {
-		return "", errors.New("invalid credentials")
-	}
-	
-	// Create the JWT claims
-	expirationTime := time.Now().Add(p.config.Duration)
-	claims := &Claims{
-		UserID: user.ID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-	
-	// Create the JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(p.config.Secret))
+		return nil, errInvalidToken
+	}
+
+	// Reject tokens revoked by logout
+	revoked, err := p.denylist.Contains(claims.ID)
+	if err != nil || revoked {
+		return nil, errInvalidToken
+	}
+
+	user, err := p.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if claims.ImpersonatorID != 0 {
+		impersonator, err := p.GetUserByID(ctx, claims.ImpersonatorID)
+		if err != nil {
+			return nil, ErrUserNotFound
+		}
+		ctx = context.WithValue(ctx, impersonatorKey{}, impersonator)
+	}
+
+	ctx = context.WithValue(ctx, userKey{}, user)
+	ctx = context.WithValue(ctx, claimsKey{}, claims)
+	ctx = context.WithValue(ctx, scopesKey{}, []string{"*"})
+	return ctx, nil
+}
+
+// authenticateAccessToken validates a personal access token and stores its
+// user, the token and its scopes in ctx
+func (p *Provider) authenticateAccessToken(ctx context.Context, tokenString string) (context.Context, error) {
+	token, err := p.accessTokens.FindByToken(ctx, tokenString)
+	if err != nil || token.Expired(p.now()) {
+		return nil, errInvalidToken
+	}
+
+	user, err := p.GetUserByID(ctx, token.UserID)
 	if err != nil {
-		return "", err
+		return nil, ErrUserNotFound
 	}
-	
-	return tokenString, nil
+
+	// Usage tracking is best effort and must not fail the request
+	p.accessTokens.Touch(ctx, token, p.now())
+
+	ctx = context.WithValue(ctx, userKey{}, user)
+	ctx = context.WithValue(ctx, accessTokenKey{}, token)
+	ctx = context.WithValue(ctx, scopesKey{}, token.Scopes())
+	return ctx, nil
 }
 
-// GetUserByID gets a user by ID from the database
-func (p *Provider) GetUserByID(id uint) (*User, error) {
-	// This would use the DB in a real app
-	// For simplicity, we'll just return a fake user
-	if id == 1 {
-		return &User{
-			ID:    1,
-			Name:  "John Doe",
-			Email: "john@example.com",
-		}, nil
+// Login authenticates a user and returns an access/refresh token pair
+func (p *Provider) Login(ctx context.Context, email, password string) (*TokenPair, error) {
+	user, err := p.Attempt(ctx, email, password)
+	if err != nil {
+		return nil, err
 	}
-	return nil, errors.New("user not found")
+
+	return p.IssueTokens(ctx, user)
 }
 
-// GetUserByEmail gets a user by email from the database
-func (p *Provider) GetUserByEmail(email string) (*User, error) {
-	// This would use the DB in a real app
-	// For simplicity, we'll just return a fake user
-	if email == "john@example.com" {
-		return &User{
-			ID:    1,
-			Name:  "John Doe",
-			Email: "john@example.com",
-		}, nil
+// Attempt validates an email/password pair and returns the matching user.
+// Unknown emails still pay for a hash comparison so that response times
+// do not reveal which accounts exist. Hashes made with outdated settings are
+// upgraded on success; this changes the hash, so the user's other sessions
+// are signed out.
+func (p *Provider) Attempt(ctx context.Context, email, password string) (*UserModel, error) {
+	user, err := p.users.RetrieveByEmail(ctx, email)
+	if err != nil {
+		hashing.Default().Check(password, dummyHash())
+		return nil, ErrInvalidCredentials
+	}
+
+	if !p.CheckPassword(user, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if rehasher, ok := p.users.(PasswordRehasher); ok {
+		if err := rehasher.RehashPasswordIfNeeded(user, password); err != nil {
+			log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		}
 	}
-	return nil, errors.New("user not found")
+
+	return user, nil
+}
+
+// GetUserByID gets a user by ID from the user provider
+func (p *Provider) GetUserByID(ctx context.Context, id uint) (*UserModel, error) {
+	return p.users.RetrieveByID(ctx, id)
+}
+
+// GetUserByEmail gets a user by email from the user provider
+func (p *Provider) GetUserByEmail(ctx context.Context, email string) (*UserModel, error) {
+	return p.users.RetrieveByEmail(ctx, email)
 }
 
 // CheckPassword checks if a password is valid for a user
-func (p *Provider) CheckPassword(userID uint, password string) bool {
-	// This would use proper password comparison in a real app
-	// For simplicity, we'll just return true for a specific password
-	return password == "password"
+func (p *Provider) CheckPassword(user *UserModel, password string) bool {
+	return p.users.ValidateCredentials(user, password)
 }
 
-// GetUser gets the user from the request context
-func GetUser(ctx context.Context) *User {
-	user, ok := ctx.Value(userKey{}).(*User)
+// GetUser gets the user from the request context. While an admin is
+// impersonating someone this is the impersonated user; see Impersonator.
+func GetUser(ctx context.Context) *UserModel {
+	user, ok := ctx.Value(userKey{}).(*UserModel)
 	if !ok {
 		return nil
 	}
 	return user
 }
 
+// GetClaims gets the access token claims from the request context
+func GetClaims(ctx context.Context) *Claims {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	if !ok {
+		return nil
+	}
+	return claims
+}
+
+var (
+	dummyHashMu     sync.Mutex
+	dummyHashValue  string
+	dummyHashHasher hashing.Hasher
+)
+
+// dummyHash returns a hash from the default hasher, used to equalise timing
+// for unknown users. It is remade if the default hasher changes.
+func dummyHash() string {
+	dummyHashMu.Lock()
+	defer dummyHashMu.Unlock()
+
+	hasher := hashing.Default()
+	if dummyHashHasher != hasher {
+		dummyHashValue, _ = hasher.Make("goframe-timing-guard")
+		dummyHashHasher = hasher
+	}
+	return dummyHashValue
+}
@@ -2,20 +2,35 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/goframe/mail"
 )
 
 // Controller handles authentication-related HTTP requests
 type Controller struct {
 	provider *Provider
 	repo     *UserRepository
+	mailer   mail.Mailer
+	limiter  *RateLimiter
+	audit    *AuditLog
 }
 
 // NewController creates a new auth controller
-func NewController(provider *Provider, repo *UserRepository) *Controller {
+func NewController(provider *Provider, repo *UserRepository, mailer mail.Mailer) *Controller {
 	return &Controller{
 		provider: provider,
 		repo:     repo,
+		mailer:   mailer,
+		limiter:  NewRateLimiter(),
 	}
 }
 
@@ -27,8 +42,13 @@ type LoginRequest struct {
 
 // LoginResponse represents a login response
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	*TokenPair
+	User *UserModel `json:"user"`
+}
+
+// RefreshRequest carries a refresh token for rotation or revocation
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // RegisterRequest represents a registration request
@@ -38,36 +58,139 @@ type RegisterRequest struct {
 	Password string `json:"password"`
 }
 
-// Login handles login requests
+// ForgotPasswordRequest represents a request for a password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password
+type ResetPasswordRequest struct {
+	Email    string `json:"email"`
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// Login handles login requests. JSON clients receive a token pair; HTML form
+// posts start a session and are redirected to the page they were headed for.
+// Both get a session cookie. Users with 2FA enabled are first sent to
+// TwoFactorChallenge with a short-lived challenge token.
 func (c *Controller) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	redirect := "/dashboard"
+	form := isFormPost(r)
+	if form {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		req.Email = r.PostFormValue("email")
+		req.Password = r.PostFormValue("password")
+		redirect = SafeRedirect(r.PostFormValue("redirect"), redirect)
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 	
+	ip := clientIP(r)
+	if wait := c.provider.LoginLockedFor(req.Email, ip); wait > 0 {
+		c.loginFailed(r, req.Email, "locked_out")
+		tooManyLoginAttempts(w, wait)
+		return
+	}
+
 	// Authenticate the user
-	token, err := c.provider.Login(req.Email, req.Password)
+	user, err := c.provider.Attempt(r.Context(), req.Email, req.Password)
 	if err != nil {
+		wait, throttleErr := c.provider.RecordFailedLogin(req.Email, ip)
+		if throttleErr != nil {
+			log.Printf("Failed to record login attempt for %s: %v", req.Email, throttleErr)
+		}
+		c.loginFailed(r, req.Email, "invalid_credentials")
+		if wait > 0 {
+			tooManyLoginAttempts(w, wait)
+			return
+		}
+		if form {
+			target := "/login?" + url.Values{"error": {"1"}, "redirect": {redirect}}.Encode()
+			http.Redirect(w, r, target, http.StatusSeeOther)
+			return
+		}
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 	
-	// Get the user
-	userModel, err := c.repo.FindByEmail(req.Email)
+	if err := c.provider.ClearLoginAttempts(req.Email, ip); err != nil {
+		log.Printf("Failed to clear login attempts for %s: %v", req.Email, err)
+	}
+
+	if user.HasTwoFactorEnabled() {
+		c.startTwoFactorChallenge(w, r, user, form, redirect)
+		return
+	}
+
+	c.completeLogin(w, r, user, "password", form, redirect)
+}
+
+// LoginUser signs in a browser whose identity was established some other way,
+// such as an external identity provider. It applies the same two-factor
+// challenge as a password login before redirecting to redirect. method is
+// recorded on the Login event, e.g. "oauth:github".
+func (c *Controller) LoginUser(w http.ResponseWriter, r *http.Request, user *UserModel, method, redirect string) {
+	redirect = SafeRedirect(redirect, "/dashboard")
+	if user.HasTwoFactorEnabled() {
+		c.startTwoFactorChallenge(w, r, user, true, redirect)
+		return
+	}
+
+	c.completeLogin(w, r, user, method, true, redirect)
+}
+
+// loginFailed dispatches LoginFailed for an attempt to log in as email
+func (c *Controller) loginFailed(r *http.Request, email, reason string) {
+	event := LoginFailed{Email: email, Reason: reason, RequestInfo: requestInfo(r)}
+	if user, err := c.repo.FindByEmailContext(r.Context(), email); err == nil {
+		event.UserID = user.ID
+	}
+	c.provider.events.Dispatch(event)
+}
+
+// tooManyLoginAttempts responds 429 with the lockout in Retry-After
+func tooManyLoginAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Too many login attempts", http.StatusTooManyRequests)
+}
+
+// completeLogin signs in a fully authenticated user, redirecting form posts
+// and sending JSON clients a token pair. method is recorded on the Login event.
+func (c *Controller) completeLogin(w http.ResponseWriter, r *http.Request, user *UserModel, method string, form bool, redirect string) {
+	// API-only deployments run without the session middleware
+	if err := c.provider.StartSession(r, user); err != nil && !errors.Is(err, ErrSessionsDisabled) {
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+		return
+	}
+
+	c.provider.events.Dispatch(Login{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Method:      method,
+		RequestInfo: requestInfo(r),
+	})
+
+	if form {
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	tokens, err := c.provider.IssueTokens(r.Context(), user)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusInternalServerError)
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
 		return
 	}
 	
 	// Create the response
 	resp := LoginResponse{
-		Token: token,
-		User: User{
-			ID:    userModel.ID,
-			Name:  userModel.Name,
-			Email: userModel.Email,
-		},
+		TokenPair: tokens,
+		User:      user,
 	}
 	
 	// Send the response
@@ -84,8 +207,13 @@ func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	if err := c.provider.PasswordPolicy().Validate(req.Password, req.Email, req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
 	// Check if the user already exists
-	_, err := c.repo.FindByEmail(req.Email)
+	_, err := c.repo.FindByEmailContext(r.Context(), req.Email)
 	if err == nil {
 		http.Error(w, "Email already in use", http.StatusConflict)
 		return
@@ -98,8 +226,14 @@ func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// Ask the user to confirm their address; a failed send is not fatal since
+	// the link can be requested again
+	if err := c.sendVerificationEmail(userModel); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", userModel.Email, err)
+	}
+
 	// Authenticate the user
-	token, err := c.provider.Login(req.Email, req.Password)
+	tokens, err := c.provider.IssueTokens(r.Context(), userModel)
 	if err != nil {
 		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
 		return
@@ -107,12 +241,8 @@ func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
 	
 	// Create the response
 	resp := LoginResponse{
-		Token: token,
-		User: User{
-			ID:    userModel.ID,
-			Name:  userModel.Name,
-			Email: userModel.Email,
-		},
+		TokenPair: tokens,
+		User:      userModel,
 	}
 	
 	// Send the response
@@ -135,3 +265,200 @@ func (c *Controller) Me(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+
+// Refresh exchanges a refresh token for a new token pair
+func (c *Controller) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.provider.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout revokes the current access token and, if supplied, its refresh token.
+// Requests authenticated by the session cookie end the session instead.
+// Logging out of an impersonation token ends the impersonation.
+func (c *Controller) Logout(w http.ResponseWriter, r *http.Request) {
+	if ViaSession(r.Context()) {
+		if err := c.provider.EndSession(r); err != nil {
+			http.Error(w, "Failed to log out", http.StatusInternalServerError)
+			return
+		}
+		if user := GetUser(r.Context()); user != nil {
+			c.provider.events.Dispatch(Logout{UserID: user.ID, RequestInfo: requestInfo(r)})
+		}
+		if wantsJSON(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	claims := GetClaims(r.Context())
+	if claims == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	// The body is optional; a bare logout only revokes the access token
+	var req RefreshRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := c.provider.Logout(r.Context(), claims, req.RefreshToken); err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			http.Error(w, "Invalid refresh token", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	if IsImpersonating(r.Context()) {
+		c.provider.events.Dispatch(ImpersonationEnded{UserID: claims.UserID, RequestInfo: requestInfo(r)})
+	} else {
+		c.provider.events.Dispatch(Logout{UserID: claims.UserID, RequestInfo: requestInfo(r)})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPassword emails a password reset link. The response is the same whether
+// or not the address belongs to an account, so it cannot be used to probe emails.
+func (c *Controller) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// Throttle per address regardless of whether the account exists
+	key := "password-reset:" + strings.ToLower(strings.TrimSpace(req.Email))
+	if c.limiter.TooManyAttempts(key, 1) {
+		retryAfter := int(c.limiter.AvailableIn(key).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, "Too many password reset requests", http.StatusTooManyRequests)
+		return
+	}
+	c.limiter.Hit(key, c.provider.Config().PasswordResetThrottle)
+
+	if user, err := c.repo.FindByEmailContext(r.Context(), req.Email); err == nil {
+		token, err := c.provider.CreatePasswordResetToken(user)
+		if err != nil {
+			http.Error(w, "Failed to create reset token", http.StatusInternalServerError)
+			return
+		}
+
+		link := fmt.Sprintf("%s/password/reset?%s",
+			strings.TrimSuffix(c.provider.Config().AppURL, "/"),
+			url.Values{"token": {token}, "email": {user.Email}}.Encode())
+
+		err = c.mailer.Send(mail.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hello %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s\n\nIf you did not request a password reset, you can ignore this email.\n",
+				user.Name, c.provider.Config().PasswordResetExpire, link),
+		})
+		if err != nil {
+			http.Error(w, "Failed to send reset email", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If that email address is registered, a reset link has been sent.",
+	})
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword. It
+// accepts JSON, or the form on the page the reset link opens.
+func (c *Controller) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	form := isFormPost(r)
+	if form {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		req.Email = r.PostFormValue("email")
+		req.Token = r.PostFormValue("token")
+		req.Password = r.PostFormValue("password")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	fail := func(message string, status int, reason string) {
+		if form {
+			query := url.Values{"token": {req.Token}, "email": {req.Email}, "error": {reason}}
+			http.Redirect(w, r, "/password/reset?"+query.Encode(), http.StatusSeeOther)
+			return
+		}
+		http.Error(w, message, status)
+	}
+
+	if req.Email == "" || req.Token == "" || req.Password == "" {
+		fail("Email, token and password are required", http.StatusBadRequest, "missing")
+		return
+	}
+
+	if err := c.provider.PasswordPolicy().Validate(req.Password, req.Email); err != nil {
+		fail(err.Error(), http.StatusUnprocessableEntity, "password")
+		return
+	}
+
+	if err := c.provider.ConsumePasswordResetToken(req.Email, req.Token); err != nil {
+		if errors.Is(err, ErrInvalidResetToken) {
+			fail("Invalid or expired reset token", http.StatusBadRequest, "invalid")
+			return
+		}
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := c.repo.FindByEmailContext(r.Context(), req.Email)
+	if err != nil {
+		fail("Invalid or expired reset token", http.StatusBadRequest, "invalid")
+		return
+	}
+
+	if err := c.repo.UpdatePassword(user, req.Password); err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	// Sign the user out everywhere
+	if err := c.provider.RevokeAllTokens(r.Context(), user); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	c.provider.events.Dispatch(PasswordChanged{UserID: user.ID, RequestInfo: requestInfo(r)})
+
+	if form {
+		http.Redirect(w, r, "/login?reset=1", http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
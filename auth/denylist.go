@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Denylist records revoked access token IDs (jti) until they expire
+type Denylist interface {
+	// Add revokes the token ID until expiresAt
+	Add(jti string, expiresAt time.Time) error
+
+	// Contains reports whether the token ID has been revoked
+	Contains(jti string) (bool, error)
+}
+
+// MemoryDenylist is an in-process Denylist. Entries are lost on restart,
+// which is acceptable as long as access tokens are short-lived.
+type MemoryDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+// NewMemoryDenylist creates an empty in-memory denylist
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{entries: make(map[string]time.Time)}
+}
+
+// Add revokes the token ID until expiresAt
+func (d *MemoryDenylist) Add(jti string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop entries whose tokens have expired on their own
+	now := time.Now()
+	for id, until := range d.entries {
+		if now.After(until) {
+			delete(d.entries, id)
+		}
+	}
+
+	d.entries[jti] = expiresAt
+	return nil
+}
+
+// Contains reports whether the token ID has been revoked
+func (d *MemoryDenylist) Contains(jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	until, ok := d.entries[jti]
+	if !ok {
+		return false, nil
+	}
+	return time.Now().Before(until), nil
+}
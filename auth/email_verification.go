@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/goframe/mail"
+)
+
+// VerificationURL returns a signed, expiring link that confirms the user's email
+func (p *Provider) VerificationURL(user *UserModel) string {
+	params := url.Values{
+		"id":   {strconv.FormatUint(uint64(user.ID), 10)},
+		"hash": {hashToken(strings.ToLower(user.Email))},
+	}
+	return p.SignedURL("/email/verify", params, time.Now().Add(p.config.VerificationExpire))
+}
+
+// sendVerificationEmail mails the user a link to confirm their address
+func (c *Controller) sendVerificationEmail(user *UserModel) error {
+	return c.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n\nIf you did not create an account, no further action is required.\n",
+			user.Name, c.provider.Config().VerificationExpire, c.provider.VerificationURL(user)),
+	})
+}
+
+// VerifyEmail marks the user's email as verified using a signed link
+func (c *Controller) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if !c.provider.HasValidSignature(r) {
+		http.Error(w, "Invalid or expired verification link", http.StatusForbidden)
+		return
+	}
+
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid verification link", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.repo.FindByIDContext(r.Context(), uint(id))
+	if err != nil {
+		http.Error(w, "Invalid verification link", http.StatusForbidden)
+		return
+	}
+
+	// A link issued for a previous address must not verify the current one
+	if r.URL.Query().Get("hash") != hashToken(strings.ToLower(user.Email)) {
+		http.Error(w, "Invalid verification link", http.StatusForbidden)
+		return
+	}
+
+	if !user.HasVerifiedEmail() {
+		if err := c.repo.MarkEmailVerified(user); err != nil {
+			http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !wantsJSON(r) {
+		http.Redirect(w, r, "/dashboard?verified=1", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email verified."})
+}
+
+// ResendVerification sends a fresh verification link to the current user
+func (c *Controller) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if user.HasVerifiedEmail() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	key := fmt.Sprintf("verification:%d", user.ID)
+	if c.limiter.TooManyAttempts(key, 1) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(c.limiter.AvailableIn(key).Seconds())+1))
+		http.Error(w, "Too many verification requests", http.StatusTooManyRequests)
+		return
+	}
+	c.limiter.Hit(key, time.Minute)
+
+	if err := c.sendVerificationEmail(user); err != nil {
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification link sent."})
+}
+
+// wantsJSON reports whether the client prefers a JSON response over HTML
+func wantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") ||
+		strings.Contains(r.Header.Get("Content-Type"), "application/json")
+}
+
+// isFormPost reports whether the request body is an HTML form submission
+func isFormPost(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(contentType, "multipart/form-data")
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestInfo records where an auth event came from
+type RequestInfo struct {
+	IP             string
+	UserAgent      string
+	ImpersonatorID uint // Set when an admin acting as the user caused the event
+}
+
+// requestInfo describes r for an auth event
+func requestInfo(r *http.Request) RequestInfo {
+	info := RequestInfo{IP: clientIP(r), UserAgent: r.UserAgent()}
+	if impersonator := Impersonator(r.Context()); impersonator != nil {
+		info.ImpersonatorID = impersonator.ID
+	}
+	return info
+}
+
+// Login is dispatched when a user logs in. Method is "password",
+// "two_factor", "magic_link" or "oauth:<provider>".
+type Login struct {
+	UserID uint
+	Email  string
+	Method string
+	RequestInfo
+}
+
+// EventName returns "auth.login"
+func (Login) EventName() string { return "auth.login" }
+
+// LoginFailed is dispatched when a login attempt is rejected. UserID is zero
+// when the email does not belong to an account.
+type LoginFailed struct {
+	UserID uint
+	Email  string
+	Reason string // "invalid_credentials", "locked_out", "invalid_two_factor_code" or "invalid_magic_link"
+	RequestInfo
+}
+
+// EventName returns "auth.login_failed"
+func (LoginFailed) EventName() string { return "auth.login_failed" }
+
+// Logout is dispatched when a user logs out
+type Logout struct {
+	UserID uint
+	RequestInfo
+}
+
+// EventName returns "auth.logout"
+func (Logout) EventName() string { return "auth.logout" }
+
+// PasswordChanged is dispatched when a user sets a new password
+type PasswordChanged struct {
+	UserID uint
+	RequestInfo
+}
+
+// EventName returns "auth.password_changed"
+func (PasswordChanged) EventName() string { return "auth.password_changed" }
+
+// TokenCreated is dispatched when a user creates a personal access token
+type TokenCreated struct {
+	UserID  uint
+	TokenID uint
+	Name    string
+	RequestInfo
+}
+
+// EventName returns "auth.token_created"
+func (TokenCreated) EventName() string { return "auth.token_created" }
+
+// TwoFactorEnabled is dispatched when a user confirms two-factor authentication
+type TwoFactorEnabled struct {
+	UserID uint
+	RequestInfo
+}
+
+// EventName returns "auth.two_factor_enabled"
+func (TwoFactorEnabled) EventName() string { return "auth.two_factor_enabled" }
+
+// TwoFactorDisabled is dispatched when a user turns off two-factor authentication
+type TwoFactorDisabled struct {
+	UserID uint
+	RequestInfo
+}
+
+// EventName returns "auth.two_factor_disabled"
+func (TwoFactorDisabled) EventName() string { return "auth.two_factor_disabled" }
+
+// RecoveryCodesRegenerated is dispatched when a user replaces their 2FA recovery codes
+type RecoveryCodesRegenerated struct {
+	UserID uint
+	RequestInfo
+}
+
+// EventName returns "auth.recovery_codes_regenerated"
+func (RecoveryCodesRegenerated) EventName() string { return "auth.recovery_codes_regenerated" }
+
+// ImpersonationStarted is dispatched when an admin starts acting as a user
+type ImpersonationStarted struct {
+	UserID         uint
+	ImpersonatorID uint
+	RequestInfo
+}
+
+// EventName returns "auth.impersonation_started"
+func (ImpersonationStarted) EventName() string { return "auth.impersonation_started" }
+
+// ImpersonationEnded is dispatched when an admin stops acting as a user
+type ImpersonationEnded struct {
+	UserID uint
+	RequestInfo
+}
+
+// EventName returns "auth.impersonation_ended"
+func (ImpersonationEnded) EventName() string { return "auth.impersonation_ended" }
+
+// ImpersonatedRequest is dispatched for every request made while an admin is
+// acting as a user
+type ImpersonatedRequest struct {
+	UserID uint
+	Method string
+	Path   string
+	RequestInfo
+}
+
+// EventName returns "auth.impersonated_request"
+func (ImpersonatedRequest) EventName() string { return "auth.impersonated_request" }
+
+// Lockout is dispatched when repeated failed logins lock out an email+IP pair
+// or, when IP is empty, the whole account
+type Lockout struct {
+	Email    string
+	IP       string
+	Attempts int
+	Until    time.Time
+}
+
+// EventName returns "auth.lockout"
+func (Lockout) EventName() string { return "auth.lockout" }
+
+// Unlock is dispatched when a successful login clears a lockout. IP is empty
+// for the account-wide lockout.
+type Unlock struct {
+	Email string
+	IP    string
+}
+
+// EventName returns "auth.unlock"
+func (Unlock) EventName() string { return "auth.unlock" }
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// BeforeFunc runs ahead of every gate check. Returning decided=true short-circuits
+// the check with the given result; otherwise the normal ability or policy runs.
+type BeforeFunc func(user *UserModel, ability string) (allowed bool, decided bool)
+
+// Gate answers whether a user may perform an ability, optionally against a model.
+//
+// Abilities are plain callbacks registered with Define. Policies group the
+// abilities for one model type: Allows(user, "update", post) calls the Update
+// method of the policy registered for post's type.
+type Gate struct {
+	mu        sync.RWMutex
+	abilities map[string]reflect.Value
+	policies  map[reflect.Type]reflect.Value
+	before    []BeforeFunc
+}
+
+var (
+	userModelType = reflect.TypeOf((*UserModel)(nil))
+	boolType      = reflect.TypeOf(true)
+	defaultGate   = NewGate()
+)
+
+// NewGate creates an empty gate
+func NewGate() *Gate {
+	return &Gate{
+		abilities: make(map[string]reflect.Value),
+		policies:  make(map[reflect.Type]reflect.Value),
+	}
+}
+
+// DefaultGate returns the gate used by the package-level helpers
+func DefaultGate() *Gate {
+	return defaultGate
+}
+
+// Define registers an ability. The callback must be a function whose first
+// parameter is *UserModel and which returns bool, for example:
+//
+//	gate.Define("update-post", func(user *auth.UserModel, post *models.Post) bool {
+//		return user.ID == post.UserID
+//	})
+func (g *Gate) Define(ability string, callback interface{}) {
+	fn := reflect.ValueOf(callback)
+	if err := checkAbilityFunc(fn.Type()); err != nil {
+		panic(fmt.Sprintf("auth: ability %q: %v", ability, err))
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.abilities[ability] = fn
+}
+
+// Policy registers a policy for a model type. model may be a value or a pointer
+// of the model type, and so may the argument given to Allows; policy methods
+// follow the same rules as Define callbacks.
+func (g *Gate) Policy(model interface{}, policy interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.policies[indirectType(reflect.TypeOf(model))] = reflect.ValueOf(policy)
+}
+
+// Before registers a hook that runs ahead of every check
+func (g *Gate) Before(fn BeforeFunc) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.before = append(g.before, fn)
+}
+
+// Has reports whether an ability has been defined
+func (g *Gate) Has(ability string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, ok := g.abilities[ability]
+	return ok
+}
+
+// Allows reports whether user may perform ability. Guests (nil users) are
+// always denied.
+func (g *Gate) Allows(user *UserModel, ability string, args ...interface{}) bool {
+	if user == nil {
+		return false
+	}
+
+	g.mu.RLock()
+	before := g.before
+	g.mu.RUnlock()
+
+	for _, hook := range before {
+		if allowed, decided := hook(user, ability); decided {
+			return allowed
+		}
+	}
+
+	if fn, ok := g.policyMethod(ability, args); ok {
+		return call(fn, user, args)
+	}
+
+	g.mu.RLock()
+	fn, ok := g.abilities[ability]
+	g.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	return call(fn, user, args)
+}
+
+// Denies reports whether user may not perform ability
+func (g *Gate) Denies(user *UserModel, ability string, args ...interface{}) bool {
+	return !g.Allows(user, ability, args...)
+}
+
+// policyMethod finds the policy method for ability based on the first argument's type
+func (g *Gate) policyMethod(ability string, args []interface{}) (reflect.Value, bool) {
+	if len(args) == 0 || args[0] == nil {
+		return reflect.Value{}, false
+	}
+
+	g.mu.RLock()
+	policy, ok := g.policies[indirectType(reflect.TypeOf(args[0]))]
+	g.mu.RUnlock()
+	if !ok {
+		return reflect.Value{}, false
+	}
+
+	method := policy.MethodByName(methodName(ability))
+	if !method.IsValid() || checkAbilityFunc(method.Type()) != nil {
+		return reflect.Value{}, false
+	}
+
+	return method, true
+}
+
+// Define registers an ability on the default gate
+func Define(ability string, callback interface{}) {
+	defaultGate.Define(ability, callback)
+}
+
+// Policy registers a model policy on the default gate
+func Policy(model interface{}, policy interface{}) {
+	defaultGate.Policy(model, policy)
+}
+
+// Before registers a hook on the default gate
+func Before(fn BeforeFunc) {
+	defaultGate.Before(fn)
+}
+
+// Allows checks an ability against the default gate
+func Allows(user *UserModel, ability string, args ...interface{}) bool {
+	return defaultGate.Allows(user, ability, args...)
+}
+
+// Denies checks an ability against the default gate
+func Denies(user *UserModel, ability string, args ...interface{}) bool {
+	return defaultGate.Denies(user, ability, args...)
+}
+
+// checkAbilityFunc validates the shape of an ability callback
+func checkAbilityFunc(t reflect.Type) error {
+	if t.Kind() != reflect.Func {
+		return fmt.Errorf("callback must be a function, got %s", t)
+	}
+	if t.NumIn() == 0 || t.In(0) != userModelType {
+		return fmt.Errorf("first parameter must be *auth.UserModel")
+	}
+	if t.NumOut() != 1 || t.Out(0) != boolType {
+		return fmt.Errorf("callback must return bool")
+	}
+	return nil
+}
+
+// call invokes an ability callback, denying when the arguments do not fit
+func call(fn reflect.Value, user *UserModel, args []interface{}) bool {
+	t := fn.Type()
+	if t.IsVariadic() || t.NumIn() != len(args)+1 {
+		return false
+	}
+
+	in := []reflect.Value{reflect.ValueOf(user)}
+	for i, arg := range args {
+		param := t.In(i + 1)
+		if arg == nil {
+			switch param.Kind() {
+			case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+				in = append(in, reflect.Zero(param))
+				continue
+			}
+			return false
+		}
+
+		value, ok := adaptArg(reflect.ValueOf(arg), param)
+		if !ok {
+			return false
+		}
+		in = append(in, value)
+	}
+
+	return fn.Call(in)[0].Bool()
+}
+
+// adaptArg fits an argument to a parameter, converting between a model value
+// and a pointer to it so Allows(user, "update", post) works with either
+func adaptArg(value reflect.Value, param reflect.Type) (reflect.Value, bool) {
+	t := value.Type()
+	switch {
+	case t.AssignableTo(param):
+		return value, true
+	case param.Kind() == reflect.Ptr && t.AssignableTo(param.Elem()):
+		ptr := reflect.New(param.Elem())
+		ptr.Elem().Set(value)
+		return ptr, true
+	case t.Kind() == reflect.Ptr && !value.IsNil() && t.Elem().AssignableTo(param):
+		return value.Elem(), true
+	}
+	return reflect.Value{}, false
+}
+
+// indirectType strips pointers so *Post and Post share a policy
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// methodName converts an ability such as "force-delete" to "ForceDelete"
+func methodName(ability string) string {
+	parts := strings.FieldsFunc(ability, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.' || r == ' '
+	})
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
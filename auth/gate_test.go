@@ -0,0 +1,78 @@
+package auth
+
+import "testing"
+
+type gateTestPost struct {
+	UserID uint
+}
+
+// pointerPolicy takes the model by pointer, valuePolicy by value
+type pointerPolicy struct{}
+
+func (pointerPolicy) Update(user *UserModel, post *gateTestPost) bool {
+	return post != nil && post.UserID == user.ID
+}
+
+type valuePolicy struct{}
+
+func (valuePolicy) Update(user *UserModel, post gateTestPost) bool {
+	return post.UserID == user.ID
+}
+
+func TestGatePolicyAcceptsPointersAndValues(t *testing.T) {
+	author := &UserModel{}
+	author.ID = 7
+	other := &UserModel{}
+	other.ID = 8
+	post := gateTestPost{UserID: 7}
+
+	registrations := map[string]func(*Gate){
+		"value model, pointer policy":   func(g *Gate) { g.Policy(gateTestPost{}, pointerPolicy{}) },
+		"pointer model, pointer policy": func(g *Gate) { g.Policy(&gateTestPost{}, pointerPolicy{}) },
+		"value model, value policy":     func(g *Gate) { g.Policy(gateTestPost{}, valuePolicy{}) },
+		"pointer model, value policy":   func(g *Gate) { g.Policy(&gateTestPost{}, valuePolicy{}) },
+	}
+	for name, register := range registrations {
+		t.Run(name, func(t *testing.T) {
+			g := NewGate()
+			register(g)
+
+			if !g.Allows(author, "update", post) {
+				t.Error("author denied with a value")
+			}
+			if !g.Allows(author, "update", &post) {
+				t.Error("author denied with a pointer")
+			}
+			if g.Allows(other, "update", post) || g.Allows(other, "update", &post) {
+				t.Error("other user allowed")
+			}
+		})
+	}
+}
+
+func TestGateDefineAcceptsPointersAndValues(t *testing.T) {
+	author := &UserModel{}
+	author.ID = 7
+	post := gateTestPost{UserID: 7}
+
+	g := NewGate()
+	g.Define("update-post", pointerPolicy{}.Update)
+
+	if !g.Allows(author, "update-post", post) || !g.Allows(author, "update-post", &post) {
+		t.Error("author denied")
+	}
+	if g.Allows(author, "update-post", "not a post") {
+		t.Error("unrelated argument allowed")
+	}
+}
+
+func TestGateValueCallbackDeniesNilPointer(t *testing.T) {
+	user := &UserModel{}
+
+	g := NewGate()
+	g.Define("update-post", valuePolicy{}.Update)
+
+	if g.Allows(user, "update-post", (*gateTestPost)(nil)) {
+		t.Error("nil pointer allowed")
+	}
+}
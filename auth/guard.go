@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Guard authenticates requests using one kind of credential
+type Guard interface {
+	// Authenticate resolves the request's user and returns a context carrying
+	// the user and any credential details
+	Authenticate(r *http.Request) (context.Context, error)
+
+	// Unauthenticated responds to a request that no guard could authenticate
+	Unauthenticated(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// RegisterGuard makes a guard available under name for Guard()
+func (p *Provider) RegisterGuard(name string, guard Guard) {
+	p.guards[name] = guard
+}
+
+// Guard creates a middleware that authenticates requests with the named guards,
+// trying each in order. When all of them fail, the first guard responds.
+// Requests made while impersonating a user dispatch ImpersonatedRequest.
+//
+//	dashboard.Use(authProvider.Guard("web"))
+//	api.Use(authProvider.Guard("api"))
+func (p *Provider) Guard(names ...string) func(http.Handler) http.Handler {
+	if len(names) == 0 {
+		names = []string{"api"}
+	}
+
+	guards := make([]Guard, len(names))
+	for i, name := range names {
+		guard, ok := p.guards[name]
+		if !ok {
+			panic("auth: unknown guard " + name)
+		}
+		guards[i] = guard
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var firstErr error
+			for _, guard := range guards {
+				ctx, err := guard.Authenticate(r)
+				if err == nil {
+					r = r.WithContext(ctx)
+					if IsImpersonating(ctx) {
+						p.events.Dispatch(ImpersonatedRequest{
+							UserID:      GetUser(ctx).ID,
+							Method:      r.Method,
+							Path:        r.URL.Path,
+							RequestInfo: requestInfo(r),
+						})
+					}
+					next.ServeHTTP(w, r)
+					return
+				}
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+
+			guards[0].Unauthenticated(w, r, firstErr)
+		})
+	}
+}
+
+// TokenGuard authenticates requests carrying a JWT or a personal access token
+// in the Authorization header
+type TokenGuard struct {
+	provider *Provider
+}
+
+var errMissingAuthorization = errors.New("authorization header required")
+
+// Authenticate validates the bearer token
+func (g *TokenGuard) Authenticate(r *http.Request) (context.Context, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, errMissingAuthorization
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if strings.HasPrefix(tokenString, AccessTokenPrefix) {
+		return g.provider.authenticateAccessToken(r.Context(), tokenString)
+	}
+	return g.provider.authenticateJWT(r.Context(), tokenString)
+}
+
+// Unauthenticated responds with 401
+func (g *TokenGuard) Unauthenticated(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errMissingAuthorization):
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusUnauthorized)
+	default:
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	}
+}
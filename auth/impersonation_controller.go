@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ImpersonateRequest names the user an admin wants to act as
+type ImpersonateRequest struct {
+	UserID uint `json:"user_id"`
+}
+
+// Impersonate issues the current admin an access token for another user.
+// Ending the impersonation is a regular Logout with that token.
+func (c *Controller) Impersonate(w http.ResponseWriter, r *http.Request) {
+	admin := GetUser(r.Context())
+	if admin == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if forbidWhileImpersonating(w, r) {
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == 0 {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.provider.GetUserByID(r.Context(), req.UserID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	tokens, err := c.provider.Impersonate(admin, user)
+	if err != nil {
+		if errors.Is(err, ErrCannotImpersonate) {
+			http.Error(w, "Cannot impersonate this user", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to impersonate user", http.StatusInternalServerError)
+		return
+	}
+
+	c.provider.events.Dispatch(ImpersonationStarted{
+		UserID:         user.ID,
+		ImpersonatorID: admin.ID,
+		RequestInfo:    requestInfo(r),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LoginResponse{TokenPair: tokens, User: user})
+}
+
+// forbidWhileImpersonating responds 403 and returns true for requests made by
+// an admin acting as a user. It guards actions that change how the user
+// authenticates, which support staff should never take on their behalf.
+func forbidWhileImpersonating(w http.ResponseWriter, r *http.Request) bool {
+	if !IsImpersonating(r.Context()) {
+		return false
+	}
+	http.Error(w, "Not allowed while impersonating", http.StatusForbidden)
+	return true
+}
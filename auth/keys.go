@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// KeyConfig describes one JWT signing key loaded from PEM files
+type KeyConfig struct {
+	ID             string    // Published as the token's kid header
+	Algorithm      string    // "RS256", "ES256" or "EdDSA"
+	PrivateKeyFile string    // Required for the active key; optional for retired keys
+	PublicKeyFile  string    // Optional when the private key is given
+	RetiredAt      time.Time // When the key stopped signing; zero for current keys
+}
+
+// signingKey is a parsed KeyConfig
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.PrivateKey
+	public    crypto.PublicKey
+	retiredAt time.Time
+}
+
+// KeySet holds the asymmetric keys tokens are signed and verified with. One
+// key signs new tokens; retired keys still verify tokens until their rotation
+// window has passed.
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+	window time.Duration
+}
+
+// LoadKeySet reads the configured keys from disk. active is the ID of the key
+// that signs new tokens and window is how long retired keys stay valid, which
+// should be at least the access token lifetime.
+func LoadKeySet(configs []KeyConfig, active string, window time.Duration) (*KeySet, error) {
+	if window == 0 {
+		window = 24 * time.Hour
+	}
+	set := &KeySet{keys: make(map[string]*signingKey), window: window}
+
+	for _, cfg := range configs {
+		key, err := loadKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", cfg.ID, err)
+		}
+		if _, exists := set.keys[key.id]; exists {
+			return nil, fmt.Errorf("signing key %q is listed twice", key.id)
+		}
+		set.keys[key.id] = key
+	}
+
+	set.active = set.keys[active]
+	if set.active == nil {
+		return nil, fmt.Errorf("active signing key %q is not configured", active)
+	}
+	if set.active.private == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", active)
+	}
+	if !set.active.retiredAt.IsZero() {
+		return nil, fmt.Errorf("active signing key %q is retired", active)
+	}
+
+	return set, nil
+}
+
+// loadKey parses one key's PEM files and checks they suit its algorithm
+func loadKey(cfg KeyConfig) (*signingKey, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("kid is required")
+	}
+	if cfg.PrivateKeyFile == "" && cfg.PublicKeyFile == "" {
+		return nil, errors.New("a private or public key file is required")
+	}
+
+	key := &signingKey{id: cfg.ID, retiredAt: cfg.RetiredAt}
+
+	var parsePrivate func([]byte) (crypto.PrivateKey, error)
+	var parsePublic func([]byte) (crypto.PublicKey, error)
+
+	switch cfg.Algorithm {
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		parsePrivate = func(b []byte) (crypto.PrivateKey, error) { return jwt.ParseRSAPrivateKeyFromPEM(b) }
+		parsePublic = func(b []byte) (crypto.PublicKey, error) { return jwt.ParseRSAPublicKeyFromPEM(b) }
+	case "ES256":
+		key.method = jwt.SigningMethodES256
+		parsePrivate = func(b []byte) (crypto.PrivateKey, error) {
+			k, err := jwt.ParseECPrivateKeyFromPEM(b)
+			if err == nil && k.Curve != elliptic.P256() {
+				return nil, errors.New("ES256 requires a P-256 key")
+			}
+			return k, err
+		}
+		parsePublic = func(b []byte) (crypto.PublicKey, error) {
+			k, err := jwt.ParseECPublicKeyFromPEM(b)
+			if err == nil && k.Curve != elliptic.P256() {
+				return nil, errors.New("ES256 requires a P-256 key")
+			}
+			return k, err
+		}
+	case "EdDSA":
+		key.method = jwt.SigningMethodEdDSA
+		parsePrivate = jwt.ParseEdPrivateKeyFromPEM
+		parsePublic = jwt.ParseEdPublicKeyFromPEM
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	if cfg.PrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.private, err = parsePrivate(data); err != nil {
+			return nil, err
+		}
+		key.public = key.private.(crypto.Signer).Public()
+	}
+
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.public, err = parsePublic(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// usable reports whether the key may still verify tokens at now
+func (k *KeySet) usable(key *signingKey, now time.Time) bool {
+	return key.retiredAt.IsZero() || now.Before(key.retiredAt.Add(k.window))
+}
+
+// sign signs claims with the active key and sets the kid header
+func (k *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+	return token.SignedString(k.active.private)
+}
+
+// verificationKey returns the public key for a token, checking that the
+// token's algorithm matches the one configured for its kid
+func (k *KeySet) verificationKey(token *jwt.Token, now time.Time) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok || !k.usable(key, now) {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.public, nil
+}
+
+// jwk is a public key in JSON Web Key form (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// jwks returns every key that can still verify tokens
+func (k *KeySet) jwks(now time.Time) []jwk {
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	keys := []jwk{}
+	for _, key := range k.keys {
+		if !k.usable(key, now) {
+			continue
+		}
+
+		entry := jwk{Kid: key.id, Alg: key.method.Alg(), Use: "sig"}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			entry.Kty = "RSA"
+			entry.N = b64(public.N.Bytes())
+			entry.E = b64(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			entry.Kty = "EC"
+			entry.Crv = "P-256"
+			entry.X = b64(public.X.FillBytes(make([]byte, 32)))
+			entry.Y = b64(public.Y.FillBytes(make([]byte, 32)))
+		case ed25519.PublicKey:
+			entry.Kty = "OKP"
+			entry.Crv = "Ed25519"
+			entry.X = b64(public)
+		default:
+			continue
+		}
+		keys = append(keys, entry)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
+
+// SetKeySet switches token signing from HS256 with the shared secret to the
+// keys in set. Tokens signed with the secret are no longer accepted.
+func (p *Provider) SetKeySet(set *KeySet) {
+	p.keys = set
+}
+
+// JWKS serves the public signing keys as a JSON Web Key Set so other services
+// can verify access tokens without the signing secret
+func (p *Provider) JWKS(w http.ResponseWriter, r *http.Request) {
+	keys := []jwk{}
+	if p.keys != nil {
+		keys = p.keys.jwks(p.now())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]jwk{"keys": keys})
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginAttempts tracks failed logins for one throttle key
+type LoginAttempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AttemptStore persists failed login counters
+type AttemptStore interface {
+	// Get returns the attempts recorded for key, or the zero value
+	Get(key string) (LoginAttempts, error)
+
+	// Put records attempts for key, to be forgotten after ttl
+	Put(key string, attempts LoginAttempts, ttl time.Duration) error
+
+	// Delete forgets the attempts recorded for key
+	Delete(key string) error
+}
+
+type memoryAttempt struct {
+	attempts  LoginAttempts
+	expiresAt time.Time
+}
+
+// MemoryAttemptStore keeps failed login counters in process memory
+type MemoryAttemptStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryAttempt
+}
+
+// NewMemoryAttemptStore creates an empty in-memory attempt store
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{entries: make(map[string]memoryAttempt)}
+}
+
+// Get returns the attempts recorded for key
+func (s *MemoryAttemptStore) Get(key string) (LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return LoginAttempts{}, nil
+	}
+	return entry.attempts, nil
+}
+
+// Put records attempts for key
+func (s *MemoryAttemptStore) Put(key string, attempts LoginAttempts, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+
+	s.entries[key] = memoryAttempt{attempts: attempts, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// Delete forgets the attempts recorded for key
+func (s *MemoryAttemptStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// SetAttemptStore replaces the store used to track failed logins
+func (p *Provider) SetAttemptStore(store AttemptStore) {
+	p.attempts = store
+}
+
+// throttleKey identifies one of the two counters a login attempt updates
+type throttleKey struct {
+	key   string
+	ip    string // Empty for the account-wide counter
+	limit int
+}
+
+// throttleKeys returns the email+IP counter and the account-wide counter.
+// The account limit is higher so one attacker cannot easily lock a user out.
+func (p *Provider) throttleKeys(email, ip string) []throttleKey {
+	email = strings.ToLower(strings.TrimSpace(email))
+	return []throttleKey{
+		{key: "login:" + email + "|" + ip, ip: ip, limit: p.config.LoginMaxAttempts},
+		{key: "login:" + email, limit: p.config.LoginMaxAccountAttempts},
+	}
+}
+
+// LoginLockedFor returns how long logins for email from ip are locked out,
+// or 0 if they may proceed
+func (p *Provider) LoginLockedFor(email, ip string) time.Duration {
+	now := p.now()
+
+	var wait time.Duration
+	for _, k := range p.throttleKeys(email, ip) {
+		attempts, err := p.attempts.Get(k.key)
+		if err != nil {
+			continue
+		}
+		if remaining := attempts.LockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait
+}
+
+// RecordFailedLogin counts a failed login for email from ip and returns how
+// long further attempts are locked out. Once a counter reaches its limit each
+// further failure doubles the lockout, up to LoginMaxLockout.
+func (p *Provider) RecordFailedLogin(email, ip string) (time.Duration, error) {
+	now := p.now()
+
+	var wait time.Duration
+	for _, k := range p.throttleKeys(email, ip) {
+		attempts, err := p.attempts.Get(k.key)
+		if err != nil {
+			return 0, err
+		}
+
+		// Old failures are forgotten after a quiet period
+		if now.Sub(attempts.LastFailure) > p.config.LoginDecay {
+			attempts = LoginAttempts{}
+		}
+
+		attempts.Failures++
+		attempts.LastFailure = now
+
+		if attempts.Failures >= k.limit {
+			lockout := p.lockoutFor(attempts.Failures - k.limit)
+			attempts.LockedUntil = now.Add(lockout)
+			if lockout > wait {
+				wait = lockout
+			}
+
+			p.events.Dispatch(Lockout{
+				Email:    strings.ToLower(strings.TrimSpace(email)),
+				IP:       k.ip,
+				Attempts: attempts.Failures,
+				Until:    attempts.LockedUntil,
+			})
+		}
+
+		ttl := p.config.LoginDecay
+		if locked := attempts.LockedUntil.Sub(now); locked > ttl {
+			ttl = locked
+		}
+		if err := p.attempts.Put(k.key, attempts, ttl); err != nil {
+			return 0, err
+		}
+	}
+	return wait, nil
+}
+
+// ClearLoginAttempts resets the counters for email from ip after a successful login
+func (p *Provider) ClearLoginAttempts(email, ip string) error {
+	for _, k := range p.throttleKeys(email, ip) {
+		attempts, err := p.attempts.Get(k.key)
+		if err != nil {
+			return err
+		}
+		if attempts.Failures == 0 {
+			continue
+		}
+
+		if err := p.attempts.Delete(k.key); err != nil {
+			return err
+		}
+		if !attempts.LockedUntil.IsZero() {
+			p.events.Dispatch(Unlock{
+				Email: strings.ToLower(strings.TrimSpace(email)),
+				IP:    k.ip,
+			})
+		}
+	}
+	return nil
+}
+
+// lockoutFor returns the lockout after excess failures beyond the limit
+func (p *Provider) lockoutFor(excess int) time.Duration {
+	lockout := p.config.LoginLockout
+	for i := 0; i < excess && lockout < p.config.LoginMaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > p.config.LoginMaxLockout {
+		lockout = p.config.LoginMaxLockout
+	}
+	return lockout
+}
+
+// clientIP returns the IP address of the request's remote end without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/example/goframe/db"
+)
+
+var (
+	// ErrInvalidMagicLink is returned for unknown, expired, tampered or already used login links
+	ErrInvalidMagicLink = errors.New("invalid login link")
+
+	// ErrMagicLinkBrowser is returned when a login link is opened in a browser
+	// other than the one that requested it
+	ErrMagicLinkBrowser = errors.New("login link was requested from another browser")
+)
+
+// magicLinkPath is where emailed login links point
+const magicLinkPath = "/login/magic/verify"
+
+// MagicLinkCookie holds the browser nonce a login link is bound to
+const MagicLinkCookie = "magic_link_nonce"
+
+// MagicLinkHeader carries the browser nonce for clients without cookies
+const MagicLinkHeader = "X-Magic-Link-Nonce"
+
+// magicLink is an outstanding login link
+type magicLink struct {
+	UserID      uint      `db:"user_id"`
+	BrowserHash string    `db:"browser_hash"`
+	Redirect    string    `db:"redirect"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// MagicLinkRepository stores hashed login link tokens in magic_links
+type MagicLinkRepository struct {
+	db    *db.Database
+	table string
+}
+
+// NewMagicLinkRepository creates a new magic link repository
+func NewMagicLinkRepository(database *db.Database) *MagicLinkRepository {
+	return &MagicLinkRepository{
+		db:    database,
+		table: "magic_links",
+	}
+}
+
+// Create replaces any outstanding link for the user and returns the new
+// plain-text token. browserHash binds the link to the requesting browser.
+func (r *MagicLinkRepository) Create(userID uint, browserHash, redirect string, expiresAt time.Time) (string, error) {
+	plain, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.DeleteForUser(userID); err != nil {
+		return "", err
+	}
+
+	err = db.NewQueryBuilder(r.db, r.table).Insert(map[string]interface{}{
+		"user_id":      userID,
+		"token_hash":   hashToken(plain),
+		"browser_hash": browserHash,
+		"redirect":     redirect,
+		"expires_at":   expiresAt,
+		"created_at":   time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
+
+// find returns the link for plain
+func (r *MagicLinkRepository) find(plain string) (*magicLink, error) {
+	var links []magicLink
+	err := db.NewQueryBuilder(r.db, r.table).
+		Select("user_id", "browser_hash", "redirect", "expires_at").
+		Where("token_hash", "=", hashToken(plain)).
+		Get(&links)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, ErrInvalidMagicLink
+	}
+	return &links[0], nil
+}
+
+// consume deletes the link for plain if it is still bound to browserHash and
+// unexpired at now, and reports whether it was. The check and the delete are
+// one statement, so of two concurrent callers with the same link only one wins.
+func (r *MagicLinkRepository) consume(plain, browserHash string, now time.Time) (bool, error) {
+	deleted, err := db.NewQueryBuilder(r.db, r.table).
+		Where("token_hash", "=", hashToken(plain)).
+		Where("browser_hash", "=", browserHash).
+		Where("expires_at", ">", now).
+		DeleteCount()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// DeleteForUser removes every outstanding link for the user
+func (r *MagicLinkRepository) DeleteForUser(userID uint) error {
+	return db.NewQueryBuilder(r.db, r.table).Where("user_id", "=", userID).Delete()
+}
+
+// NewMagicLinkNonce returns a random value identifying the browser that
+// requests a login link. It is stored in a cookie and only its hash is
+// saved with the link.
+func NewMagicLinkNonce() (string, error) {
+	return randomToken(32)
+}
+
+// CreateMagicLink creates a single-use login link for the user, bound to the
+// browser holding nonce, and returns its signed URL. redirect is where the
+// user lands after logging in. The caller is responsible for delivering it.
+func (p *Provider) CreateMagicLink(user *UserModel, nonce, redirect string) (string, error) {
+	expiresAt := p.now().Add(p.config.MagicLinkExpire)
+	token, err := p.magicLinks.Create(user.ID, hashToken(nonce), redirect, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return p.SignedURL(magicLinkPath, url.Values{"token": {token}}, expiresAt), nil
+}
+
+// ConsumeMagicLink validates the login link in r, opened by the browser
+// holding nonce, and deletes it. It returns the link's user and redirect.
+// A link opened in the wrong browser is left intact for the right one.
+func (p *Provider) ConsumeMagicLink(r *http.Request, nonce string) (*UserModel, string, error) {
+	if !p.HasValidSignature(r) {
+		return nil, "", ErrInvalidMagicLink
+	}
+
+	token := r.URL.Query().Get("token")
+	link, err := p.magicLinks.find(token)
+	if err != nil {
+		return nil, "", ErrInvalidMagicLink
+	}
+	if !p.now().Before(link.ExpiresAt) {
+		return nil, "", ErrInvalidMagicLink
+	}
+
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(hashToken(nonce)), []byte(link.BrowserHash)) != 1 {
+		return nil, "", ErrMagicLinkBrowser
+	}
+
+	// Another request may have used the link since it was read
+	consumed, err := p.magicLinks.consume(token, link.BrowserHash, p.now())
+	if err != nil {
+		return nil, "", err
+	}
+	if !consumed {
+		return nil, "", ErrInvalidMagicLink
+	}
+
+	user, err := p.GetUserByID(r.Context(), link.UserID)
+	if err != nil {
+		return nil, "", ErrInvalidMagicLink
+	}
+	return user, link.Redirect, nil
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/goframe/mail"
+)
+
+// MagicLinkRequest asks for a login link to be emailed
+type MagicLinkRequest struct {
+	Email    string `json:"email"`
+	Redirect string `json:"redirect"`
+}
+
+// RequestMagicLink emails a single-use login link. The link only works in the
+// browser that asked for it: that browser gets a nonce cookie, and JSON
+// clients also receive the nonce to send back in the X-Magic-Link-Nonce
+// header. The response is the same whether or not the address belongs to an
+// account, so it cannot be used to probe emails.
+func (c *Controller) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req MagicLinkRequest
+	form := isFormPost(r)
+	if form {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		req.Email = r.PostFormValue("email")
+		req.Redirect = r.PostFormValue("redirect")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+	redirect := SafeRedirect(req.Redirect, "/dashboard")
+
+	// Throttle per address regardless of whether the account exists
+	key := "magic-link:" + strings.ToLower(strings.TrimSpace(req.Email))
+	if c.limiter.TooManyAttempts(key, 1) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(c.limiter.AvailableIn(key).Seconds())+1))
+		http.Error(w, "Too many login link requests", http.StatusTooManyRequests)
+		return
+	}
+	c.limiter.Hit(key, c.provider.Config().MagicLinkThrottle)
+
+	nonce, err := NewMagicLinkNonce()
+	if err != nil {
+		http.Error(w, "Failed to create login link", http.StatusInternalServerError)
+		return
+	}
+
+	if user, err := c.repo.FindByEmailContext(r.Context(), req.Email); err == nil {
+		link, err := c.provider.CreateMagicLink(user, nonce, redirect)
+		if err != nil {
+			http.Error(w, "Failed to create login link", http.StatusInternalServerError)
+			return
+		}
+
+		err = c.mailer.Send(mail.Message{
+			To:      user.Email,
+			Subject: "Your login link",
+			Body: fmt.Sprintf("Hello %s,\n\nUse the link below to log in. It expires in %s, works once, and only in the browser you requested it from.\n\n%s\n\nIf you did not request a login link, you can ignore this email.\n",
+				user.Name, c.provider.Config().MagicLinkExpire, link),
+		})
+		if err != nil {
+			http.Error(w, "Failed to send login link", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     MagicLinkCookie,
+		Value:    nonce,
+		Path:     "/login/magic",
+		MaxAge:   int(c.provider.Config().MagicLinkExpire.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if form {
+		http.Redirect(w, r, "/login?magic=sent", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If that email address is registered, a login link has been sent.",
+		"nonce":   nonce,
+	})
+}
+
+// MagicLinkLogin redeems a login link. Browsers are logged into a session and
+// redirected; JSON clients receive a token pair. Users with 2FA enabled are
+// sent to the two-factor challenge first.
+func (c *Controller) MagicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	form := !wantsJSON(r)
+
+	nonce := r.Header.Get(MagicLinkHeader)
+	if cookie, err := r.Cookie(MagicLinkCookie); err == nil {
+		nonce = cookie.Value
+	}
+
+	user, redirect, err := c.provider.ConsumeMagicLink(r, nonce)
+	if err != nil {
+		if errors.Is(err, ErrMagicLinkBrowser) || errors.Is(err, ErrInvalidMagicLink) {
+			c.provider.events.Dispatch(LoginFailed{Reason: "invalid_magic_link", RequestInfo: requestInfo(r)})
+		}
+		switch {
+		case form:
+			http.Redirect(w, r, "/login?error=magic", http.StatusSeeOther)
+		case errors.Is(err, ErrMagicLinkBrowser):
+			http.Error(w, "Open the login link in the browser that requested it", http.StatusForbidden)
+		case errors.Is(err, ErrInvalidMagicLink):
+			http.Error(w, "Invalid or expired login link", http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: MagicLinkCookie, Path: "/login/magic", MaxAge: -1})
+
+	// Following the link proves the user controls the address
+	if !user.HasVerifiedEmail() {
+		if err := c.repo.MarkEmailVerified(user); err != nil {
+			http.Error(w, "Failed to log in", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if user.HasTwoFactorEnabled() {
+		c.startTwoFactorChallenge(w, r, user, form, redirect)
+		return
+	}
+
+	c.completeLogin(w, r, user, "magic_link", form, redirect)
+}
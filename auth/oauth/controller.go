@@ -0,0 +1,177 @@
+package oauth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/example/goframe/auth"
+	"github.com/example/goframe/session"
+)
+
+// pendingKey is the session key holding the in-flight authorization request
+const pendingKey = "oauth.pending"
+
+// pendingLifetime bounds how long a user may take at the provider
+const pendingLifetime = 10 * time.Minute
+
+// pendingLogin is what the redirect remembers for the callback to check
+type pendingLogin struct {
+	Provider  string    `json:"provider"`
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"`
+	Redirect  string    `json:"redirect"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Controller handles the redirect to and callback from identity providers
+type Controller struct {
+	providers map[string]*Provider
+	resolver  *Resolver
+	auth      *auth.Provider
+	logins    *auth.Controller
+}
+
+// NewController creates a controller that signs users in through logins
+func NewController(authProvider *auth.Provider, logins *auth.Controller, resolver *Resolver) *Controller {
+	return &Controller{
+		providers: make(map[string]*Provider),
+		resolver:  resolver,
+		auth:      authProvider,
+		logins:    logins,
+	}
+}
+
+// Register makes provider available for login
+func (c *Controller) Register(provider *Provider) {
+	c.providers[provider.Name()] = provider
+}
+
+// Providers returns the names of the registered providers, sorted
+func (c *Controller) Providers() []string {
+	names := make([]string, 0, len(c.providers))
+	for name := range c.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Redirect returns a handler that sends the browser to the named provider.
+// A ?redirect= path is where the user lands after signing in. Users who are
+// already logged in link the external account to themselves.
+func (c *Controller) Redirect(name string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := c.providers[name]
+		s := session.FromRequest(r)
+		if !ok || s == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		pending := pendingLogin{
+			Provider:  name,
+			Redirect:  auth.SafeRedirect(r.URL.Query().Get("redirect"), "/dashboard"),
+			StartedAt: time.Now(),
+		}
+
+		var err error
+		var challenge string
+		if pending.State, err = RandomString(32); err == nil {
+			if pending.Nonce, err = RandomString(32); err == nil {
+				pending.Verifier, challenge, err = NewPKCE()
+			}
+		}
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+
+		target, err := provider.AuthCodeURL(r.Context(), pending.State, pending.Nonce, challenge)
+		if err != nil {
+			log.Printf("OAuth provider %s unavailable: %v", name, err)
+			http.Error(w, "Login provider unavailable", http.StatusBadGateway)
+			return
+		}
+
+		encoded, _ := json.Marshal(pending)
+		s.Put(pendingKey, string(encoded))
+
+		http.Redirect(w, r, target, http.StatusFound)
+	}
+}
+
+// Callback returns a handler for the named provider's redirect back to us. It
+// checks the state, exchanges the code with the PKCE verifier, verifies the
+// identity and logs in the linked user, creating one on first login.
+func (c *Controller) Callback(name string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := c.providers[name]
+		s := session.FromRequest(r)
+		if !ok || s == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		// The pending login is single use, whatever the outcome
+		var pending pendingLogin
+		raw, _ := s.Pull(pendingKey).(string)
+		if json.Unmarshal([]byte(raw), &pending) != nil || pending.Provider != name ||
+			time.Since(pending.StartedAt) > pendingLifetime {
+			c.fail(w, r, ErrInvalidState)
+			return
+		}
+
+		query := r.URL.Query()
+		if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(pending.State)) != 1 {
+			c.fail(w, r, ErrInvalidState)
+			return
+		}
+
+		// The user declined, or the provider refused the request
+		if query.Get("error") != "" || query.Get("code") == "" {
+			c.fail(w, r, nil)
+			return
+		}
+
+		token, err := provider.Exchange(r.Context(), query.Get("code"), pending.Verifier)
+		if err != nil {
+			c.fail(w, r, err)
+			return
+		}
+
+		identity, err := provider.Identity(r.Context(), token, pending.Nonce)
+		if err != nil {
+			c.fail(w, r, err)
+			return
+		}
+
+		current := c.auth.SessionUser(r)
+		user, err := c.resolver.Resolve(identity, current)
+		if err != nil {
+			c.fail(w, r, err)
+			return
+		}
+
+		// Linking an account to the signed-in user needs no new login
+		if current != nil {
+			http.Redirect(w, r, pending.Redirect, http.StatusSeeOther)
+			return
+		}
+
+		c.logins.LoginUser(w, r, user, "oauth:"+name, pending.Redirect)
+	}
+}
+
+// fail logs err and sends the browser back to the login page
+func (c *Controller) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		log.Printf("OAuth login failed: %v", err)
+	}
+	http.Redirect(w, r, "/login?"+url.Values{"error": {"oauth"}}.Encode(), http.StatusSeeOther)
+}
@@ -0,0 +1,314 @@
+// Package oauth signs users in with external identity providers using the
+// OAuth 2.0 authorization-code flow with PKCE. OpenID Connect providers are
+// configured from their discovery document and their ID tokens are verified
+// against the provider's published keys.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidState is returned when a callback's state does not match the one sent
+	ErrInvalidState = errors.New("oauth: invalid state")
+
+	// ErrInvalidIDToken is returned when an ID token fails verification
+	ErrInvalidIDToken = errors.New("oauth: invalid id token")
+)
+
+// Config describes one identity provider
+type Config struct {
+	Name         string // Used in routes and stored with linked accounts
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// Issuer enables OpenID Connect: endpoints are discovered from
+	// Issuer + "/.well-known/openid-configuration" and ID tokens are verified
+	Issuer string
+
+	// Endpoints for plain OAuth 2.0 providers, or overrides of discovered ones
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+
+	// FetchIdentity loads the user's profile for providers whose user info
+	// response is not standard OIDC claims
+	FetchIdentity func(ctx context.Context, client *http.Client, token *Token) (*Identity, error)
+
+	HTTPClient *http.Client     // Defaults to a client with a 10 second timeout
+	Now        func() time.Time // Defaults to time.Now; used to check ID token expiry
+}
+
+// Token is the response from a provider's token endpoint
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+	Scope        string `json:"scope"`
+}
+
+// Identity is the user as described by an identity provider
+type Identity struct {
+	Provider      string
+	Subject       string // The provider's stable ID for the user
+	Email         string
+	EmailVerified bool
+	Name          string
+	AvatarURL     string
+}
+
+// Provider runs the authorization-code flow against one identity provider
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     *keyCache
+}
+
+// New creates a provider. Discovery for OIDC providers happens on first use,
+// so an unreachable provider does not stop the application from starting.
+func New(cfg Config) (*Provider, error) {
+	if cfg.Name == "" || cfg.ClientID == "" {
+		return nil, errors.New("oauth: name and client ID are required")
+	}
+	if cfg.Issuer == "" && (cfg.AuthURL == "" || cfg.TokenURL == "") {
+		return nil, fmt.Errorf("oauth: provider %s needs an issuer or auth and token URLs", cfg.Name)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Provider{config: cfg, client: cfg.HTTPClient}, nil
+}
+
+// Name returns the provider's configured name
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// IsOIDC reports whether the provider issues verifiable ID tokens
+func (p *Provider) IsOIDC() bool {
+	return p.config.Issuer != ""
+}
+
+// endpoints returns the provider's auth, token and user info URLs,
+// discovering them first for OIDC providers
+func (p *Provider) endpoints(ctx context.Context) (authURL, tokenURL, userInfoURL string, err error) {
+	authURL, tokenURL, userInfoURL = p.config.AuthURL, p.config.TokenURL, p.config.UserInfoURL
+	if !p.IsOIDC() {
+		return authURL, tokenURL, userInfoURL, nil
+	}
+
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", "", "", err
+	}
+	if authURL == "" {
+		authURL = metadata.AuthorizationEndpoint
+	}
+	if tokenURL == "" {
+		tokenURL = metadata.TokenEndpoint
+	}
+	if userInfoURL == "" {
+		userInfoURL = metadata.UserInfoEndpoint
+	}
+	return authURL, tokenURL, userInfoURL, nil
+}
+
+// AuthCodeURL returns the URL to send the browser to. state and nonce must be
+// random and remembered for the callback; challenge comes from NewPKCE.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	authURL, _, _, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	if p.IsOIDC() {
+		params.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(authURL, "?") {
+		separator = "&"
+	}
+	return authURL + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code and its PKCE verifier for tokens
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	_, tokenURL, _, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token Token
+	if err := p.do(req, &token); err != nil {
+		return nil, fmt.Errorf("oauth: token exchange failed: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("oauth: token response has no access token")
+	}
+	return &token, nil
+}
+
+// Identity returns the signed-in user. For OIDC providers the ID token is
+// verified, including its nonce, and user info fills in missing claims.
+func (p *Provider) Identity(ctx context.Context, token *Token, nonce string) (*Identity, error) {
+	if p.config.FetchIdentity != nil {
+		identity, err := p.config.FetchIdentity(ctx, p.client, token)
+		if err != nil {
+			return nil, err
+		}
+		identity.Provider = p.config.Name
+		return identity, nil
+	}
+
+	var claims *IDClaims
+	if p.IsOIDC() {
+		if token.IDToken == "" {
+			return nil, ErrInvalidIDToken
+		}
+		var err error
+		if claims, err = p.VerifyIDToken(ctx, token.IDToken, nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	if claims == nil || claims.Email == "" {
+		info, err := p.userInfo(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		// The ID token is authoritative for who the user is
+		if claims != nil && info.Subject != claims.Subject {
+			return nil, ErrInvalidIDToken
+		}
+		claims = info
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("oauth: provider returned no subject")
+	}
+
+	return &Identity{
+		Provider:      p.config.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		AvatarURL:     claims.Picture,
+	}, nil
+}
+
+// userInfo fetches standard OIDC claims from the user info endpoint
+func (p *Provider) userInfo(ctx context.Context, token *Token) (*IDClaims, error) {
+	_, _, userInfoURL, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if userInfoURL == "" {
+		return nil, errors.New("oauth: provider has no user info endpoint")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, userInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var claims IDClaims
+	if err := p.do(req, &claims); err != nil {
+		return nil, fmt.Errorf("oauth: user info request failed: %w", err)
+	}
+	return &claims, nil
+}
+
+// do sends req and decodes a JSON response into dest
+func (p *Provider) do(req *http.Request, dest interface{}) error {
+	return doJSON(p.client, req, dest)
+}
+
+// doJSON sends req with client and decodes a JSON response into dest
+func doJSON(client *http.Client, req *http.Request, dest interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.Unmarshal(body, dest)
+}
+
+// NewPKCE returns a random code verifier and its S256 challenge (RFC 7636)
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns a URL-safe random string built from n bytes of entropy,
+// for use as a state or nonce
+func RandomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,255 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Metadata is the subset of an OpenID Provider's discovery document we use
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgorithms     []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// Discover fetches an issuer's OpenID Connect discovery document. The issuer
+// in the document must match the one requested.
+func Discover(ctx context.Context, client *http.Client, issuer string) (*Metadata, error) {
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var metadata Metadata
+	if err := doJSON(client, req, &metadata); err != nil {
+		return nil, fmt.Errorf("oauth: discovery failed: %w", err)
+	}
+
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, fmt.Errorf("oauth: discovery returned issuer %q, expected %q", metadata.Issuer, issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oauth: discovery document is missing required endpoints")
+	}
+	return &metadata, nil
+}
+
+// discover loads and caches the provider's metadata. Failures are not cached,
+// so a provider that was briefly unreachable recovers on the next login.
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata, err := Discover(ctx, p.client, p.config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	p.metadata = metadata
+	p.keys = &keyCache{client: p.client, url: metadata.JWKSURI}
+	return metadata, nil
+}
+
+// boolClaim accepts both true and "true", since some providers send
+// email_verified as a string
+type boolClaim bool
+
+func (b *boolClaim) UnmarshalJSON(data []byte) error {
+	*b = boolClaim(strings.Trim(string(data), `"`) == "true")
+	return nil
+}
+
+// IDClaims are the ID token and user info claims we read
+type IDClaims struct {
+	Nonce           string    `json:"nonce"`
+	AuthorizedParty string    `json:"azp"`
+	Email           string    `json:"email"`
+	EmailVerified   boolClaim `json:"email_verified"`
+	Name            string    `json:"name"`
+	Picture         string    `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// VerifyIDToken checks an ID token's signature against the issuer's keys and
+// validates its issuer, audience, expiry and nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*IDClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := metadata.SigningAlgorithms
+	if len(allowed) == 0 {
+		allowed = []string{"RS256"}
+	}
+
+	claims := &IDClaims{}
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation(), jwt.WithValidMethods(allowed))
+	_, err = parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		// Symmetric algorithms would make the client secret a signing key
+		if strings.HasPrefix(token.Method.Alg(), "HS") {
+			return nil, errors.New("unexpected signing method")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.get(ctx, kid)
+	})
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	now := p.config.Now()
+	switch {
+	case !claims.VerifyIssuer(metadata.Issuer, true):
+	case !claims.VerifyAudience(p.config.ClientID, true):
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID:
+	case !claims.VerifyExpiresAt(now, true):
+	case !claims.VerifyIssuedAt(now.Add(time.Minute), false):
+	case nonce == "" || claims.Nonce != nonce:
+	default:
+		return claims, nil
+	}
+	return nil, ErrInvalidIDToken
+}
+
+// keyCache holds an issuer's JSON Web Key Set. Unknown key IDs trigger a
+// refetch, at most once a minute, so provider key rotation is picked up.
+type keyCache struct {
+	client *http.Client
+	url    string
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func (c *keyCache) get(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(c.fetchedAt) < time.Minute {
+		return nil, errors.New("unknown signing key")
+	}
+
+	keys, err := fetchJWKS(ctx, c.client, c.url)
+	c.fetchedAt = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	c.keys = keys
+
+	// Providers with a single key sometimes omit kid from tokens
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// jsonWebKey is a public key in JWK form (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchJWKS downloads a key set and parses its signing keys by kid
+func fetchJWKS(ctx context.Context, client *http.Client, url string) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := doJSON(client, req, &set); err != nil {
+		return nil, fmt.Errorf("oauth: fetching keys failed: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey converts the JWK to a Go public key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
@@ -0,0 +1,467 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/goframe/session"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testClientID = "client-123"
+	testKeyID    = "test-key"
+	testCode     = "auth-code"
+	testNonce    = "nonce-abc"
+)
+
+// fakeIssuer is a minimal OpenID Provider: discovery, keys, a token endpoint
+// that checks the code and PKCE verifier, and user info
+type fakeIssuer struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	// issuer overrides the issuer advertised in the discovery document
+	issuer string
+	// challenge is the PKCE challenge the token endpoint expects
+	challenge string
+	// claims are signed into the ID token returned by the token endpoint
+	claims jwt.MapClaims
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeIssuer{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", f.discovery)
+	mux.HandleFunc("/jwks", f.jwks)
+	mux.HandleFunc("/token", f.token)
+	mux.HandleFunc("/userinfo", f.userInfo)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+
+	f.claims = f.validClaims()
+	return f
+}
+
+func (f *fakeIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := f.URL
+	if f.issuer != "" {
+		issuer = f.issuer
+	}
+	writeJSON(w, http.StatusOK, Metadata{
+		Issuer:                issuer,
+		AuthorizationEndpoint: f.URL + "/authorize",
+		TokenEndpoint:         f.URL + "/token",
+		UserInfoEndpoint:      f.URL + "/userinfo",
+		JWKSURI:               f.URL + "/jwks",
+		SigningAlgorithms:     []string{"RS256"},
+	})
+}
+
+func (f *fakeIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := f.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []jsonWebKey{{
+			Kty: "RSA",
+			Kid: testKeyID,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (f *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code",
+		r.PostForm.Get("client_id") != testClientID,
+		r.PostForm.Get("code") != testCode,
+		base64.RawURLEncoding.EncodeToString(sum[:]) != f.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Token{
+		AccessToken: "access-token",
+		TokenType:   "Bearer",
+		IDToken:     f.sign(jwt.SigningMethodRS256, f.claims),
+	})
+}
+
+func (f *fakeIssuer) userInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":   "user-1",
+		"email": "ada@example.com",
+		"name":  "Ada",
+	})
+}
+
+// validClaims returns ID token claims that pass every check
+func (f *fakeIssuer) validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            f.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testNonce,
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"name":           "Ada",
+	}
+}
+
+// sign returns claims as a compact JWT signed with the issuer's key
+func (f *fakeIssuer) sign(method jwt.SigningMethod, claims jwt.MapClaims) string {
+	f.t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = testKeyID
+
+	var key interface{} = f.key
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		key = []byte("client-secret")
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return signed
+}
+
+// provider returns an OIDC provider configured against the fake issuer
+func (f *fakeIssuer) provider() *Provider {
+	f.t.Helper()
+
+	p, err := New(Config{
+		Name:         "fake",
+		ClientID:     testClientID,
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://app.test/auth/fake/callback",
+		Scopes:       []string{"openid", "email"},
+		Issuer:       f.URL,
+		HTTPClient:   f.Client(),
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestDiscover(t *testing.T) {
+	f := newFakeIssuer(t)
+
+	metadata, err := Discover(context.Background(), f.Client(), f.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.TokenEndpoint != f.URL+"/token" || metadata.JWKSURI != f.URL+"/jwks" {
+		t.Errorf("unexpected endpoints: %+v", metadata)
+	}
+
+	// A trailing slash on the configured issuer is not a mismatch
+	if _, err := Discover(context.Background(), f.Client(), f.URL+"/"); err != nil {
+		t.Errorf("issuer with trailing slash: %v", err)
+	}
+}
+
+func TestDiscoverRejectsWrongIssuer(t *testing.T) {
+	f := newFakeIssuer(t)
+	f.issuer = "https://evil.example.com"
+
+	if _, err := Discover(context.Background(), f.Client(), f.URL); err == nil {
+		t.Fatal("discovery document for another issuer accepted")
+	}
+
+	// The provider refuses to start a login against it either
+	if _, err := f.provider().AuthCodeURL(context.Background(), "state", testNonce, "challenge"); err == nil {
+		t.Error("AuthCodeURL succeeded with a mismatched issuer")
+	}
+}
+
+func TestAuthCodeURLUsesDiscoveredEndpoint(t *testing.T) {
+	f := newFakeIssuer(t)
+
+	target, err := f.provider().AuthCodeURL(context.Background(), "state-1", testNonce, "challenge-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != f.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %s, want %s", got, f.URL+"/authorize")
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"state":                 "state-1",
+		"nonce":                 testNonce,
+		"code_challenge":        "challenge-1",
+		"code_challenge_method": "S256",
+		"scope":                 "openid email",
+	}
+	for key, value := range want {
+		if got := u.Query().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestExchangeAndIdentity(t *testing.T) {
+	f := newFakeIssuer(t)
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.challenge = challenge
+
+	p := f.provider()
+	token, err := p.Exchange(context.Background(), testCode, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access-token" || token.IDToken == "" {
+		t.Fatalf("unexpected token response: %+v", token)
+	}
+
+	identity, err := p.Identity(context.Background(), token, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Provider != "fake" || identity.Subject != "user-1" ||
+		identity.Email != "ada@example.com" || !identity.EmailVerified {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+}
+
+func TestIdentityFallsBackToUserInfo(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := f.provider()
+
+	claims := f.validClaims()
+	delete(claims, "email")
+	token := &Token{AccessToken: "access-token", IDToken: f.sign(jwt.SigningMethodRS256, claims)}
+
+	identity, err := p.Identity(context.Background(), token, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Email != "ada@example.com" {
+		t.Errorf("Email = %q, want the user info email", identity.Email)
+	}
+
+	// User info for someone else than the ID token's subject is refused
+	claims["sub"] = "user-2"
+	token.IDToken = f.sign(jwt.SigningMethodRS256, claims)
+	if _, err := p.Identity(context.Background(), token, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("mismatched user info subject: err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	f := newFakeIssuer(t)
+	_, challenge, _ := NewPKCE()
+	f.challenge = challenge
+
+	other, _, _ := NewPKCE()
+	if _, err := f.provider().Exchange(context.Background(), testCode, other); err == nil {
+		t.Fatal("exchange with the wrong PKCE verifier succeeded")
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	f := newFakeIssuer(t)
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		method jwt.SigningMethod
+		nonce  string
+	}{
+		{name: "wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "several audiences without azp", modify: func(c jwt.MapClaims) { c["aud"] = []string{testClientID, "someone-else"} }},
+		{name: "bad nonce", nonce: "other-nonce"},
+		{name: "missing nonce", modify: func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{name: "expired", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{name: "issued in the future", modify: func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{name: "signed with the client secret", method: jwt.SigningMethodHS256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := f.validClaims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			method, nonce := tt.method, tt.nonce
+			if method == nil {
+				method = jwt.SigningMethodRS256
+			}
+			if nonce == "" {
+				nonce = testNonce
+			}
+
+			_, err := f.provider().VerifyIDToken(context.Background(), f.sign(method, claims), nonce)
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("err = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsUnknownKey(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := f.provider()
+
+	// Same claims and kid, but a key the issuer never published
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, f.validClaims())
+	token.Header["kid"] = testKeyID
+	raw, err := token.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.VerifyIDToken(context.Background(), raw, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestVerifyIDTokenUsesConfiguredClock(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := f.provider()
+	raw := f.sign(jwt.SigningMethodRS256, f.validClaims())
+
+	if _, err := p.VerifyIDToken(context.Background(), raw, testNonce); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	p.config.Now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := p.VerifyIDToken(context.Background(), raw, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("token accepted after expiry: err = %v", err)
+	}
+}
+
+// startLogin runs the redirect handler and returns the session cookie and
+// the state sent to the provider
+func startLogin(t *testing.T, handler http.Handler) (*http.Cookie, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/fake/redirect", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("redirect status = %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("redirect set no session cookie")
+	}
+	return cookies[0], location.Query().Get("state")
+}
+
+func TestCallbackRejectsBadState(t *testing.T) {
+	f := newFakeIssuer(t)
+
+	// A bad state fails before the auth provider or resolver are needed
+	controller := NewController(nil, nil, nil)
+	controller.Register(f.provider())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/fake/redirect", controller.Redirect("fake"))
+	mux.HandleFunc("/auth/fake/callback", controller.Callback("fake"))
+	handler := session.NewManager(session.NewMemoryStore(), session.Config{}).Middleware()(mux)
+
+	callback := func(cookie *http.Cookie, state string) *httptest.ResponseRecorder {
+		query := url.Values{"code": {testCode}, "state": {state}}
+		req := httptest.NewRequest(http.MethodGet, "/auth/fake/callback?"+query.Encode(), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	isLoginRedirect := func(rec *httptest.ResponseRecorder) bool {
+		return rec.Code == http.StatusSeeOther && strings.HasPrefix(rec.Header().Get("Location"), "/login?error=oauth")
+	}
+
+	tests := []struct {
+		name  string
+		login bool
+		state func(sent string) string
+	}{
+		{"wrong state", true, func(sent string) string { return sent + "x" }},
+		{"empty state", true, func(string) string { return "" }},
+		{"no pending login", false, func(sent string) string { return sent }},
+	}
+	for _, tt := range tests {
+		cookie, sent := startLogin(t, handler)
+		if sent == "" {
+			t.Fatal("no state sent to the provider")
+		}
+		if !tt.login {
+			cookie = nil
+		}
+		if rec := callback(cookie, tt.state(sent)); !isLoginRedirect(rec) {
+			t.Errorf("%s: got %d to %q, want a redirect to the login page", tt.name, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+
+	// The pending login is single use, so after a failed callback even the
+	// right state is refused
+	cookie, sent := startLogin(t, handler)
+	callback(cookie, sent+"x")
+	if rec := callback(cookie, sent); !isLoginRedirect(rec) {
+		t.Errorf("state reused after a failed callback: got %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,119 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Presets returns the built-in provider configurations by name. Only the
+// client credentials and redirect URL need to be added.
+func Presets() map[string]Config {
+	return map[string]Config{
+		"google": Google(),
+		"github": GitHub(),
+		"gitlab": GitLab(),
+	}
+}
+
+// Google signs in with Google accounts over OpenID Connect
+func Google() Config {
+	return Config{
+		Name:   "google",
+		Issuer: "https://accounts.google.com",
+		Scopes: []string{"openid", "email", "profile"},
+	}
+}
+
+// GitLab signs in with GitLab.com accounts over OpenID Connect. Self-hosted
+// instances can use the same preset with their own Issuer.
+func GitLab() Config {
+	return Config{
+		Name:   "gitlab",
+		Issuer: "https://gitlab.com",
+		Scopes: []string{"openid", "email", "profile"},
+	}
+}
+
+// Microsoft signs in with Microsoft Entra ID accounts from one tenant. The
+// tenant must be a directory ID or domain; "common" cannot be used because its
+// tokens are issued by each user's home tenant.
+func Microsoft(tenant string) Config {
+	return Config{
+		Name:   "microsoft",
+		Issuer: "https://login.microsoftonline.com/" + tenant + "/v2.0",
+		Scopes: []string{"openid", "email", "profile"},
+	}
+}
+
+// GitHub signs in with GitHub accounts. GitHub does not support OpenID
+// Connect, so the profile and verified email are read from its REST API.
+func GitHub() Config {
+	return Config{
+		Name:          "github",
+		AuthURL:       "https://github.com/login/oauth/authorize",
+		TokenURL:      "https://github.com/login/oauth/access_token",
+		UserInfoURL:   "https://api.github.com/user",
+		Scopes:        []string{"read:user", "user:email"},
+		FetchIdentity: fetchGitHubIdentity("https://api.github.com"),
+	}
+}
+
+// fetchGitHubIdentity reads the user and their primary verified email from
+// the GitHub API at baseURL
+func fetchGitHubIdentity(baseURL string) func(context.Context, *http.Client, *Token) (*Identity, error) {
+	return func(ctx context.Context, client *http.Client, token *Token) (*Identity, error) {
+		get := func(path string, dest interface{}) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+			req.Header.Set("Accept", "application/vnd.github+json")
+			return doJSON(client, req, dest)
+		}
+
+		var user struct {
+			ID        int64  `json:"id"`
+			Login     string `json:"login"`
+			Name      string `json:"name"`
+			AvatarURL string `json:"avatar_url"`
+		}
+		if err := get("/user", &user); err != nil {
+			return nil, err
+		}
+		if user.ID == 0 {
+			return nil, errors.New("oauth: provider returned no subject")
+		}
+
+		identity := &Identity{
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Name:      user.Name,
+			AvatarURL: user.AvatarURL,
+		}
+		if identity.Name == "" {
+			identity.Name = user.Login
+		}
+
+		// The public profile email is not necessarily verified, so always
+		// use the primary address from the emails endpoint
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := get("/user/emails", &emails); err != nil {
+			return nil, err
+		}
+		for _, e := range emails {
+			if e.Primary {
+				identity.Email = e.Email
+				identity.EmailVerified = e.Verified
+				break
+			}
+		}
+
+		return identity, nil
+	}
+}
@@ -0,0 +1,187 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/example/goframe/auth"
+	"github.com/example/goframe/db"
+)
+
+var (
+	// ErrAccountLinked is returned when an external account already belongs to another user
+	ErrAccountLinked = errors.New("oauth: account is linked to another user")
+
+	// ErrEmailUnverified is returned when an unverified provider email matches
+	// an existing user, who must log in and link the account themselves
+	ErrEmailUnverified = errors.New("oauth: email belongs to an existing user but is not verified by the provider")
+
+	// ErrNoEmail is returned when a new user cannot be created without an email
+	ErrNoEmail = errors.New("oauth: provider did not return an email address")
+)
+
+// SocialAccount links a user to their account at an external identity provider
+type SocialAccount struct {
+	db.Entity
+	UserID         uint   `db:"user_id" json:"user_id"`
+	Provider       string `db:"provider" json:"provider"`
+	ProviderUserID string `db:"provider_user_id" json:"provider_user_id"`
+	Email          string `db:"email" json:"email"`
+	Name           string `db:"name" json:"name"`
+	AvatarURL      string `db:"avatar_url" json:"avatar_url"`
+}
+
+// TableName returns the table name for the model
+func (SocialAccount) TableName() string {
+	return "social_accounts"
+}
+
+// SocialAccountRepository provides methods to interact with linked accounts
+type SocialAccountRepository struct {
+	db   *db.Database
+	repo *db.Repository[SocialAccount]
+}
+
+// NewSocialAccountRepository creates a new social account repository
+func NewSocialAccountRepository(database *db.Database) *SocialAccountRepository {
+	return &SocialAccountRepository{
+		db:   database,
+		repo: db.NewRepository[SocialAccount](database),
+	}
+}
+
+// Find returns the account linked to provider's user subject, or nil if there is none
+func (r *SocialAccountRepository) Find(provider, subject string) (*SocialAccount, error) {
+	var accounts []SocialAccount
+	if err := r.repo.FindAll(&accounts, "provider = ? AND provider_user_id = ?", provider, subject); err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+	return &accounts[0], nil
+}
+
+// ForUser lists the external accounts linked to a user
+func (r *SocialAccountRepository) ForUser(userID uint) ([]SocialAccount, error) {
+	var accounts []SocialAccount
+	if err := r.repo.FindAll(&accounts, "user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// Link records that identity belongs to the user
+func (r *SocialAccountRepository) Link(userID uint, identity *Identity) (*SocialAccount, error) {
+	account := &SocialAccount{
+		UserID:         userID,
+		Provider:       identity.Provider,
+		ProviderUserID: identity.Subject,
+		Email:          identity.Email,
+		Name:           identity.Name,
+		AvatarURL:      identity.AvatarURL,
+	}
+	if err := r.repo.Create(account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Unlink removes one of the user's linked accounts
+func (r *SocialAccountRepository) Unlink(userID uint, provider string) error {
+	return db.NewQueryBuilder(r.db, SocialAccount{}.TableName()).
+		Where("user_id", "=", userID).
+		Where("provider", "=", provider).
+		Delete()
+}
+
+// Resolver finds or creates the local user for an external identity
+type Resolver struct {
+	accounts *SocialAccountRepository
+	users    *auth.UserRepository
+}
+
+// NewResolver creates a resolver that links identities to users in users
+func NewResolver(accounts *SocialAccountRepository, users *auth.UserRepository) *Resolver {
+	return &Resolver{accounts: accounts, users: users}
+}
+
+// Resolve returns the user for identity, linking it in this order:
+//
+//  1. an account already linked to the identity
+//  2. current, the user who is already logged in
+//  3. an existing user with the same email, if the provider verified it
+//  4. a new user, created on first login
+func (res *Resolver) Resolve(identity *Identity, current *auth.UserModel) (*auth.UserModel, error) {
+	account, err := res.accounts.Find(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil {
+		if current != nil && current.ID != account.UserID {
+			return nil, ErrAccountLinked
+		}
+		return res.users.FindByID(account.UserID)
+	}
+
+	if current != nil {
+		if _, err := res.accounts.Link(current.ID, identity); err != nil {
+			return nil, err
+		}
+		return current, nil
+	}
+
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+	if email == "" {
+		return nil, ErrNoEmail
+	}
+
+	user, err := res.users.FindByEmail(email)
+	if err == nil {
+		// Trusting an unverified address would let anyone who registers it at
+		// the provider take over the local account
+		if !identity.EmailVerified {
+			return nil, ErrEmailUnverified
+		}
+	} else {
+		if user, err = res.createUser(identity, email); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := res.accounts.Link(user.ID, identity); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createUser registers a user for a first-time login. The random password
+// can be replaced through the password reset flow.
+func (res *Resolver) createUser(identity *Identity, email string) (*auth.UserModel, error) {
+	password, err := RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	name := identity.Name
+	if name == "" {
+		name = email
+	}
+
+	if _, err := res.users.Create(name, email, password); err != nil {
+		return nil, err
+	}
+
+	// Reload to pick up the generated ID
+	user, err := res.users.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity.EmailVerified {
+		if err := res.users.MarkEmailVerified(user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
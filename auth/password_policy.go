@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var (
+	commonPasswordsOnce sync.Once
+	commonPasswords     map[string]struct{}
+)
+
+// isCommonPassword reports whether password, ignoring case, is on the bundled list
+func isCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			commonPasswords[strings.ToLower(line)] = struct{}{}
+		}
+	})
+
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
+
+// WeakPasswordError explains why a password was rejected. Its message is
+// suitable for showing to the user.
+type WeakPasswordError struct {
+	Reason string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return e.Reason
+}
+
+// PasswordPolicy describes the passwords users may choose
+type PasswordPolicy struct {
+	MinLength int // In characters
+	MaxLength int // In bytes, since bcrypt ignores everything past 72
+}
+
+// Validate returns a *WeakPasswordError if password is too short, too long,
+// commonly used, or the same as one of related, such as the user's email or name
+func (p PasswordPolicy) Validate(password string, related ...string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &WeakPasswordError{fmt.Sprintf("Password must be at least %d characters", p.MinLength)}
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return &WeakPasswordError{fmt.Sprintf("Password must be at most %d bytes", p.MaxLength)}
+	}
+	if isCommonPassword(password) {
+		return &WeakPasswordError{"Password is too common"}
+	}
+	for _, value := range related {
+		if value != "" && strings.EqualFold(strings.TrimSpace(value), password) {
+			return &WeakPasswordError{"Password must not match your name or email"}
+		}
+	}
+	return nil
+}
+
+// PasswordPolicy returns the policy new passwords are checked against
+func (p *Provider) PasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength: p.config.PasswordMinLength,
+		MaxLength: p.config.PasswordMaxLength,
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/example/goframe/db"
+)
+
+// ErrInvalidResetToken is returned for unknown, expired or already used reset tokens
+var ErrInvalidResetToken = errors.New("invalid password reset token")
+
+// PasswordResetRepository stores hashed password reset tokens in password_resets
+type PasswordResetRepository struct {
+	db    *db.Database
+	table string
+}
+
+// NewPasswordResetRepository creates a new password reset repository
+func NewPasswordResetRepository(database *db.Database) *PasswordResetRepository {
+	return &PasswordResetRepository{
+		db:    database,
+		table: "password_resets",
+	}
+}
+
+// Create replaces any outstanding token for email, created at now, and returns
+// the new plain-text token
+func (r *PasswordResetRepository) Create(email string, now time.Time) (string, error) {
+	plain, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.Delete(email); err != nil {
+		return "", err
+	}
+
+	err = db.NewQueryBuilder(r.db, r.table).Insert(map[string]interface{}{
+		"email":      email,
+		"token":      hashToken(plain),
+		"created_at": now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
+
+// Consume deletes plain if it is a token for email created no earlier than
+// notBefore, and reports whether it was. The check and the delete are one
+// statement, so of two concurrent callers with the same token only one wins.
+func (r *PasswordResetRepository) Consume(email, plain string, notBefore time.Time) (bool, error) {
+	deleted, err := db.NewQueryBuilder(r.db, r.table).
+		Where("email", "=", email).
+		Where("token", "=", hashToken(plain)).
+		Where("created_at", ">=", notBefore).
+		DeleteCount()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// Delete removes every token for email, invalidating outstanding links
+func (r *PasswordResetRepository) Delete(email string) error {
+	return db.NewQueryBuilder(r.db, r.table).Where("email", "=", email).Delete()
+}
+
+// CreatePasswordResetToken creates a reset token for the user and returns it.
+// The caller is responsible for delivering the token.
+func (p *Provider) CreatePasswordResetToken(user *UserModel) (string, error) {
+	return p.passwordResets.Create(user.Email, p.now())
+}
+
+// ConsumePasswordResetToken validates a reset token and deletes it, along with
+// any other outstanding tokens for the email, so that it cannot be replayed
+func (p *Provider) ConsumePasswordResetToken(email, token string) error {
+	consumed, err := p.passwordResets.Consume(email, token, p.now().Add(-p.config.PasswordResetExpire))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+	return p.passwordResets.Delete(email)
+}
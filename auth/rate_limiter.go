@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter counts hits per key within a decay window
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	hits    int
+	resetAt time.Time
+}
+
+// NewRateLimiter creates an in-memory rate limiter
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: make(map[string]*rateBucket)}
+}
+
+// Hit records an attempt for key and returns the number of hits in the window
+func (l *RateLimiter) Hit(key string, decay time.Duration) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok || now.After(b.resetAt) {
+		b = &rateBucket{resetAt: now.Add(decay)}
+		l.buckets[key] = b
+	}
+	b.hits++
+
+	// Drop stale buckets so the map does not grow without bound
+	for k, other := range l.buckets {
+		if now.After(other.resetAt) {
+			delete(l.buckets, k)
+		}
+	}
+
+	return b.hits
+}
+
+// TooManyAttempts reports whether key has reached max hits in the current window
+func (l *RateLimiter) TooManyAttempts(key string, max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok || time.Now().After(b.resetAt) {
+		return false
+	}
+	return b.hits >= max
+}
+
+// AvailableIn returns how long until key's window resets
+func (l *RateLimiter) AvailableIn(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return 0
+	}
+	if wait := time.Until(b.resetAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Clear forgets all hits for key
+func (l *RateLimiter) Clear(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, key)
+}
@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/example/goframe/db"
+)
+
+// Role is a named group of permissions assigned to users
+type Role struct {
+	db.Entity
+	Name  string  `db:"name" json:"name"`
+	Label *string `db:"label" json:"label"`
+}
+
+// TableName returns the table name for the model
+func (Role) TableName() string {
+	return "roles"
+}
+
+// Permission is a named capability granted through roles
+type Permission struct {
+	db.Entity
+	Name  string  `db:"name" json:"name"`
+	Label *string `db:"label" json:"label"`
+}
+
+// TableName returns the table name for the model
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// RoleRepository manages roles, permissions and their pivot tables
+type RoleRepository struct {
+	db          *db.Database
+	roles       *db.Repository[Role]
+	permissions *db.Repository[Permission]
+}
+
+// NewRoleRepository creates a new role repository
+func NewRoleRepository(database *db.Database) *RoleRepository {
+	return &RoleRepository{
+		db:          database,
+		roles:       db.NewRepository[Role](database),
+		permissions: db.NewRepository[Permission](database),
+	}
+}
+
+// FindOrCreateRole returns the role with the given name, creating it if needed
+func (r *RoleRepository) FindOrCreateRole(name string) (*Role, error) {
+	var role Role
+	if err := r.roles.FindByString("name", &role, name); err == nil {
+		return &role, nil
+	}
+
+	role = Role{Name: name}
+	if err := r.roles.Create(&role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// FindOrCreatePermission returns the permission with the given name, creating it if needed
+func (r *RoleRepository) FindOrCreatePermission(name string) (*Permission, error) {
+	var permission Permission
+	if err := r.permissions.FindByString("name", &permission, name); err == nil {
+		return &permission, nil
+	}
+
+	permission = Permission{Name: name}
+	if err := r.permissions.Create(&permission); err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+// AssignRole gives the user a role, creating the role if it does not exist
+func (r *RoleRepository) AssignRole(userID uint, roleName string) error {
+	role, err := r.FindOrCreateRole(roleName)
+	if err != nil {
+		return err
+	}
+
+	count, err := db.NewQueryBuilder(r.db, "role_user").
+		Where("user_id", "=", userID).
+		Where("role_id", "=", role.ID).
+		Count()
+	if err != nil || count > 0 {
+		return err
+	}
+
+	return db.NewQueryBuilder(r.db, "role_user").Insert(map[string]interface{}{
+		"user_id": userID,
+		"role_id": role.ID,
+	})
+}
+
+// RemoveRole takes a role away from the user
+func (r *RoleRepository) RemoveRole(userID uint, roleName string) error {
+	var role Role
+	if err := r.roles.FindByString("name", &role, roleName); err != nil {
+		return nil
+	}
+
+	return db.NewQueryBuilder(r.db, "role_user").
+		Where("user_id", "=", userID).
+		Where("role_id", "=", role.ID).
+		Delete()
+}
+
+// GivePermission grants a permission to a role, creating either if needed
+func (r *RoleRepository) GivePermission(roleName, permissionName string) error {
+	role, err := r.FindOrCreateRole(roleName)
+	if err != nil {
+		return err
+	}
+
+	permission, err := r.FindOrCreatePermission(permissionName)
+	if err != nil {
+		return err
+	}
+
+	count, err := db.NewQueryBuilder(r.db, "permission_role").
+		Where("permission_id", "=", permission.ID).
+		Where("role_id", "=", role.ID).
+		Count()
+	if err != nil || count > 0 {
+		return err
+	}
+
+	return db.NewQueryBuilder(r.db, "permission_role").Insert(map[string]interface{}{
+		"permission_id": permission.ID,
+		"role_id":       role.ID,
+	})
+}
+
+// RoleNames returns the names of every role assigned to the user
+func (r *RoleRepository) RoleNames(ctx context.Context, userID uint) ([]string, error) {
+	query, binds := db.NewQueryBuilder(r.db, "roles").
+		Select("roles.name").
+		Join("role_user", "role_user.role_id", "=", "roles.id").
+		Where("role_user.user_id", "=", userID).
+		ToSql()
+
+	return r.names(ctx, query, binds)
+}
+
+// PermissionNames returns the names of every permission granted to the user through roles
+func (r *RoleRepository) PermissionNames(ctx context.Context, userID uint) ([]string, error) {
+	query, binds := db.NewQueryBuilder(r.db, "permissions").
+		Select("permissions.name").
+		Distinct().
+		Join("permission_role", "permission_role.permission_id", "=", "permissions.id").
+		Join("role_user", "role_user.role_id", "=", "permission_role.role_id").
+		Where("role_user.user_id", "=", userID).
+		ToSql()
+
+	return r.names(ctx, query, binds)
+}
+
+// names runs a single-column query and collects the results
+func (r *RoleRepository) names(ctx context.Context, query string, binds []interface{}) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, binds...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// accessControl lazily loads a user's roles and permissions. It hangs off the
+// UserModel loaded for a request, so each request queries at most once. A
+// failed load is not cached: the check is denied and the next one retries.
+type accessControl struct {
+	repo   *RoleRepository
+	userID uint
+
+	mu          sync.Mutex
+	loaded      bool
+	roles       map[string]bool
+	permissions map[string]bool
+}
+
+// load fetches the roles and permissions unless they are already loaded
+func (a *accessControl) load(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.loaded {
+		return nil
+	}
+
+	roles, err := a.repo.RoleNames(ctx, a.userID)
+	if err != nil {
+		return fmt.Errorf("failed to load roles for user %d: %w", a.userID, err)
+	}
+	permissions, err := a.repo.PermissionNames(ctx, a.userID)
+	if err != nil {
+		return fmt.Errorf("failed to load permissions for user %d: %w", a.userID, err)
+	}
+
+	a.roles = make(map[string]bool, len(roles))
+	for _, name := range roles {
+		a.roles[name] = true
+	}
+	a.permissions = make(map[string]bool, len(permissions))
+	for _, name := range permissions {
+		a.permissions[name] = true
+	}
+	a.loaded = true
+	return nil
+}
+
+// LoadAccess loads the user's roles and permissions now, with ctx, rather
+// than on the first check. Middleware calls it so that a failed lookup is a
+// server error instead of a denial.
+func (u *UserModel) LoadAccess(ctx context.Context) error {
+	if u.access == nil {
+		return nil
+	}
+	return u.access.load(ctx)
+}
+
+// accessLoaded loads the user's roles and permissions for a check made
+// outside a request, such as in a template or policy, and reports whether
+// they are available
+func (u *UserModel) accessLoaded() bool {
+	if u.access == nil {
+		return false
+	}
+	if err := u.access.load(context.Background()); err != nil {
+		log.Printf("Failed to check access: %v", err)
+		return false
+	}
+	return true
+}
+
+// HasRole reports whether the user has the named role
+func (u *UserModel) HasRole(name string) bool {
+	return u.HasAnyRole(name)
+}
+
+// HasAnyRole reports whether the user has at least one of the named roles
+func (u *UserModel) HasAnyRole(names ...string) bool {
+	if !u.accessLoaded() {
+		return false
+	}
+	for _, name := range names {
+		if u.access.roles[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether any of the user's roles grants the permission
+func (u *UserModel) HasPermission(name string) bool {
+	if !u.accessLoaded() {
+		return false
+	}
+	return u.access.permissions[name]
+}
+
+// Roles returns the names of the user's roles
+func (u *UserModel) Roles() []string {
+	if !u.accessLoaded() {
+		return nil
+	}
+	names := make([]string, 0, len(u.access.roles))
+	for name := range u.access.roles {
+		names = append(names, name)
+	}
+	return names
+}
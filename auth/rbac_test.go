@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/example/goframe/db"
+	_ "github.com/example/goframe/migrations"
+)
+
+func TestAccessControlRetriesFailedLoad(t *testing.T) {
+	database, err := db.NewDatabase(&db.DatabaseConfig{Driver: "sqlite", Name: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	roles := NewRoleRepository(database)
+	user := &UserModel{access: &accessControl{repo: roles, userID: 1}}
+
+	// The tables do not exist yet, so the lookup fails and is denied
+	if err := user.LoadAccess(context.Background()); err == nil {
+		t.Fatal("LoadAccess succeeded without the tables")
+	}
+	if user.HasRole("admin") {
+		t.Fatal("role granted although the lookup failed")
+	}
+
+	if _, err := db.NewMigrator(database).RunMigrations(db.RegisteredMigrations()); err != nil {
+		t.Fatal(err)
+	}
+	created, err := NewUserRepository(database).Create("Admin", "admin@example.test", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != 1 {
+		t.Fatalf("created user has ID %d", created.ID)
+	}
+	if err := roles.AssignRole(created.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failure was not cached, so the next check loads the role
+	if !user.HasRole("admin") {
+		t.Fatal("role denied after the tables were created")
+	}
+}
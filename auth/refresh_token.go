@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/example/goframe/db"
+)
+
+// RefreshToken is a long-lived, single-use token exchanged for new access tokens.
+// Tokens issued from the same login share a FamilyID so that reuse of a rotated
+// token can revoke every descendant.
+type RefreshToken struct {
+	db.Entity
+	UserID    uint       `db:"user_id" json:"user_id"`
+	FamilyID  string     `db:"family_id" json:"-"`
+	TokenHash string     `db:"token_hash" json:"-"`
+	ExpiresAt time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at"`
+}
+
+// TableName returns the table name for the model
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// RefreshTokenRepository provides methods to interact with refresh tokens
+type RefreshTokenRepository struct {
+	db   *db.Database
+	repo *db.Repository[RefreshToken]
+}
+
+// NewRefreshTokenRepository creates a new refresh token repository
+func NewRefreshTokenRepository(database *db.Database) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db:   database,
+		repo: db.NewRepository[RefreshToken](database),
+	}
+}
+
+// Create stores a new refresh token and returns its plain-text value
+func (r *RefreshTokenRepository) Create(ctx context.Context, userID uint, familyID string, expiresAt time.Time) (string, error) {
+	plain, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	token := &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(plain),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := r.repo.CreateContext(ctx, token); err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
+
+// FindByToken finds a refresh token by its plain-text value
+func (r *RefreshTokenRepository) FindByToken(ctx context.Context, plain string) (*RefreshToken, error) {
+	var token RefreshToken
+	if err := r.repo.FindByStringContext(ctx, "token_hash", &token, hashToken(plain)); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// errAlreadyRevoked is returned by Revoke when the token was revoked first by
+// someone else, such as a concurrent refresh with the same token
+var errAlreadyRevoked = errors.New("refresh token already revoked")
+
+// Revoke marks a single refresh token as used at now. The update only
+// applies while the token is unrevoked, so of two concurrent callers exactly
+// one succeeds; the other gets errAlreadyRevoked.
+func (r *RefreshTokenRepository) Revoke(ctx context.Context, token *RefreshToken, now time.Time) error {
+	revoked, err := db.NewQueryBuilder(r.db, token.TableName()).
+		WithContext(ctx).
+		Where("id", "=", token.ID).
+		WhereNull("revoked_at").
+		UpdateCount(map[string]interface{}{"revoked_at": now, "updated_at": now})
+	if err != nil {
+		return err
+	}
+	if revoked != 1 {
+		return errAlreadyRevoked
+	}
+
+	token.RevokedAt = &now
+	return nil
+}
+
+// RevokeFamily revokes, at now, every outstanding token descended from the
+// same login
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, now time.Time) error {
+	return db.NewQueryBuilder(r.db, RefreshToken{}.TableName()).
+		WithContext(ctx).
+		Where("family_id", "=", familyID).
+		WhereNull("revoked_at").
+		Update(map[string]interface{}{"revoked_at": now, "updated_at": now})
+}
+
+// RevokeAllForUser revokes, at now, every outstanding refresh token
+// belonging to a user
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uint, now time.Time) error {
+	return db.NewQueryBuilder(r.db, RefreshToken{}.TableName()).
+		WithContext(ctx).
+		Where("user_id", "=", userID).
+		WhereNull("revoked_at").
+		Update(map[string]interface{}{"revoked_at": now, "updated_at": now})
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/example/goframe/session"
+)
+
+type sessionIDKey struct{}
+
+// Session keys used by SessionGuard
+const (
+	sessionUserKey        = "auth.user_id"
+	sessionFingerprintKey = "auth.fingerprint"
+)
+
+var (
+	errNoSession = errors.New("no active session")
+
+	// ErrSessionsDisabled is returned when logging in without the session middleware
+	ErrSessionsDisabled = errors.New("session middleware is not installed")
+)
+
+// SessionGuard authenticates browser requests from the request's session,
+// which the session middleware must have loaded. Sessions are bound to the
+// user's password hash, so changing or resetting the password signs out
+// every browser.
+type SessionGuard struct {
+	provider *Provider
+}
+
+// NewSessionGuard creates a session guard
+func NewSessionGuard(provider *Provider) *SessionGuard {
+	return &SessionGuard{provider: provider}
+}
+
+// Login stores the user in the session. The session ID is regenerated so an
+// ID planted before login cannot be used afterwards.
+func (g *SessionGuard) Login(r *http.Request, user *UserModel) error {
+	s := session.FromRequest(r)
+	if s == nil {
+		return ErrSessionsDisabled
+	}
+
+	if err := s.Regenerate(); err != nil {
+		return err
+	}
+	s.Put(sessionUserKey, user.ID)
+	s.Put(sessionFingerprintKey, hashToken(user.PasswordHash))
+	return nil
+}
+
+// Logout discards the request's session
+func (g *SessionGuard) Logout(r *http.Request) error {
+	s := session.FromRequest(r)
+	if s == nil {
+		return nil
+	}
+	return s.Invalidate()
+}
+
+// Authenticate resolves the user from the session
+func (g *SessionGuard) Authenticate(r *http.Request) (context.Context, error) {
+	s := session.FromRequest(r)
+	if s == nil || !s.Has(sessionUserKey) {
+		return nil, errNoSession
+	}
+
+	user, err := g.provider.GetUserByID(r.Context(), uint(s.GetInt(sessionUserKey)))
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if hashToken(user.PasswordHash) != s.GetString(sessionFingerprintKey) {
+		s.Forget(sessionUserKey, sessionFingerprintKey)
+		return nil, errNoSession
+	}
+
+	ctx := context.WithValue(r.Context(), userKey{}, user)
+	ctx = context.WithValue(ctx, sessionIDKey{}, s.ID())
+	ctx = context.WithValue(ctx, scopesKey{}, []string{"*"})
+	return ctx, nil
+}
+
+// Unauthenticated sends browsers to the login page, remembering where they
+// were headed. JSON clients get a 401.
+func (g *SessionGuard) Unauthenticated(w http.ResponseWriter, r *http.Request, err error) {
+	if wantsJSON(r) {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	target := "/login?" + url.Values{"redirect": {r.URL.RequestURI()}}.Encode()
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// StartSession logs the user in with the "web" session guard
+func (p *Provider) StartSession(r *http.Request, user *UserModel) error {
+	return p.sessions.Login(r, user)
+}
+
+// EndSession logs the request out of the "web" session guard
+func (p *Provider) EndSession(r *http.Request) error {
+	return p.sessions.Logout(r)
+}
+
+// SessionUser returns the user logged in with the "web" session guard, or nil,
+// for public pages that behave differently for signed-in users
+func (p *Provider) SessionUser(r *http.Request) *UserModel {
+	ctx, err := p.sessions.Authenticate(r)
+	if err != nil {
+		return nil
+	}
+	return GetUser(ctx)
+}
+
+// ViaSession reports whether the request was authenticated by a session cookie
+func ViaSession(ctx context.Context) bool {
+	_, ok := ctx.Value(sessionIDKey{}).(string)
+	return ok
+}
+
+// SafeRedirect returns target if it is a path on this site, or fallback
+// otherwise, so login redirects cannot be pointed at another host
+func SafeRedirect(target, fallback string) string {
+	if len(target) == 0 || target[0] != '/' || (len(target) > 1 && (target[1] == '/' || target[1] == '\\')) {
+		return fallback
+	}
+	return target
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CreateTokenRequest represents a request for a new personal access token
+type CreateTokenRequest struct {
+	Name      string     `json:"name"`
+	Abilities []string   `json:"abilities"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateTokenResponse carries the plain-text token, shown only once
+type CreateTokenResponse struct {
+	Token       string               `json:"token"`
+	AccessToken *PersonalAccessToken `json:"access_token"`
+}
+
+// Tokens lists the current user's personal access tokens
+func (c *Controller) Tokens(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := c.provider.AccessTokens(r.Context(), user)
+	if err != nil {
+		http.Error(w, "Failed to fetch tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// CreateToken issues a personal access token for the current user
+func (c *Controller) CreateToken(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if forbidWhileImpersonating(w, r) {
+		return
+	}
+
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(c.provider.now()) {
+		http.Error(w, "Expiry must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	// A token may only mint tokens with a subset of its own scopes
+	abilities := req.Abilities
+	if len(abilities) == 0 {
+		abilities = []string{"*"}
+	}
+	for _, ability := range abilities {
+		if !TokenCan(r.Context(), ability) {
+			http.Error(w, "Requested abilities exceed the current token", http.StatusForbidden)
+			return
+		}
+	}
+
+	token, plain, err := c.provider.CreateAccessToken(r.Context(), user, req.Name, abilities, req.ExpiresAt)
+	if err != nil {
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	c.provider.events.Dispatch(TokenCreated{
+		UserID:      user.ID,
+		TokenID:     token.ID,
+		Name:        token.Name,
+		RequestInfo: requestInfo(r),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateTokenResponse{Token: plain, AccessToken: token})
+}
+
+// RevokeToken deletes one of the current user's personal access tokens
+func (c *Controller) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if forbidWhileImpersonating(w, r) {
+		return
+	}
+
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.provider.RevokeAccessToken(r.Context(), user, uint(id)); err != nil {
+		if errors.Is(err, ErrAccessTokenNotFound) {
+			http.Error(w, "Token not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
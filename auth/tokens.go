@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// TokenPair is the set of tokens handed to a client after authentication
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// IssueTokens creates an access token and starts a new refresh token family
+func (p *Provider) IssueTokens(ctx context.Context, user *UserModel) (*TokenPair, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.issuePair(ctx, user, familyID)
+}
+
+// Refresh rotates a refresh token and returns a fresh token pair. Presenting a
+// token that has already been rotated revokes its whole family, since either
+// the client or an attacker is holding a stolen copy.
+func (p *Provider) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	token, err := p.refreshTokens.FindByToken(ctx, refreshToken)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if token.RevokedAt != nil {
+		return nil, p.refreshReused(ctx, token)
+	}
+
+	if p.now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := p.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Losing the race to revoke means another request rotated this token
+	// between the lookup and now, which is reuse all the same
+	if err := p.refreshTokens.Revoke(ctx, token, p.now()); err != nil {
+		if errors.Is(err, errAlreadyRevoked) {
+			return nil, p.refreshReused(ctx, token)
+		}
+		return nil, err
+	}
+
+	return p.issuePair(ctx, user, token.FamilyID)
+}
+
+// refreshReused revokes the family of a refresh token presented after it
+// was rotated and returns ErrRefreshTokenReused
+func (p *Provider) refreshReused(ctx context.Context, token *RefreshToken) error {
+	if err := p.refreshTokens.RevokeFamily(ctx, token.FamilyID, p.now()); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// Logout revokes the access token described by claims and, when given, the
+// refresh token family it was issued with
+func (p *Provider) Logout(ctx context.Context, claims *Claims, refreshToken string) error {
+	if claims != nil && claims.ID != "" && claims.ExpiresAt != nil {
+		if err := p.denylist.Add(claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	token, err := p.refreshTokens.FindByToken(ctx, refreshToken)
+	if err != nil {
+		return nil
+	}
+
+	// Never let one user revoke another user's session
+	if claims != nil && token.UserID != claims.UserID {
+		return ErrInvalidRefreshToken
+	}
+
+	return p.refreshTokens.RevokeFamily(ctx, token.FamilyID, p.now())
+}
+
+// RevokeAllTokens revokes every refresh token belonging to the user
+func (p *Provider) RevokeAllTokens(ctx context.Context, user *UserModel) error {
+	return p.refreshTokens.RevokeAllForUser(ctx, user.ID, p.now())
+}
+
+// issuePair creates an access token and a refresh token in the given family
+func (p *Provider) issuePair(ctx context.Context, user *UserModel, familyID string) (*TokenPair, error) {
+	accessToken, err := p.issueAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := p.refreshTokens.Create(ctx, user.ID, familyID, p.now().Add(p.config.RefreshDuration))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(p.config.Duration.Seconds()),
+	}, nil
+}
+
+// issueAccessToken creates a short-lived signed JWT for the given user
+func (p *Provider) issueAccessToken(user *UserModel) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &Claims{
+		UserID:           user.ID,
+		RegisteredClaims: p.registeredClaims(jti, p.config.Duration),
+	}
+
+	return p.signToken(claims)
+}
+
+// registeredClaims returns the standard claims for a token valid for ttl,
+// including the configured issuer and audience
+func (p *Provider) registeredClaims(jti string, ttl time.Duration) jwt.RegisteredClaims {
+	now := p.now()
+	claims := jwt.RegisteredClaims{
+		ID:        jti,
+		Issuer:    p.config.Issuer,
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	if p.config.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{p.config.Audience}
+	}
+	return claims
+}
+
+// signToken signs claims with the active key, or with the shared secret when
+// no key set is configured
+func (p *Provider) signToken(claims jwt.Claims) (string, error) {
+	if p.keys != nil {
+		return p.keys.sign(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(p.config.Secret))
+}
+
+// verifiableClaims is implemented by claims embedding jwt.RegisteredClaims
+type verifiableClaims interface {
+	VerifyExpiresAt(cmp time.Time, req bool) bool
+	VerifyNotBefore(cmp time.Time, req bool) bool
+	VerifyIssuer(cmp string, req bool) bool
+	VerifyAudience(cmp string, req bool) bool
+}
+
+// parseToken verifies a signed token and decodes it into claims. Expiry is
+// checked against the provider's clock rather than the system time, and the
+// issuer and audience must match when configured.
+func (p *Provider) parseToken(tokenString string, claims jwt.Claims) error {
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if p.keys != nil {
+			return p.keys.verificationKey(token, p.now())
+		}
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(p.config.Secret), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !token.Valid {
+		return errInvalidToken
+	}
+
+	if registered, ok := claims.(verifiableClaims); ok {
+		now := p.now()
+		if !registered.VerifyExpiresAt(now, true) || !registered.VerifyNotBefore(now, false) {
+			return errInvalidToken
+		}
+		if p.config.Issuer != "" && !registered.VerifyIssuer(p.config.Issuer, true) {
+			return errInvalidToken
+		}
+		if p.config.Audience != "" && !registered.VerifyAudience(p.config.Audience, true) {
+			return errInvalidToken
+		}
+	}
+
+	return nil
+}
+
+// parseAccessToken verifies an access token and returns its claims
+func (p *Provider) parseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := p.parseToken(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	// Challenge and other single-purpose tokens are never access tokens
+	if claims.Purpose != "" {
+		return nil, errInvalidToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,136 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from RFC 6238 appendix B, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC lists 8-digit codes; with 6 digits the code is their last six
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	for _, v := range rfcVectors {
+		code, err := Code(rfcSecret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("Code at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestValidateAcceptsRFC6238Vectors(t *testing.T) {
+	for _, v := range rfcVectors {
+		now := time.Unix(v.unix, 0)
+		step, ok := Validate(rfcSecret, v.code, now, 0)
+		if !ok {
+			t.Errorf("Validate(%s) at %d rejected", v.code, v.unix)
+			continue
+		}
+		if step != Step(now) {
+			t.Errorf("Validate(%s) at %d matched step %d, want %d", v.code, v.unix, step, Step(now))
+		}
+	}
+}
+
+func TestValidateAllowsOneStepOfSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"previous step", -Period, true},
+		{"next step", Period, true},
+		{"two steps behind", -2 * Period, false},
+		{"two steps ahead", 2 * Period, false},
+	}
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, now.Add(tt.offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfcSecret, code, now, 0)
+		if ok != tt.ok {
+			t.Errorf("%s: Validate = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if ok && step != current+int64(tt.offset/Period) {
+			t.Errorf("%s: matched step %d, want %d", tt.name, step, current+int64(tt.offset/Period))
+		}
+	}
+}
+
+func TestValidateRejectsReplayedSteps(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := Code(rfcSecret, now)
+
+	step, ok := Validate(rfcSecret, code, now, 0)
+	if !ok {
+		t.Fatal("first use rejected")
+	}
+	if _, ok := Validate(rfcSecret, code, now, step); ok {
+		t.Error("code accepted again at the step it was used")
+	}
+
+	// A code from an earlier step is refused once a later one was used, even
+	// though it is still within the skew window
+	earlier, _ := Code(rfcSecret, now.Add(-Period))
+	if _, ok := Validate(rfcSecret, earlier, now, step); ok {
+		t.Error("code from before the last used step accepted")
+	}
+
+	// The next period's code is still fine
+	next, _ := Code(rfcSecret, now.Add(Period))
+	if _, ok := Validate(rfcSecret, next, now.Add(Period), step); !ok {
+		t.Error("code from the following step rejected")
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"wrong code", rfcSecret, "000000"},
+		{"short code", rfcSecret, "28708"},
+		{"long code", rfcSecret, "2870820"},
+		{"bad secret", "not base32!", "287082"},
+		{"empty secret", "", "287082"},
+	}
+	for _, tt := range tests {
+		if _, ok := Validate(tt.secret, tt.code, now, 0); ok {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
+
+func TestGenerateSecretRoundTrips(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("Code with generated secret: %v", err)
+	}
+	if _, ok := Validate(secret, code, now, 0); !ok {
+		t.Error("code for generated secret rejected")
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/example/goframe/auth/totp"
+)
+
+// challengePurpose marks a token issued between the password and 2FA steps of a login
+const challengePurpose = "two-factor-challenge"
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 8
+
+var (
+	// ErrTwoFactorNotEnabled is returned when 2FA is required but not set up
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+
+	// ErrInvalidChallenge is returned for unknown, expired or used challenge tokens
+	ErrInvalidChallenge = errors.New("invalid two-factor challenge")
+)
+
+// HasTwoFactorEnabled reports whether the user has confirmed a 2FA secret
+func (u *UserModel) HasTwoFactorEnabled() bool {
+	return u.TwoFactorSecret != nil && u.TwoFactorConfirmedAt != nil
+}
+
+// EnableTwoFactor generates a new secret for the user. 2FA is not enforced
+// until the secret is confirmed with ConfirmTwoFactor.
+func (p *Provider) EnableTwoFactor(user *UserModel) (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	user.TwoFactorSecret = &secret
+	user.TwoFactorConfirmedAt = nil
+	user.TwoFactorRecoveryCodes = nil
+	user.TwoFactorLastStep = 0
+	return secret, nil
+}
+
+// ConfirmTwoFactor turns on 2FA once the user proves their authenticator app
+// produces valid codes, and returns a fresh set of recovery codes
+func (p *Provider) ConfirmTwoFactor(user *UserModel, code string) ([]string, error) {
+	if user.TwoFactorSecret == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if !p.VerifyTwoFactorCode(user, code) {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := p.now()
+	user.TwoFactorConfirmedAt = &now
+	return p.GenerateRecoveryCodes(user)
+}
+
+// DisableTwoFactor removes the user's 2FA secret and recovery codes
+func (p *Provider) DisableTwoFactor(user *UserModel) {
+	user.TwoFactorSecret = nil
+	user.TwoFactorConfirmedAt = nil
+	user.TwoFactorRecoveryCodes = nil
+	user.TwoFactorLastStep = 0
+}
+
+// TwoFactorURI returns the otpauth:// URI for the user's secret
+func (p *Provider) TwoFactorURI(user *UserModel) string {
+	if user.TwoFactorSecret == nil {
+		return ""
+	}
+	return totp.URI(p.config.AppName, user.Email, *user.TwoFactorSecret)
+}
+
+// VerifyTwoFactorCode checks a code from the user's authenticator app. Each
+// code is accepted once; the step it matched is recorded on the user, which
+// the caller must save.
+func (p *Provider) VerifyTwoFactorCode(user *UserModel, code string) bool {
+	if user.TwoFactorSecret == nil {
+		return false
+	}
+
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	step, ok := totp.Validate(*user.TwoFactorSecret, code, p.now(), user.TwoFactorLastStep)
+	if !ok {
+		return false
+	}
+
+	user.TwoFactorLastStep = step
+	return true
+}
+
+// GenerateRecoveryCodes replaces the user's recovery codes and returns the
+// plain-text values, which are never retrievable again. The caller must save
+// the user.
+func (p *Provider) GenerateRecoveryCodes(user *UserModel) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw, err := randomHex(5)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:5] + "-" + raw[5:]
+		codes[i] = code
+		hashes[i] = hashToken(code)
+	}
+
+	encoded, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, err
+	}
+	stored := string(encoded)
+	user.TwoFactorRecoveryCodes = &stored
+	return codes, nil
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes. The caller must
+// save the user.
+func (p *Provider) UseRecoveryCode(user *UserModel, code string) bool {
+	if user.TwoFactorRecoveryCodes == nil {
+		return false
+	}
+
+	var hashes []string
+	if err := json.Unmarshal([]byte(*user.TwoFactorRecoveryCodes), &hashes); err != nil {
+		return false
+	}
+
+	hash := hashToken(strings.ToLower(strings.TrimSpace(code)))
+	for i, candidate := range hashes {
+		if candidate == hash {
+			hashes = append(hashes[:i], hashes[i+1:]...)
+			encoded, _ := json.Marshal(hashes)
+			stored := string(encoded)
+			user.TwoFactorRecoveryCodes = &stored
+			return true
+		}
+	}
+	return false
+}
+
+// IssueChallengeToken creates a short-lived token proving the user passed the
+// password step of a login. It cannot be used as an access token.
+func (p *Provider) IssueChallengeToken(user *UserModel) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	return p.signToken(&Claims{
+		UserID:           user.ID,
+		Purpose:          challengePurpose,
+		RegisteredClaims: p.registeredClaims(jti, p.config.TwoFactorChallenge),
+	})
+}
+
+// ParseChallengeToken verifies a challenge token and returns its claims
+func (p *Provider) ParseChallengeToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := p.parseToken(tokenString, claims); err != nil || claims.Purpose != challengePurpose {
+		return nil, ErrInvalidChallenge
+	}
+
+	used, err := p.denylist.Contains(claims.ID)
+	if err != nil || used {
+		return nil, ErrInvalidChallenge
+	}
+	return claims, nil
+}
+
+// CompleteChallenge marks a challenge token as used
+func (p *Provider) CompleteChallenge(claims *Claims) error {
+	return p.denylist.Add(claims.ID, claims.ExpiresAt.Time)
+}
@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/example/goframe/auth/totp"
+	"github.com/example/goframe/session"
+)
+
+// sessionChallengeKey holds the challenge token for HTML logins awaiting a code
+const sessionChallengeKey = "auth.two_factor_challenge"
+
+// maxChallengeAttempts is how many codes may be tried against one challenge
+const maxChallengeAttempts = 5
+
+// TwoFactorCodeRequest carries a code from the user's authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// DisableTwoFactorRequest confirms the user's password before turning 2FA off
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+}
+
+// TwoFactorChallengeRequest completes a login that requires a second factor
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// EnableTwoFactor generates a new 2FA secret for the current user and returns
+// it with its otpauth:// URI and a QR code to scan
+func (c *Controller) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if forbidWhileImpersonating(w, r) {
+		return
+	}
+
+	if user.HasTwoFactorEnabled() {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := c.provider.EnableTwoFactor(user)
+	if err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	uri := c.provider.TwoFactorURI(user)
+	png, err := totp.QRCode(uri, 256)
+	if err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	if err := c.repo.Save(user); err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_url": uri,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// TwoFactorQRCode serves the current user's enrollment QR code as a PNG
+func (c *Controller) TwoFactorQRCode(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	uri := c.provider.TwoFactorURI(user)
+	if uri == "" {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusNotFound)
+		return
+	}
+
+	png, err := totp.QRCode(uri, 256)
+	if err != nil {
+		http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+// ConfirmTwoFactor turns on 2FA once the user submits a valid code, and
+// returns their recovery codes
+func (c *Controller) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if forbidWhileImpersonating(w, r) {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := c.provider.ConfirmTwoFactor(user, req.Code)
+	if errors.Is(err, ErrTwoFactorNotEnabled) {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrInvalidCredentials) {
+		http.Error(w, "Invalid two-factor code", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to confirm two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	if err := c.repo.Save(user); err != nil {
+		http.Error(w, "Failed to confirm two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	c.provider.events.Dispatch(TwoFactorEnabled{UserID: user.ID, RequestInfo: requestInfo(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes
+func (c *Controller) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if forbidWhileImpersonating(w, r) {
+		return
+	}
+
+	if !user.HasTwoFactorEnabled() {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := c.provider.GenerateRecoveryCodes(user)
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	if err := c.repo.Save(user); err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	c.provider.events.Dispatch(RecoveryCodesRegenerated{UserID: user.ID, RequestInfo: requestInfo(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns 2FA off after checking the user's password
+func (c *Controller) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := GetUser(r.Context())
+	if user == nil {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if forbidWhileImpersonating(w, r) {
+		return
+	}
+
+	var req DisableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !c.provider.CheckPassword(user, req.Password) {
+		http.Error(w, "Invalid password", http.StatusUnprocessableEntity)
+		return
+	}
+
+	c.provider.DisableTwoFactor(user)
+	if err := c.repo.Save(user); err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	c.provider.events.Dispatch(TwoFactorDisabled{UserID: user.ID, RequestInfo: requestInfo(r)})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TwoFactorChallenge completes a login started by Login for a user with 2FA
+// enabled. It accepts either a code from the authenticator app or one of the
+// user's recovery codes.
+func (c *Controller) TwoFactorChallenge(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorChallengeRequest
+	redirect := "/dashboard"
+	form := isFormPost(r)
+	if form {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if s := session.FromRequest(r); s != nil {
+			req.ChallengeToken = s.GetString(sessionChallengeKey)
+		}
+		req.Code = r.PostFormValue("code")
+		req.RecoveryCode = r.PostFormValue("recovery_code")
+		redirect = SafeRedirect(r.PostFormValue("redirect"), redirect)
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	fail := func(message string, status int) {
+		if form {
+			target := "/two-factor-challenge?" + url.Values{"error": {"1"}, "redirect": {redirect}}.Encode()
+			http.Redirect(w, r, target, http.StatusSeeOther)
+			return
+		}
+		http.Error(w, message, status)
+	}
+
+	claims, err := c.provider.ParseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		if form {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	// Six digits are only safe against guessing with a cap on attempts
+	key := "two-factor:" + claims.ID
+	if c.limiter.TooManyAttempts(key, maxChallengeAttempts) {
+		retryAfter := int(c.limiter.AvailableIn(key).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		fail("Too many two-factor attempts", http.StatusTooManyRequests)
+		return
+	}
+	c.limiter.Hit(key, c.provider.Config().TwoFactorChallenge)
+
+	user, err := c.provider.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		fail("Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	var ok bool
+	switch {
+	case req.Code != "":
+		ok = c.provider.VerifyTwoFactorCode(user, req.Code)
+	case req.RecoveryCode != "":
+		ok = c.provider.UseRecoveryCode(user, req.RecoveryCode)
+	}
+	if !ok {
+		c.provider.events.Dispatch(LoginFailed{
+			UserID:      user.ID,
+			Email:       user.Email,
+			Reason:      "invalid_two_factor_code",
+			RequestInfo: requestInfo(r),
+		})
+		fail("Invalid two-factor code", http.StatusUnauthorized)
+		return
+	}
+
+	// Persist the used code before anything else so it cannot be replayed
+	if err := c.repo.Save(user); err != nil {
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+		return
+	}
+	if err := c.provider.CompleteChallenge(claims); err != nil {
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+		return
+	}
+	c.limiter.Clear(key)
+
+	if s := session.FromRequest(r); s != nil {
+		s.Forget(sessionChallengeKey)
+	}
+	c.completeLogin(w, r, user, "two_factor", form, redirect)
+}
+
+// startTwoFactorChallenge asks a user who passed the password step for their
+// second factor
+func (c *Controller) startTwoFactorChallenge(w http.ResponseWriter, r *http.Request, user *UserModel, form bool, redirect string) {
+	challenge, err := c.provider.IssueChallengeToken(user)
+	if err != nil {
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+		return
+	}
+
+	if form {
+		s := session.FromRequest(r)
+		if s == nil {
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		s.Put(sessionChallengeKey, challenge)
+		http.Redirect(w, r, "/two-factor-challenge?"+url.Values{"redirect": {redirect}}.Encode(), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"two_factor":      true,
+		"challenge_token": challenge,
+		"expires_in":      int64(c.provider.Config().TwoFactorChallenge.Seconds()),
+	})
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/goframe/auth/totp"
+)
+
+// newTwoFactorProvider returns a provider whose clock is fixed at now. The
+// two-factor checks never touch the database.
+func newTwoFactorProvider(now *time.Time) *Provider {
+	p := NewProvider(nil, AuthConfig{Secret: "test-secret"})
+	p.SetClock(func() time.Time { return *now })
+	return p
+}
+
+func TestVerifyTwoFactorCodeUsesProviderClock(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := newTwoFactorProvider(&now)
+
+	user := &UserModel{}
+	secret, err := p.EnableTwoFactor(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := totp.Code(secret, now)
+	if !p.VerifyTwoFactorCode(user, code) {
+		t.Fatal("current code rejected")
+	}
+	if user.TwoFactorLastStep != totp.Step(now) {
+		t.Errorf("TwoFactorLastStep = %d, want %d", user.TwoFactorLastStep, totp.Step(now))
+	}
+
+	// The same code is only good for its own window
+	now = now.Add(3 * totp.Period)
+	stale, _ := totp.Code(secret, now.Add(-3*totp.Period))
+	if p.VerifyTwoFactorCode(user, stale) {
+		t.Error("code from three periods ago accepted")
+	}
+}
+
+func TestVerifyTwoFactorCodeRejectsReplay(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := newTwoFactorProvider(&now)
+
+	user := &UserModel{}
+	secret, _ := p.EnableTwoFactor(user)
+	code, _ := totp.Code(secret, now)
+
+	if !p.VerifyTwoFactorCode(user, code) {
+		t.Fatal("first use rejected")
+	}
+	if p.VerifyTwoFactorCode(user, code) {
+		t.Error("code accepted twice")
+	}
+
+	now = now.Add(totp.Period)
+	next, _ := totp.Code(secret, now)
+	if !p.VerifyTwoFactorCode(user, next) {
+		t.Error("next period's code rejected")
+	}
+}
+
+func TestVerifyTwoFactorCodeIgnoresSpacing(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := newTwoFactorProvider(&now)
+
+	user := &UserModel{}
+	secret, _ := p.EnableTwoFactor(user)
+	code, _ := totp.Code(secret, now)
+
+	if !p.VerifyTwoFactorCode(user, " "+code[:3]+" "+code[3:]+" ") {
+		t.Error("spaced code rejected")
+	}
+}
+
+func TestVerifyTwoFactorCodeWithoutSecret(t *testing.T) {
+	now := time.Now()
+	p := newTwoFactorProvider(&now)
+
+	if p.VerifyTwoFactorCode(&UserModel{}, "123456") {
+		t.Error("code accepted for a user without 2FA")
+	}
+}
+
+func TestConfirmTwoFactorEnablesAndIssuesRecoveryCodes(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := newTwoFactorProvider(&now)
+
+	user := &UserModel{}
+	secret, _ := p.EnableTwoFactor(user)
+	if user.HasTwoFactorEnabled() {
+		t.Fatal("2FA enabled before confirmation")
+	}
+
+	if _, err := p.ConfirmTwoFactor(user, "000000"); err == nil {
+		t.Fatal("confirmed with a wrong code")
+	}
+
+	code, _ := totp.Code(secret, now)
+	codes, err := p.ConfirmTwoFactor(user, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.HasTwoFactorEnabled() {
+		t.Error("2FA not enabled after confirmation")
+	}
+	if !user.TwoFactorConfirmedAt.Equal(now) {
+		t.Errorf("TwoFactorConfirmedAt = %v, want %v", user.TwoFactorConfirmedAt, now)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+}
+
+func TestUseRecoveryCodeConsumesEachCodeOnce(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := newTwoFactorProvider(&now)
+
+	user := &UserModel{}
+	codes, err := p.GenerateRecoveryCodes(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.UseRecoveryCode(user, codes[0]) {
+		t.Fatal("recovery code rejected")
+	}
+	if p.UseRecoveryCode(user, codes[0]) {
+		t.Error("recovery code accepted twice")
+	}
+
+	// Codes are matched regardless of case and surrounding space
+	if !p.UseRecoveryCode(user, "  "+strings.ToUpper(codes[1])+"\n") {
+		t.Error("upper-case recovery code with whitespace rejected")
+	}
+	if p.UseRecoveryCode(user, "00000-00000") {
+		t.Error("unknown recovery code accepted")
+	}
+
+	for _, code := range codes[2:] {
+		if !p.UseRecoveryCode(user, code) {
+			t.Errorf("recovery code %s rejected", code)
+		}
+	}
+	if p.UseRecoveryCode(user, codes[2]) {
+		t.Error("recovery code accepted after all were used")
+	}
+}
+
+func TestUseRecoveryCodeWithoutCodes(t *testing.T) {
+	now := time.Now()
+	p := newTwoFactorProvider(&now)
+
+	if p.UseRecoveryCode(&UserModel{}, "abcde-12345") {
+		t.Error("recovery code accepted for a user without codes")
+	}
+}
@@ -1,36 +1,90 @@
 package auth
 
 import (
-	// "time"
+	"context"
+	"time"
 
 	"github.com/example/goframe/db"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/example/goframe/hashing"
 )
 
 // UserModel represents a user in the database
 type UserModel struct {
 	db.Entity
-	Name         string `db:"name" json:"name"`
-	Email        string `db:"email" json:"email"`
-	PasswordHash string `db:"password_hash" json:"-"`
+	Name            string     `db:"name" json:"name"`
+	Email           string     `db:"email" json:"email"`
+	PasswordHash    string     `db:"password_hash" json:"-"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at"`
+
+	TwoFactorSecret        *string    `db:"two_factor_secret" json:"-"`
+	TwoFactorRecoveryCodes *string    `db:"two_factor_recovery_codes" json:"-"` // JSON array of hashes
+	TwoFactorConfirmedAt   *time.Time `db:"two_factor_confirmed_at" json:"two_factor_confirmed_at"`
+	TwoFactorLastStep      int64      `db:"two_factor_last_step" json:"-"` // Last TOTP step accepted, to stop replays
+
+	access *accessControl // Roles and permissions, loaded on first check
+}
+
+// TableName returns the table name for the model
+func (UserModel) TableName() string {
+	return "users"
+}
+
+// Hydrate gives users loaded by any query, such as a post's author, the same
+// lazily loaded roles and permissions as those from UserRepository
+func (u *UserModel) Hydrate(database *db.Database) {
+	if u.access == nil {
+		u.access = &accessControl{repo: NewRoleRepository(database), userID: u.ID}
+	}
+}
+
+// HasVerifiedEmail reports whether the user has confirmed their email address
+func (u *UserModel) HasVerifiedEmail() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 // UserRepository provides methods to interact with users
 type UserRepository struct {
-	repo *db.Repository[UserModel]
+	repo   *db.Repository[UserModel]
+	roles  *RoleRepository
+	hasher hashing.Hasher // Nil uses hashing.Default()
 }
 
 // NewUserRepository creates a new user repository
 func NewUserRepository(database *db.Database) *UserRepository {
 	return &UserRepository{
-		repo: db.NewRepository[UserModel](database),
+		repo:  db.NewRepository[UserModel](database),
+		roles: NewRoleRepository(database),
 	}
 }
 
+// Roles returns the repository used for the users' roles and permissions
+func (r *UserRepository) Roles() *RoleRepository {
+	return r.roles
+}
+
+// SetHasher replaces the password hasher, which defaults to hashing.Default()
+func (r *UserRepository) SetHasher(hasher hashing.Hasher) {
+	r.hasher = hasher
+}
+
+// passwords returns the hasher used for the users' passwords
+func (r *UserRepository) passwords() hashing.Hasher {
+	if r.hasher != nil {
+		return r.hasher
+	}
+	return hashing.Default()
+}
+
+// withAccess attaches role and permission lookups to a loaded user
+func (r *UserRepository) withAccess(user *UserModel) *UserModel {
+	user.access = &accessControl{repo: r.roles, userID: user.ID}
+	return user
+}
+
 // Create creates a new user
 func (r *UserRepository) Create(name, email, password string) (*UserModel, error) {
 	// Hash the password
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := r.passwords().Make(password)
 	if err != nil {
 		return nil, err
 	}
@@ -38,38 +92,79 @@ func (r *UserRepository) Create(name, email, password string) (*UserModel, error
 	user := &UserModel{
 		Name:         name,
 		Email:        email,
-		PasswordHash: string(hash),
+		PasswordHash: hash,
 	}
 	
 	if err := r.repo.Create(user); err != nil {
 		return nil, err
 	}
 	
-	return user, nil
+	return r.withAccess(user), nil
 }
 
 // FindByEmail finds a user by email
 func (r *UserRepository) FindByEmail(email string) (*UserModel, error) {
-    var user UserModel
-    err := r.repo.FindByString("email", &user, email)
-    if err != nil {
-        return nil, err
-    }
-    return &user, nil
+	return r.FindByEmailContext(context.Background(), email)
+}
+
+// FindByEmailContext finds a user by email, giving up when ctx is done
+func (r *UserRepository) FindByEmailContext(ctx context.Context, email string) (*UserModel, error) {
+	var user UserModel
+	err := r.repo.FindByStringContext(ctx, "email", &user, email)
+	if err != nil {
+		return nil, err
+	}
+	return r.withAccess(&user), nil
 }
 
 // FindByID finds a user by ID
 func (r *UserRepository) FindByID(id uint) (*UserModel, error) {
+	return r.FindByIDContext(context.Background(), id)
+}
+
+// FindByIDContext finds a user by ID, giving up when ctx is done
+func (r *UserRepository) FindByIDContext(ctx context.Context, id uint) (*UserModel, error) {
 	var user UserModel
-	if err := r.repo.FindByID(id, &user); err != nil {
+	if err := r.repo.FindByIDContext(ctx, id, &user); err != nil {
 		return nil, err
 	}
-	return &user, nil
+	return r.withAccess(&user), nil
+}
+
+// UpdatePassword hashes and stores a new password for the user
+func (r *UserRepository) UpdatePassword(user *UserModel, password string) error {
+	hash, err := r.passwords().Make(password)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = hash
+	return r.repo.Update(user)
+}
+
+// MarkEmailVerified records that the user has confirmed their email address
+func (r *UserRepository) MarkEmailVerified(user *UserModel) error {
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return r.repo.Update(user)
+}
+
+// Save writes the user's current fields to the database
+func (r *UserRepository) Save(user *UserModel) error {
+	return r.repo.Update(user)
 }
 
 // CheckPassword checks if a password is valid for a user
 func (r *UserRepository) CheckPassword(user *UserModel, password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
-	return err == nil
+	return r.passwords().Check(password, user.PasswordHash)
+}
+
+// RehashPasswordIfNeeded re-hashes a correct password whose stored hash was
+// made with another driver or older cost settings
+func (r *UserRepository) RehashPasswordIfNeeded(user *UserModel, password string) error {
+	if !r.passwords().NeedsRehash(user.PasswordHash) {
+		return nil
+	}
+	return r.UpdatePassword(user, password)
 }
 
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUserNotFound is returned when a user provider cannot find a user
+var ErrUserNotFound = errors.New("user not found")
+
+// UserProvider retrieves users and validates their credentials
+type UserProvider interface {
+	// RetrieveByID finds a user by primary key
+	RetrieveByID(ctx context.Context, id uint) (*UserModel, error)
+
+	// RetrieveByEmail finds a user by email address
+	RetrieveByEmail(ctx context.Context, email string) (*UserModel, error)
+
+	// ValidateCredentials reports whether password matches the user's stored hash
+	ValidateCredentials(user *UserModel, password string) bool
+}
+
+// PasswordRehasher is implemented by user providers that can upgrade a stored
+// password hash once the plain password is known
+type PasswordRehasher interface {
+	RehashPasswordIfNeeded(user *UserModel, password string) error
+}
+
+// DatabaseUserProvider is the default UserProvider, backed by a UserRepository
+type DatabaseUserProvider struct {
+	repo *UserRepository
+}
+
+// NewDatabaseUserProvider creates a user provider backed by the given repository
+func NewDatabaseUserProvider(repo *UserRepository) *DatabaseUserProvider {
+	return &DatabaseUserProvider{repo: repo}
+}
+
+// RetrieveByID finds a user by primary key
+func (p *DatabaseUserProvider) RetrieveByID(ctx context.Context, id uint) (*UserModel, error) {
+	user, err := p.repo.FindByIDContext(ctx, id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// RetrieveByEmail finds a user by email address
+func (p *DatabaseUserProvider) RetrieveByEmail(ctx context.Context, email string) (*UserModel, error) {
+	user, err := p.repo.FindByEmailContext(ctx, email)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// ValidateCredentials compares password against the user's stored hash
+func (p *DatabaseUserProvider) ValidateCredentials(user *UserModel, password string) bool {
+	return p.repo.CheckPassword(user, password)
+}
+
+// RehashPasswordIfNeeded upgrades the user's hash to the current settings
+func (p *DatabaseUserProvider) RehashPasswordIfNeeded(user *UserModel, password string) error {
+	return p.repo.RehashPasswordIfNeeded(user, password)
+}
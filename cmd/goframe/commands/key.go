@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// KeyGenerate creates a JWT signing key pair in storage/keys. The private key
+// is written to [kid].pem and the public key to [kid].pub.pem, ready to be
+// listed under auth.signing.keys in config.yaml.
+func KeyGenerate(kid, algorithm string) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		fmt.Printf("Unsupported algorithm: %s (use RS256, ES256 or EdDSA)\n", algorithm)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Failed to generate key: %v\n", err)
+		os.Exit(1)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		fmt.Printf("Failed to encode private key: %v\n", err)
+		os.Exit(1)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		fmt.Printf("Failed to encode public key: %v\n", err)
+		os.Exit(1)
+	}
+
+	dir := filepath.Join("storage", "keys")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		fmt.Printf("Failed to create key directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	privatePath := filepath.Join(dir, kid+".pem")
+	publicPath := filepath.Join(dir, kid+".pub.pem")
+
+	if _, err := os.Stat(privatePath); err == nil {
+		fmt.Printf("Key already exists: %s\n", privatePath)
+		os.Exit(1)
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	if err := os.WriteFile(privatePath, privatePEM, 0600); err != nil {
+		fmt.Printf("Failed to write private key: %v\n", err)
+		os.Exit(1)
+	}
+
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	if err := os.WriteFile(publicPath, publicPEM, 0644); err != nil {
+		fmt.Printf("Failed to write public key: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created %s key %s\n", algorithm, kid)
+	fmt.Printf("  private: %s\n", privatePath)
+	fmt.Printf("  public:  %s\n", publicPath)
+}
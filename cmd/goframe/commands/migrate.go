@@ -8,35 +8,22 @@ import (
 
 	"github.com/example/goframe/config"
 	"github.com/example/goframe/db"
+	_ "github.com/example/goframe/migrations"
 )
 
 // Migrate runs all pending migrations
 func Migrate(cfg *config.Config) {
-
-	dbConfig := db.DatabaseConfig{
-		Driver:   cfg.Database.Driver,
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Name:     cfg.Database.Name,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-	}
-	
 	fmt.Println("Running migrations...")
 
-	database, err := db.NewDatabase(&dbConfig)
+	database, err := db.NewDatabase(db.ConfigFrom(cfg))
 	if err != nil {
 		fmt.Printf("Failed to connect to database: %v\n", err)
 		os.Exit(1)
 	}
 	defer database.Close()
 
-	// Get all migration files
-	migrations, err := getMigrationFiles()
-	if err != nil {
-		fmt.Printf("Failed to get migration files: %v\n", err)
-		os.Exit(1)
-	}
+	// Get all registered migrations
+	migrations := db.RegisteredMigrations()
 
 	// Run migrations
 	migrator := db.NewMigrator(database)
@@ -57,28 +44,15 @@ func Migrate(cfg *config.Config) {
 func MigrateRollback(cfg *config.Config, step int) {
 	fmt.Println("Rolling back migrations...")
 
-	dbConfig := db.DatabaseConfig{
-		Driver:   cfg.Database.Driver,
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Name:     cfg.Database.Name,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-	}
-
-	database, err := db.NewDatabase(&dbConfig)
+	database, err := db.NewDatabase(db.ConfigFrom(cfg))
 	if err != nil {
 		fmt.Printf("Failed to connect to database: %v\n", err)
 		os.Exit(1)
 	}
 	defer database.Close()
 
-	// Get all migration files
-	migrations, err := getMigrationFiles()
-	if err != nil {
-		fmt.Printf("Failed to get migration files: %v\n", err)
-		os.Exit(1)
-	}
+	// Get all registered migrations
+	migrations := db.RegisteredMigrations()
 
 	// Rollback migrations
 	migrator := db.NewMigrator(database)
@@ -99,28 +73,15 @@ func MigrateRollback(cfg *config.Config, step int) {
 func MigrateReset(cfg *config.Config) {
 	fmt.Println("Resetting migrations...")
 
-	dbConfig := db.DatabaseConfig{
-		Driver:   cfg.Database.Driver,
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Name:     cfg.Database.Name,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-	}
-
-	database, err := db.NewDatabase(&dbConfig)
+	database, err := db.NewDatabase(db.ConfigFrom(cfg))
 	if err != nil {
 		fmt.Printf("Failed to connect to database: %v\n", err)
 		os.Exit(1)
 	}
 	defer database.Close()
 
-	// Get all migration files
-	migrations, err := getMigrationFiles()
-	if err != nil {
-		fmt.Printf("Failed to get migration files: %v\n", err)
-		os.Exit(1)
-	}
+	// Get all registered migrations
+	migrations := db.RegisteredMigrations()
 
 	// Reset migrations
 	migrator := db.NewMigrator(database)
@@ -137,28 +98,15 @@ func MigrateReset(cfg *config.Config) {
 func MigrateRefresh(cfg *config.Config) {
 	fmt.Println("Refreshing migrations...")
 
-	dbConfig := db.DatabaseConfig{
-		Driver:   cfg.Database.Driver,
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Name:     cfg.Database.Name,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-	}
-
-	database, err := db.NewDatabase(&dbConfig)
+	database, err := db.NewDatabase(db.ConfigFrom(cfg))
 	if err != nil {
 		fmt.Printf("Failed to connect to database: %v\n", err)
 		os.Exit(1)
 	}
 	defer database.Close()
 
-	// Get all migration files
-	migrations, err := getMigrationFiles()
-	if err != nil {
-		fmt.Printf("Failed to get migration files: %v\n", err)
-		os.Exit(1)
-	}
+	// Get all registered migrations
+	migrations := db.RegisteredMigrations()
 
 	// Refresh migrations
 	migrator := db.NewMigrator(database)
@@ -204,31 +152,31 @@ import (
 	"github.com/example/goframe/db"
 )
 
+func init() {
+	db.RegisterMigration("%s_%s", &Migration_%s{})
+}
+
 // Migration_%s represents the %s migration
 type Migration_%s struct{}
 
 // Up runs the migration
 func (m *Migration_%s) Up(migrator *db.Migrator) error {
-	// Create table
-	sql := ` + "`" + `
-		CREATE TABLE IF NOT EXISTS table_name (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)
-	` + "`" + `
-
-	return migrator.DB().Exec(sql)
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Create("table_name", func(table *db.Blueprint) {
+		table.ID()
+		table.String("name", 255)
+		table.Timestamps()
+	})
 }
 
 // Down rolls back the migration
 func (m *Migration_%s) Down(migrator *db.Migrator) error {
-	// Drop table
-	sql := "DROP TABLE IF EXISTS table_name"
-	return migrator.DB().Exec(sql)
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.DropIfExists("table_name")
 }
-`, timestamp, name, timestamp, timestamp, timestamp)
+`, timestamp, name, timestamp, timestamp, name, timestamp, timestamp, timestamp)
 
 	if _, err := file.WriteString(template); err != nil {
 		fmt.Printf("Failed to write migration file: %v\n", err)
@@ -237,26 +185,3 @@ func (m *Migration_%s) Down(migrator *db.Migrator) error {
 
 	fmt.Printf("Created migration: %s\n", path)
 }
-
-// getMigrationFiles returns all migration files
-func getMigrationFiles() ([]string, error) {
-	// Check if migrations directory exists
-	if _, err := os.Stat("migrations"); os.IsNotExist(err) {
-		return []string{}, nil
-	}
-
-	// Get all migration files
-	var files []string
-	err := filepath.Walk("migrations", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && filepath.Ext(path) == ".go" {
-			files = append(files, path)
-		}
-		return nil
-	})
-
-	return files, err
-}
-
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/example/goframe/auth"
+	"github.com/example/goframe/config"
+	"github.com/example/goframe/db"
+)
+
+// RoleAssign assigns a role to the user with the given email, creating the
+// role if it does not exist yet. Use it to bootstrap the first admin.
+func RoleAssign(cfg *config.Config, email, role string) {
+	database, err := db.NewDatabase(db.ConfigFrom(cfg))
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	users := auth.NewUserRepository(database)
+	user, err := users.FindByEmail(email)
+	if err != nil {
+		fmt.Printf("User not found: %s\n", email)
+		os.Exit(1)
+	}
+
+	if err := users.Roles().AssignRole(user.ID, role); err != nil {
+		fmt.Printf("Failed to assign role: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Assigned role %s to %s\n", role, email)
+}
@@ -37,6 +37,10 @@ func main() {
 		handleMakeController(args)
 	case "make:resource":
 		handleMakeResource(args)
+	case "role:assign":
+		handleRoleAssign(cfg, args)
+	case "key:generate":
+		handleKeyGenerate(args)
 	case "serve":
 		handleServe(cfg)
 	case "help":
@@ -112,6 +116,26 @@ func handleMakeResource(args []string) {
 	commands.MakeResource(name)
 }
 
+func handleRoleAssign(cfg *config.Config, args []string) {
+	if len(args) < 2 {
+		fmt.Println("Usage: goframe role:assign [email] [role]")
+		os.Exit(1)
+	}
+	commands.RoleAssign(cfg, args[0], args[1])
+}
+
+func handleKeyGenerate(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: goframe key:generate [kid] [RS256|ES256|EdDSA]")
+		os.Exit(1)
+	}
+	algorithm := "ES256"
+	if len(args) > 1 {
+		algorithm = args[1]
+	}
+	commands.KeyGenerate(args[0], algorithm)
+}
+
 func handleServe(cfg *config.Config) {
 	commands.Serve(cfg)
 }
@@ -132,6 +156,8 @@ func printHelp() {
 	fmt.Println("  make:model [name]      Create a new model")
 	fmt.Println("  make:controller [name] Create a new controller")
 	fmt.Println("  make:resource [name]   Create a new resource")
+	fmt.Println("  role:assign [email] [role]  Assign a role to a user")
+	fmt.Println("  key:generate [kid] [alg]  Generate a JWT signing key pair")
 	fmt.Println("  serve                  Start the HTTP server")
 	fmt.Println("  help                   Display this help message")
 }
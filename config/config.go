@@ -9,28 +9,120 @@ import (
 
 type Config struct {
 	Server struct {
-		Host string `yaml:"host"`
-		Port int    `yaml:"port"`
+		Host           string        `yaml:"host"`
+		Port           int           `yaml:"port"`
+		RequestTimeout time.Duration `yaml:"request_timeout"`
 	} `yaml:"server"`
 	Database struct {
-		Driver   string `yaml:"driver"`
-		Host     string `yaml:"host"`
-		Port     int    `yaml:"port"`
-		Name     string `yaml:"name"`
-		User     string `yaml:"user"`
-		Password string `yaml:"password"`
+		Driver   string            `yaml:"driver"`
+		Host     string            `yaml:"host"`
+		Port     int               `yaml:"port"`
+		Name     string            `yaml:"name"`
+		User     string            `yaml:"user"`
+		Password string            `yaml:"password"`
+		TLS      string            `yaml:"tls"`
+		Timezone string            `yaml:"timezone"`
+		Charset  string            `yaml:"charset"`
+		Params   map[string]string `yaml:"params"`
+		Pool     struct {
+			MaxOpen     int           `yaml:"max_open"`
+			MaxIdle     int           `yaml:"max_idle"`
+			MaxLifetime time.Duration `yaml:"max_lifetime"`
+			MaxIdleTime time.Duration `yaml:"max_idle_time"`
+		} `yaml:"pool"`
+		TransactionRetries int `yaml:"transaction_retries"`
 	} `yaml:"database"`
 	Auth struct {
-		Secret   string        `yaml:"secret"`
-		Duration time.Duration `yaml:"duration"`
+		Secret          string        `yaml:"secret"`
+		Issuer          string        `yaml:"issuer"`
+		Audience        string        `yaml:"audience"`
+		Duration        time.Duration `yaml:"duration"`
+		RefreshDuration time.Duration `yaml:"refresh_duration"`
+		PasswordReset   struct {
+			Expire   time.Duration `yaml:"expire"`
+			Throttle time.Duration `yaml:"throttle"`
+		} `yaml:"password_reset"`
+		Verification struct {
+			Expire time.Duration `yaml:"expire"`
+		} `yaml:"verification"`
+		Password struct {
+			MinLength int `yaml:"min_length"`
+			MaxLength int `yaml:"max_length"`
+		} `yaml:"password"`
+		MagicLink struct {
+			Expire   time.Duration `yaml:"expire"`
+			Throttle time.Duration `yaml:"throttle"`
+		} `yaml:"magic_link"`
+		Impersonation struct {
+			Duration time.Duration `yaml:"duration"`
+		} `yaml:"impersonation"`
+		Signing struct {
+			Active         string        `yaml:"active"`
+			RotationWindow time.Duration `yaml:"rotation_window"`
+			Keys           []struct {
+				ID         string    `yaml:"kid"`
+				Algorithm  string    `yaml:"algorithm"`
+				PrivateKey string    `yaml:"private_key"`
+				PublicKey  string    `yaml:"public_key"`
+				RetiredAt  time.Time `yaml:"retired_at"`
+			} `yaml:"keys"`
+		} `yaml:"signing"`
+		LoginThrottle struct {
+			MaxAttempts        int           `yaml:"max_attempts"`
+			MaxAccountAttempts int           `yaml:"max_account_attempts"`
+			Lockout            time.Duration `yaml:"lockout"`
+			MaxLockout         time.Duration `yaml:"max_lockout"`
+			Decay              time.Duration `yaml:"decay"`
+		} `yaml:"login_throttle"`
+		TwoFactor struct {
+			Challenge time.Duration `yaml:"challenge"`
+		} `yaml:"two_factor"`
+		OAuth struct {
+			Providers map[string]struct {
+				ClientID     string   `yaml:"client_id"`
+				ClientSecret string   `yaml:"client_secret"`
+				RedirectURL  string   `yaml:"redirect_url"`
+				Scopes       []string `yaml:"scopes"`
+				Issuer       string   `yaml:"issuer"`
+				Tenant       string   `yaml:"tenant"`
+				AuthURL      string   `yaml:"auth_url"`
+				TokenURL     string   `yaml:"token_url"`
+				UserInfoURL  string   `yaml:"userinfo_url"`
+			} `yaml:"providers"`
+		} `yaml:"oauth"`
 	} `yaml:"auth"`
+	Hashing struct {
+		Driver     string `yaml:"driver"`
+		BcryptCost int    `yaml:"bcrypt_cost"`
+		Argon2     struct {
+			Memory      uint32 `yaml:"memory"`
+			Iterations  uint32 `yaml:"iterations"`
+			Parallelism uint8  `yaml:"parallelism"`
+		} `yaml:"argon2"`
+	} `yaml:"hashing"`
+	Session struct {
+		Driver      string        `yaml:"driver"`
+		Cookie      string        `yaml:"cookie"`
+		IdleTimeout time.Duration `yaml:"idle_timeout"`
+		Lifetime    time.Duration `yaml:"lifetime"`
+		Secure      bool          `yaml:"secure"`
+		Secret      string        `yaml:"secret"`
+		Path        string        `yaml:"path"`
+		GCInterval  time.Duration `yaml:"gc_interval"`
+	} `yaml:"session"`
+	Mail struct {
+		Driver string `yaml:"driver"`
+		From   string `yaml:"from"`
+		Path   string `yaml:"path"`
+	} `yaml:"mail"`
 	RateLimit struct {
 		Requests int           `yaml:"requests"`
 		Period   time.Duration `yaml:"period"`
 	} `yaml:"rateLimit"`
 	App struct {
-		Name string           `yaml:"name"`
-		Version  string		  `yaml:"version"`
+		Name    string `yaml:"name"`
+		Version string `yaml:"version"`
+		URL     string `yaml:"url"`
 	} `yaml:"app"`
 }
 
@@ -10,6 +10,7 @@ import (
 // WebController handles web requests
 type WebController struct {
 	// Add your dependencies here
+	loginProviders []string
 }
 
 // NewWebController creates a new web controller
@@ -17,6 +18,11 @@ func NewWebController() *WebController {
 	return &WebController{}
 }
 
+// SetLoginProviders lists the external providers offered on the login page
+func (c *WebController) SetLoginProviders(names []string) {
+	c.loginProviders = names
+}
+
 // Home handles the home page
 func (c *WebController) Home(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
@@ -47,6 +53,47 @@ func (c *WebController) Contact(w http.ResponseWriter, r *http.Request) {
 	view.Render(w, "pages/contact", data)
 }
 
+// Login handles the login page
+func (c *WebController) Login(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"title":       "Login",
+		"currentYear": time.Now().Year(),
+		"redirect":    r.URL.Query().Get("redirect"),
+		"error":       r.URL.Query().Get("error"),
+		"magicSent":   r.URL.Query().Get("magic") == "sent",
+		"reset":       r.URL.Query().Get("reset") != "",
+		"providers":   c.loginProviders,
+	}
+
+	view.Render(w, "pages/login", data)
+}
+
+// TwoFactorChallenge handles the page asking for a 2FA code during login
+func (c *WebController) TwoFactorChallenge(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"title":       "Two-Factor Authentication",
+		"currentYear": time.Now().Year(),
+		"redirect":    r.URL.Query().Get("redirect"),
+		"error":       r.URL.Query().Get("error") != "",
+	}
+
+	view.Render(w, "pages/two-factor-challenge", data)
+}
+
+// ResetPassword handles the page a password reset link opens. The form posts
+// the token and email from the link back with the new password.
+func (c *WebController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"title":       "Reset Password",
+		"currentYear": time.Now().Year(),
+		"token":       r.URL.Query().Get("token"),
+		"email":       r.URL.Query().Get("email"),
+		"error":       r.URL.Query().Get("error"),
+	}
+
+	view.Render(w, "pages/reset-password", data)
+}
+
 // NotFound handles 404 errors
 func (c *WebController) NotFound(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
@@ -1,65 +1,97 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	_ "github.com/go-sql-driver/mysql"
 	"errors"
 	"fmt"
+	"github.com/example/goframe/config"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+	"net/url"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
 type DatabaseConfig struct {
-	Driver   string
+	Driver   string // "mysql", "postgres" or "sqlite"
 	Host     string
 	Port     int
-	Name     string
+	Name     string // For SQLite, a file path or ":memory:"
 	User     string
 	Password string
+
+	// Connection pool; zero values keep the database/sql defaults
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// Connection options, ignored by SQLite except for Params
+	TLS      string            // MySQL tls ("true", "skip-verify", "preferred"); Postgres sslmode, default "disable"
+	Timezone string            // MySQL loc or Postgres TimeZone, e.g. "UTC"
+	Charset  string            // MySQL charset or Postgres client_encoding
+	Params   map[string]string // Any other driver-specific DSN parameters
+
+	// How many times Transaction reruns after a deadlock or serialization failure
+	TransactionRetries int
+}
+
+// ConfigFrom returns the connection settings from the application config
+func ConfigFrom(cfg *config.Config) *DatabaseConfig {
+	return &DatabaseConfig{
+		Driver:   cfg.Database.Driver,
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		Name:     cfg.Database.Name,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+
+		MaxOpenConns:    cfg.Database.Pool.MaxOpen,
+		MaxIdleConns:    cfg.Database.Pool.MaxIdle,
+		ConnMaxLifetime: cfg.Database.Pool.MaxLifetime,
+		ConnMaxIdleTime: cfg.Database.Pool.MaxIdleTime,
+
+		TLS:      cfg.Database.TLS,
+		Timezone: cfg.Database.Timezone,
+		Charset:  cfg.Database.Charset,
+		Params:   cfg.Database.Params,
+
+		TransactionRetries: cfg.Database.TransactionRetries,
+	}
 }
 
 type Database struct {
-	config DatabaseConfig
-	db     *sql.DB
+	config  DatabaseConfig
+	db      *sql.DB
+	dialect Dialect
 }
 
-// Connect creates a new database connection
+// Connect opens a connection to the configured database in place of db's
+// current one, with the same pool settings as NewDatabase
 func (db *Database) Connect(config DatabaseConfig) (*Database, error) {
-	var err error
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.Name)
-
-	db.db, err = sql.Open(config.Driver, dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %v", err)
-	}
-
-	// Test the connection
-	err = db.db.Ping()
+	connected, err := NewDatabase(&config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to ping database: %v", err)
+		return nil, err
 	}
-
-	db.config = config
+	*db = *connected
 	return db, nil
 }
 
+// NewDatabase opens and pings a database, applying the configured pool settings
 func NewDatabase(cfg *DatabaseConfig) (*Database, error) {
+	dialect, err := DialectFor(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create connection string based on driver
-	var dsn string
-	switch cfg.Driver {
-	case "mysql":
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
-			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
-	case "postgres":
-		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name)
-	default:
-		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	dsn, err := buildDSN(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	// Open database connection
@@ -69,16 +101,141 @@ func NewDatabase(cfg *DatabaseConfig) (*Database, error) {
 	}
 
 	// Configure connection pool
-	// sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
-	// sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
-	// sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	// Every connection to :memory: opens a separate, empty database, so the
+	// one connection must never be closed
+	if cfg.Driver == "sqlite" && cfg.Name == ":memory:" {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
 
 	// Verify connection
 	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &Database{db: sqlDB}, nil
+	return &Database{config: *cfg, db: sqlDB, dialect: dialect}, nil
+}
+
+// buildDSN returns the connection string for cfg's driver
+func buildDSN(cfg *DatabaseConfig) (string, error) {
+	switch cfg.Driver {
+	case "mysql":
+		params := url.Values{"parseTime": {"true"}}
+		setParam(params, "tls", cfg.TLS)
+		setParam(params, "loc", cfg.Timezone)
+		setParam(params, "charset", cfg.Charset)
+		for key, value := range cfg.Params {
+			params.Set(key, value)
+		}
+		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?%s",
+			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name, params.Encode()), nil
+	case "postgres":
+		params := map[string]string{
+			"host":     cfg.Host,
+			"port":     fmt.Sprint(cfg.Port),
+			"user":     cfg.User,
+			"password": cfg.Password,
+			"dbname":   cfg.Name,
+			"sslmode":  "disable",
+		}
+		if cfg.TLS != "" {
+			params["sslmode"] = cfg.TLS
+		}
+		if cfg.Timezone != "" {
+			params["TimeZone"] = cfg.Timezone
+		}
+		if cfg.Charset != "" {
+			params["client_encoding"] = cfg.Charset
+		}
+		for key, value := range cfg.Params {
+			params[key] = value
+		}
+		return postgresDSN(params), nil
+	case "sqlite":
+		return sqliteDSN(cfg.Name, cfg.Params), nil
+	default:
+		return "", fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+}
+
+// setParam sets key in params unless value is empty
+func setParam(params url.Values, key, value string) {
+	if value != "" {
+		params.Set(key, value)
+	}
+}
+
+// postgresDSN returns a keyword/value connection string, quoting values so
+// that passwords with spaces or quotes survive
+func postgresDSN(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(params[key])
+		pairs[i] = fmt.Sprintf("%s='%s'", key, value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// sqliteDSN returns the DSN for a SQLite file or ":memory:". Foreign keys are
+// enforced, and file databases use WAL and wait for locks instead of failing.
+func sqliteDSN(name string, extra map[string]string) string {
+	pragmas := "_pragma=foreign_keys(1)&_time_format=sqlite"
+	if len(extra) > 0 {
+		params := url.Values{}
+		for key, value := range extra {
+			params.Set(key, value)
+		}
+		pragmas += "&" + params.Encode()
+	}
+	if name == ":memory:" {
+		return ":memory:?" + pragmas
+	}
+	return "file:" + name + "?" + pragmas + "&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
+
+// Ping checks that the database is reachable, giving up when ctx is done
+func (db *Database) Ping(ctx context.Context) error {
+	if db.db == nil {
+		return errors.New("database is not connected")
+	}
+	return db.db.PingContext(ctx)
+}
+
+// Stats returns the connection pool statistics
+func (db *Database) Stats() sql.DBStats {
+	if db.db == nil {
+		return sql.DBStats{}
+	}
+	return db.db.Stats()
+}
+
+// Dialect returns the SQL dialect of the connected database
+func (db *Database) Dialect() Dialect {
+	if db.dialect == nil {
+		return MySQLDialect{}
+	}
+	return db.dialect
 }
 
 // Close closes the database connection
@@ -91,60 +248,101 @@ func (db *Database) Close() error {
 
 // Model represents a database model
 type Model struct {
-	ID uint `db:"id" json:"id"`
+	ID uint `db:"id,pk,autoincr" json:"id"`
 }
 
 // Create inserts a new record into the database
 func (db *Database) Create(value interface{}) error {
-	// Get table name from type
-	tableName := strings.ToLower(reflect.TypeOf(value).Name())
-
-	// Get fields and values using reflection
-	val := reflect.ValueOf(value).Elem()
-	typ := val.Type()
-
-	var fields []string
-	var placeholders []string
-	var values []interface{}
-
-	for i := 0; i < val.NumField(); i++ {
-		field := typ.Field(i)
-		dbTag := field.Tag.Get("db")
-		if dbTag == "" || dbTag == "-" {
-			continue
-		}
+	return db.CreateContext(context.Background(), value)
+}
+
+// CreateContext inserts a new record into the database, cancelling the
+// insert when ctx is done
+func (db *Database) CreateContext(ctx context.Context, value interface{}) error {
+	return create(ctx, db, value)
+}
+
+// create inserts value, a pointer to a model, through e. An auto-increment
+// primary key left at zero is assigned by the database and read back.
+func create(ctx context.Context, e Executor, value interface{}) error {
+	meta, err := modelOf(value)
+	if err != nil {
+		return err
+	}
+
+	val := reflect.Indirect(reflect.ValueOf(value))
+	fields, values := meta.writable(val, true)
 
-		fields = append(fields, dbTag)
-		placeholders = append(placeholders, "?")
-		values = append(values, val.Field(i).Interface())
+	d := e.Dialect()
+	quoted := make([]string, len(fields))
+	placeholders := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = d.Quote(field)
+		placeholders[i] = "?"
 	}
 
 	query := fmt.Sprintf(
 		"INSERT INTO %s (%s) VALUES (%s)",
-		tableName,
-		strings.Join(fields, ", "),
+		d.Quote(meta.table),
+		strings.Join(quoted, ", "),
 		strings.Join(placeholders, ", "),
 	)
 
-	_, err := db.db.Exec(query, values...)
-	return err
+	if meta.pk == nil || !meta.pk.autoIncrement {
+		return e.ExecContext(ctx, query, values...)
+	}
+	pk := val.FieldByIndex(meta.pk.index)
+	if !pk.IsZero() {
+		return e.ExecContext(ctx, query, values...)
+	}
+
+	id, err := insertGetID(ctx, e, query, values, meta.pk.column)
+	if err != nil {
+		return err
+	}
+	return setInteger(pk, id)
+}
+
+// insertGetID runs an INSERT and returns the ID the database generated for
+// column, using RETURNING where the dialect has it and LastInsertId otherwise
+func insertGetID(ctx context.Context, e Executor, query string, args []interface{}, column string) (int64, error) {
+	d := e.Dialect()
+	if d.SupportsReturning() {
+		var id int64
+		err := e.QueryRowContext(ctx, query+" RETURNING "+d.Quote(column), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := e.ExecResultContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
 }
 
 // Query represents a database query
 type Query struct {
-	db         *Database
+	db         Executor
+	ctx        context.Context
 	model      interface{}
 	conditions []string
 	values     []interface{}
 	limit      int
 	offset     int
 	orderBy    string
+	eager      []*eagerLoad
+	counts     []string
 }
 
 // Table creates a new query for the given model
 func (db *Database) Table(model interface{}) *Query {
+	return newQuery(db, model)
+}
+
+// newQuery creates a query for model that runs through e
+func newQuery(e Executor, model interface{}) *Query {
 	return &Query{
-		db:    db,
+		db:    e,
 		model: model,
 	}
 }
@@ -156,6 +354,20 @@ func (q *Query) Where(condition string, values ...interface{}) *Query {
 	return q
 }
 
+// WithContext runs the query under ctx, so it is cancelled when ctx is done
+func (q *Query) WithContext(ctx context.Context) *Query {
+	q.ctx = ctx
+	return q
+}
+
+// context returns the query's context, defaulting to context.Background()
+func (q *Query) context() context.Context {
+	if q.ctx == nil {
+		return context.Background()
+	}
+	return q.ctx
+}
+
 // Limit sets the limit for the query
 func (q *Query) Limit(limit int) *Query {
 	q.limit = limit
@@ -174,10 +386,42 @@ func (q *Query) OrderBy(orderBy string) *Query {
 	return q
 }
 
-// Find executes the query and scans the result into dest
+// With eager loads the named relations of the models Find returns, batching
+// each relation into one query for all of them. A dotted path such as
+// "Comments.User" loads Comments and then User on every comment loaded.
+func (q *Query) With(relations ...string) *Query {
+	for _, path := range relations {
+		q.eager = addEagerLoad(q.eager, path, nil)
+	}
+	return q
+}
+
+// WithConstraint eager loads a relation path, like With, letting constrain
+// narrow or order the query that loads its last relation. That query covers
+// every model Find returns, so constrain must not call Limit or Offset; Find
+// fails with ErrEagerLimit if it does.
+func (q *Query) WithConstraint(path string, constrain func(q *QueryBuilder)) *Query {
+	q.eager = addEagerLoad(q.eager, path, constrain)
+	return q
+}
+
+// WithCount counts the related models of each model Find returns, without
+// loading them, into its <Relation>Count field, such as CommentsCount for
+// "Comments". The field is an integer without a db tag.
+func (q *Query) WithCount(relations ...string) *Query {
+	q.counts = append(q.counts, relations...)
+	return q
+}
+
+// Find executes the query and scans the result into dest, a pointer to a
+// slice of models or to a single model, then loads the relations and counts
+// asked for with With and WithCount
 func (q *Query) Find(dest interface{}) error {
-	tableName := strings.ToLower(reflect.TypeOf(q.model).Name())
-	query := fmt.Sprintf("SELECT * FROM %s", tableName)
+	meta, err := modelOf(q.model)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("SELECT * FROM %s", q.db.Dialect().Quote(meta.table))
 
 	if len(q.conditions) > 0 {
 		query += " WHERE " + strings.Join(q.conditions, " AND ")
@@ -187,24 +431,33 @@ func (q *Query) Find(dest interface{}) error {
 		query += " ORDER BY " + q.orderBy
 	}
 
-	if q.limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", q.limit)
-	}
+	query += q.db.Dialect().LimitOffset(q.limit, q.offset)
 
-	if q.offset > 0 {
-		query += fmt.Sprintf(" OFFSET %d", q.offset)
+	rows, err := q.db.QueryContext(q.context(), query, q.values...)
+	if err != nil {
+		return err
 	}
-
-	rows, err := q.db.db.Query(query, q.values...)
+	// The rows are closed before loading relations, which may otherwise
+	// wait on the connection they hold
+	err = rowsScan(rows, dest)
+	rows.Close()
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
+	hydrate(q.db, dest)
 
-	return rowsScan(rows, dest)
+	if len(q.eager) == 0 && len(q.counts) == 0 {
+		return nil
+	}
+	models := modelValues(reflect.ValueOf(dest))
+	if err := loadEager(q.context(), q.db, meta, models, q.eager); err != nil {
+		return err
+	}
+	return loadCounts(q.context(), q.db, meta, models, q.counts)
 }
 
-// First executes the query and scans the first result into dest
+// First executes the query and scans the first result into dest, a pointer
+// to a model. It returns sql.ErrNoRows when nothing matches.
 func (q *Query) First(dest interface{}) error {
 	q.Limit(1)
 	return q.Find(dest)
@@ -216,164 +469,133 @@ func (q *Query) Update(values map[string]interface{}) error {
 		return errors.New("no values provided for update")
 	}
 
-	tableName := strings.ToLower(reflect.TypeOf(q.model).Name())
+	meta, err := modelOf(q.model)
+	if err != nil {
+		return err
+	}
+	d := q.db.Dialect()
 	var setClauses []string
 	var updateValues []interface{}
 
-	for field, value := range values {
-		setClauses = append(setClauses, fmt.Sprintf("%s = ?", field))
-		updateValues = append(updateValues, value)
+	for _, field := range sortedColumns(values) {
+		setClauses = append(setClauses, fmt.Sprintf("%s = ?", d.Quote(field)))
+		updateValues = append(updateValues, values[field])
 	}
 
-	query := fmt.Sprintf("UPDATE %s SET %s", tableName, strings.Join(setClauses, ", "))
+	query := fmt.Sprintf("UPDATE %s SET %s", d.Quote(meta.table), strings.Join(setClauses, ", "))
 
 	if len(q.conditions) > 0 {
 		query += " WHERE " + strings.Join(q.conditions, " AND ")
 		updateValues = append(updateValues, q.values...)
 	}
 
-	_, err := q.db.db.Exec(query, updateValues...)
-	return err
+	return q.db.ExecContext(q.context(), query, updateValues...)
 }
 
 // Delete deletes records matching the query
 func (q *Query) Delete() error {
-	tableName := strings.ToLower(reflect.TypeOf(q.model).Name())
-	query := fmt.Sprintf("DELETE FROM %s", tableName)
+	meta, err := modelOf(q.model)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("DELETE FROM %s", q.db.Dialect().Quote(meta.table))
 
 	if len(q.conditions) > 0 {
 		query += " WHERE " + strings.Join(q.conditions, " AND ")
 	}
 
-	_, err := q.db.db.Exec(query, q.values...)
-	return err
+	return q.db.ExecContext(q.context(), query, q.values...)
 }
 
-// Transaction represents a database transaction
-type Transaction struct {
-	db *Database
-	tx *sql.Tx
-}
-
-// Begin starts a new transaction
-func (db *Database) Begin() (*Transaction, error) {
-	tx, err := db.db.Begin()
-	if err != nil {
-		return nil, err
-	}
-	return &Transaction{db: db, tx: tx}, nil
+// Exec executes a SQL query and returns only error. Placeholders are written
+// as ? and rebound for the database's dialect.
+func (db *Database) Exec(query string, args ...interface{}) error {
+	return db.ExecContext(context.Background(), query, args...)
 }
 
-// Commit commits the transaction
-func (tx *Transaction) Commit() error {
-	return tx.tx.Commit()
+// ExecContext is Exec, cancelled when ctx is done
+func (db *Database) ExecContext(ctx context.Context, query string, args ...interface{}) error {
+	_, err := db.ExecResultContext(ctx, query, args...)
+	return err
 }
 
-// Rollback rolls back the transaction
-func (tx *Transaction) Rollback() error {
-	return tx.tx.Rollback()
-}
-
-// Exec executes a SQL query and returns only error
-func (db *Database) Exec(query string, args ...interface{}) error {
-	_, err := db.db.Exec(query, args...)
-	return err
+// ExecResultContext is ExecContext, returning the sql.Result for the number
+// of affected rows or the last insert ID
+func (db *Database) ExecResultContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.db.ExecContext(ctx, db.Dialect().Rebind(query), args...)
 }
 
 // Query executes a SQL query that returns rows
 func (db *Database) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return db.db.Query(query, args...)
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is Query, cancelled when ctx is done
+func (db *Database) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.db.QueryContext(ctx, db.Dialect().Rebind(query), args...)
 }
 
 // QueryRow executes a SQL query that returns at most one row
 func (db *Database) QueryRow(query string, args ...interface{}) *sql.Row {
-	return db.db.QueryRow(query, args...)
+	return db.QueryRowContext(context.Background(), query, args...)
 }
 
-// scanRows scans database rows into a destination slice
-func (db *Database) scanRows(rows *sql.Rows, dest interface{}) error {
+// QueryRowContext is QueryRow, cancelled when ctx is done
+func (db *Database) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.db.QueryRowContext(ctx, db.Dialect().Rebind(query), args...)
+}
+
+// rowsScan scans rows into dest: a pointer to a slice of models, or of
+// pointers to models, or a pointer to a single model, which gets the first
+// row or sql.ErrNoRows. Columns match fields, including those of embedded
+// structs, by their db tag.
+func rowsScan(rows *sql.Rows, dest interface{}) error {
 	destVal := reflect.ValueOf(dest)
-	if destVal.Kind() != reflect.Ptr || destVal.Elem().Kind() != reflect.Slice {
-		return errors.New("dest must be a pointer to a slice")
+	if destVal.Kind() != reflect.Ptr || destVal.IsNil() {
+		return errors.New("dest must be a pointer to a slice or struct")
 	}
+	target := destVal.Elem()
 
-	sliceVal := destVal.Elem()
-	elemType := sliceVal.Type().Elem()
-
+	meta, err := modelOfType(target.Type())
+	if err != nil {
+		return err
+	}
 	columns, err := rows.Columns()
 	if err != nil {
 		return err
 	}
 
-	for rows.Next() {
-		elem := reflect.New(elemType).Elem()
-		fields := make([]interface{}, len(columns))
-
-		for i := 0; i < elem.NumField(); i++ {
-			field := elem.Type().Field(i)
-			dbTag := field.Tag.Get("db")
-			if dbTag == "" {
-				continue
-			}
-
-			for j, col := range columns {
-				if strings.EqualFold(col, dbTag) {
-					fields[j] = elem.Field(i).Addr().Interface()
-					break
-				}
+	if target.Kind() == reflect.Struct {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return err
 			}
+			return sql.ErrNoRows
 		}
-
-		if err := rows.Scan(fields...); err != nil {
-			return err
-		}
-
-		sliceVal.Set(reflect.Append(sliceVal, elem))
+		return scanStruct(rows, columns, meta, target)
 	}
-
-	return rows.Err()
-}
-
-// scanRows scans database rows into a destination slice
-func rowsScan(rows *sql.Rows, dest interface{}) error {
-	destVal := reflect.ValueOf(dest)
-	if destVal.Kind() != reflect.Ptr || destVal.Elem().Kind() != reflect.Slice {
-		return errors.New("dest must be a pointer to a slice")
+	if target.Kind() != reflect.Slice {
+		return errors.New("dest must be a pointer to a slice or struct")
 	}
 
-	sliceVal := destVal.Elem()
-	elemType := sliceVal.Type().Elem()
-
-	columns, err := rows.Columns()
-	if err != nil {
-		return err
+	elemType := target.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
 	}
 
 	for rows.Next() {
-		elem := reflect.New(elemType).Elem()
-		fields := make([]interface{}, len(columns))
-
-		for i := 0; i < elem.NumField(); i++ {
-			field := elem.Type().Field(i)
-			dbTag := field.Tag.Get("db")
-			if dbTag == "" {
-				continue
-			}
-
-			for j, col := range columns {
-				if strings.EqualFold(col, dbTag) {
-					fields[j] = elem.Field(i).Addr().Interface()
-					break
-				}
-			}
-		}
-
-		if err := rows.Scan(fields...); err != nil {
+		elem := reflect.New(elemType)
+		if err := scanStruct(rows, columns, meta, elem.Elem()); err != nil {
 			return err
 		}
 
-		sliceVal.Set(reflect.Append(sliceVal, elem))
+		if isPtr {
+			target.Set(reflect.Append(target, elem))
+		} else {
+			target.Set(reflect.Append(target, elem.Elem()))
+		}
 	}
 
 	return rows.Err()
-}
\ No newline at end of file
+}
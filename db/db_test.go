@@ -0,0 +1,106 @@
+package db
+
+import (
+	"testing"
+)
+
+// newTestDatabase opens an empty in-memory SQLite database
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	database, err := NewDatabase(&DatabaseConfig{Driver: "sqlite", Name: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// widget is a model whose status is filled in by a column default
+type widget struct {
+	Entity
+	Name   string `db:"name"`
+	Status string `db:"status,omitempty"`
+}
+
+func createWidgets(t *testing.T, database *Database) {
+	t.Helper()
+	err := NewSchema(database).Create("widgets", func(table *Blueprint) {
+		table.ID()
+		table.String("name", 100)
+		table.String("status", 20).Default("draft")
+		table.Timestamps()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateReadsBackID(t *testing.T) {
+	database := newTestDatabase(t)
+	createWidgets(t, database)
+	widgets := NewRepository[widget](database)
+
+	for i, name := range []string{"first", "second"} {
+		w := &widget{Name: name}
+		if err := widgets.Create(w); err != nil {
+			t.Fatal(err)
+		}
+		if w.ID != uint(i+1) {
+			t.Errorf("%s: ID = %d, want %d", name, w.ID, i+1)
+		}
+	}
+
+	// An ID set by the caller is inserted as is
+	w := &widget{Name: "chosen"}
+	w.ID = 10
+	if err := widgets.Create(w); err != nil {
+		t.Fatal(err)
+	}
+	var found widget
+	if err := widgets.FindByID(10, &found); err != nil || found.Name != "chosen" {
+		t.Errorf("FindByID(10) = %+v, %v", found, err)
+	}
+}
+
+func TestRefreshReadsColumnDefaults(t *testing.T) {
+	database := newTestDatabase(t)
+	createWidgets(t, database)
+	widgets := NewRepository[widget](database)
+
+	w := &widget{Name: "fresh"}
+	if err := widgets.Create(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != "" {
+		t.Fatalf("status before refresh = %q", w.Status)
+	}
+
+	if err := widgets.Refresh(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != "draft" || w.Name != "fresh" {
+		t.Errorf("after refresh: %+v", w)
+	}
+}
+
+func TestConnectAppliesPoolSettings(t *testing.T) {
+	database, err := (&Database{}).Connect(DatabaseConfig{Driver: "sqlite", Name: ":memory:", MaxOpenConns: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	// An in-memory database is pinned to one connection so every query sees it
+	if n := database.Stats().MaxOpenConnections; n != 1 {
+		t.Errorf("in-memory MaxOpenConnections = %d, want 1", n)
+	}
+
+	file, err := (&Database{}).Connect(DatabaseConfig{Driver: "sqlite", Name: t.TempDir() + "/app.db", MaxOpenConns: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if n := file.Stats().MaxOpenConnections; n != 5 {
+		t.Errorf("file MaxOpenConnections = %d, want 5", n)
+	}
+}
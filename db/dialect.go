@@ -0,0 +1,367 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect hides the differences between the SQL spoken by each supported
+// database. Queries throughout the package are written with ? placeholders
+// and rebound by the dialect just before they run.
+type Dialect interface {
+	// Name returns the driver name the dialect is registered under
+	Name() string
+
+	// Rebind rewrites ? placeholders into the dialect's own style, leaving
+	// question marks inside quoted strings and identifiers alone
+	Rebind(query string) string
+
+	// Quote quotes an identifier, such as a table or column name. Dotted
+	// names are quoted part by part and * is left as is.
+	Quote(identifier string) string
+
+	// LimitOffset returns the LIMIT/OFFSET clause, with a leading space, or
+	// an empty string when both are zero
+	LimitOffset(limit, offset int) string
+
+	// SupportsReturning reports whether INSERT ... RETURNING is available
+	SupportsReturning() bool
+
+	// Upsert returns an INSERT of columns into table that updates the update
+	// columns when a row with the same conflict columns already exists. With
+	// no update columns the conflicting row is left untouched.
+	Upsert(table string, columns, conflict, update []string) string
+
+	// ColumnType returns the SQL type for a schema column
+	ColumnType(column Column) string
+
+	// IsRetryable reports whether err is a deadlock or serialization failure,
+	// after which the whole transaction can be run again
+	IsRetryable(err error) bool
+}
+
+// DialectFor returns the dialect for a database driver
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case "mysql":
+		return MySQLDialect{}, nil
+	case "postgres":
+		return PostgresDialect{}, nil
+	case "sqlite":
+		return SQLiteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+// MySQLDialect speaks MySQL and MariaDB
+type MySQLDialect struct{}
+
+// Name returns "mysql"
+func (MySQLDialect) Name() string { return "mysql" }
+
+// Rebind returns query unchanged, since MySQL uses ? placeholders
+func (MySQLDialect) Rebind(query string) string { return query }
+
+// Quote quotes identifier with backticks
+func (MySQLDialect) Quote(identifier string) string { return quoteWith(identifier, '`') }
+
+// LimitOffset returns a LIMIT clause. MySQL has no OFFSET without LIMIT, so
+// an offset alone is paired with the largest possible limit.
+func (MySQLDialect) LimitOffset(limit, offset int) string {
+	switch {
+	case limit > 0 && offset > 0:
+		return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	case limit > 0:
+		return fmt.Sprintf(" LIMIT %d", limit)
+	case offset > 0:
+		return fmt.Sprintf(" LIMIT 18446744073709551615 OFFSET %d", offset)
+	}
+	return ""
+}
+
+// SupportsReturning returns false
+func (MySQLDialect) SupportsReturning() bool { return false }
+
+// Upsert uses ON DUPLICATE KEY UPDATE, which applies to every unique key
+// rather than just conflict
+func (d MySQLDialect) Upsert(table string, columns, conflict, update []string) string {
+	if len(update) == 0 {
+		return "INSERT IGNORE" + strings.TrimPrefix(insertSQL(d, table, columns), "INSERT")
+	}
+
+	sets := make([]string, len(update))
+	for i, column := range update {
+		sets[i] = fmt.Sprintf("%s = VALUES(%s)", d.Quote(column), d.Quote(column))
+	}
+	return insertSQL(d, table, columns) + " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// ColumnType maps a schema column to a MySQL type
+func (MySQLDialect) ColumnType(column Column) string {
+	var typ string
+	switch column.columnType {
+	case "string":
+		typ = fmt.Sprintf("VARCHAR(%d)", column.length)
+	case "text":
+		typ = "TEXT"
+	case "integer":
+		typ = "INT"
+	case "bigInteger":
+		typ = "BIGINT"
+	case "boolean":
+		typ = "TINYINT(1)"
+	case "date":
+		typ = "DATE"
+	case "dateTime":
+		typ = "DATETIME"
+	case "decimal":
+		typ = fmt.Sprintf("DECIMAL(%d,%d)", column.precision, column.scale)
+	case "float":
+		typ = "DOUBLE"
+	case "json":
+		typ = "JSON"
+	default:
+		typ = strings.ToUpper(column.columnType)
+	}
+
+	if column.unsigned || column.autoIncrement {
+		typ += " UNSIGNED"
+	}
+	if column.autoIncrement {
+		typ += " AUTO_INCREMENT"
+	}
+	return typ
+}
+
+// IsRetryable matches deadlocks (1213) and lock wait timeouts (1205)
+func (MySQLDialect) IsRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205)
+}
+
+// PostgresDialect speaks PostgreSQL
+type PostgresDialect struct{}
+
+// Name returns "postgres"
+func (PostgresDialect) Name() string { return "postgres" }
+
+// Rebind numbers placeholders $1, $2, ...
+func (PostgresDialect) Rebind(query string) string {
+	n := 0
+	return rebind(query, func() string {
+		n++
+		return "$" + strconv.Itoa(n)
+	})
+}
+
+// Quote quotes identifier with double quotes
+func (PostgresDialect) Quote(identifier string) string { return quoteWith(identifier, '"') }
+
+// LimitOffset returns a LIMIT and/or OFFSET clause
+func (PostgresDialect) LimitOffset(limit, offset int) string { return limitOffset(limit, offset) }
+
+// SupportsReturning returns true
+func (PostgresDialect) SupportsReturning() bool { return true }
+
+// Upsert uses ON CONFLICT
+func (d PostgresDialect) Upsert(table string, columns, conflict, update []string) string {
+	return onConflict(d, table, columns, conflict, update)
+}
+
+// ColumnType maps a schema column to a PostgreSQL type. Auto-incrementing
+// columns become SERIAL or BIGSERIAL; unsigned has no equivalent and is ignored.
+func (PostgresDialect) ColumnType(column Column) string {
+	switch column.columnType {
+	case "string":
+		return fmt.Sprintf("VARCHAR(%d)", column.length)
+	case "text":
+		return "TEXT"
+	case "integer":
+		if column.autoIncrement {
+			return "SERIAL"
+		}
+		return "INTEGER"
+	case "bigInteger":
+		if column.autoIncrement {
+			return "BIGSERIAL"
+		}
+		return "BIGINT"
+	case "boolean":
+		return "BOOLEAN"
+	case "date":
+		return "DATE"
+	case "dateTime":
+		return "TIMESTAMP"
+	case "decimal":
+		return fmt.Sprintf("DECIMAL(%d,%d)", column.precision, column.scale)
+	case "float":
+		return "DOUBLE PRECISION"
+	case "json":
+		return "JSONB"
+	}
+	return strings.ToUpper(column.columnType)
+}
+
+// IsRetryable matches serialization failures (40001) and deadlocks (40P01)
+func (PostgresDialect) IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
+
+// SQLiteDialect speaks SQLite
+type SQLiteDialect struct{}
+
+// Name returns "sqlite"
+func (SQLiteDialect) Name() string { return "sqlite" }
+
+// Rebind returns query unchanged, since SQLite accepts ? placeholders
+func (SQLiteDialect) Rebind(query string) string { return query }
+
+// Quote quotes identifier with double quotes
+func (SQLiteDialect) Quote(identifier string) string { return quoteWith(identifier, '"') }
+
+// LimitOffset returns a LIMIT clause. SQLite has no OFFSET without LIMIT, so
+// an offset alone is paired with LIMIT -1.
+func (SQLiteDialect) LimitOffset(limit, offset int) string {
+	if limit <= 0 && offset > 0 {
+		return fmt.Sprintf(" LIMIT -1 OFFSET %d", offset)
+	}
+	return limitOffset(limit, offset)
+}
+
+// SupportsReturning returns true; RETURNING needs SQLite 3.35 or later
+func (SQLiteDialect) SupportsReturning() bool { return true }
+
+// Upsert uses ON CONFLICT
+func (d SQLiteDialect) Upsert(table string, columns, conflict, update []string) string {
+	return onConflict(d, table, columns, conflict, update)
+}
+
+// ColumnType maps a schema column to a SQLite type. An auto-incrementing
+// column must be the table's INTEGER PRIMARY KEY, so it is declared inline.
+func (SQLiteDialect) ColumnType(column Column) string {
+	if column.autoIncrement {
+		return "INTEGER PRIMARY KEY AUTOINCREMENT"
+	}
+
+	switch column.columnType {
+	case "string":
+		return fmt.Sprintf("VARCHAR(%d)", column.length)
+	case "text", "json":
+		return "TEXT"
+	case "integer", "bigInteger":
+		return "INTEGER"
+	case "boolean":
+		return "BOOLEAN"
+	case "date":
+		return "DATE"
+	case "dateTime":
+		return "DATETIME"
+	case "decimal":
+		return fmt.Sprintf("DECIMAL(%d,%d)", column.precision, column.scale)
+	case "float":
+		return "REAL"
+	}
+	return strings.ToUpper(column.columnType)
+}
+
+// IsRetryable matches SQLITE_BUSY and SQLITE_LOCKED, including their
+// extended codes
+func (SQLiteDialect) IsRetryable(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// rebind replaces each ? outside quotes with the next placeholder
+func rebind(query string, next func() string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var out strings.Builder
+	var quote rune
+	for _, ch := range query {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?':
+			out.WriteString(next())
+			continue
+		}
+		out.WriteRune(ch)
+	}
+	return out.String()
+}
+
+// quoteWith quotes each part of a dotted identifier, doubling embedded quotes
+func quoteWith(identifier string, quote rune) string {
+	q := string(quote)
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		if part == "*" {
+			continue
+		}
+		parts[i] = q + strings.ReplaceAll(part, q, q+q) + q
+	}
+	return strings.Join(parts, ".")
+}
+
+// limitOffset returns the standard LIMIT and OFFSET clauses
+func limitOffset(limit, offset int) string {
+	var clause string
+	if limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return clause
+}
+
+// insertSQL returns an INSERT of columns into table with ? placeholders
+func insertSQL(d Dialect, table string, columns []string) string {
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = d.Quote(column)
+		placeholders[i] = "?"
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		d.Quote(table), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+}
+
+// onConflict builds an upsert with the ON CONFLICT clause shared by
+// PostgreSQL and SQLite
+func onConflict(d Dialect, table string, columns, conflict, update []string) string {
+	targets := make([]string, len(conflict))
+	for i, column := range conflict {
+		targets[i] = d.Quote(column)
+	}
+
+	query := insertSQL(d, table, columns) + " ON CONFLICT (" + strings.Join(targets, ", ") + ")"
+	if len(update) == 0 {
+		return query + " DO NOTHING"
+	}
+
+	sets := make([]string, len(update))
+	for i, column := range update {
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", d.Quote(column), d.Quote(column))
+	}
+	return query + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
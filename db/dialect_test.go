@@ -0,0 +1,120 @@
+package db
+
+import "testing"
+
+var dialects = []Dialect{MySQLDialect{}, PostgresDialect{}, SQLiteDialect{}}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT * FROM "a?" WHERE x = ? AND y = '?' AND z IN (?, ?)`
+	want := map[string]string{
+		"mysql":    query,
+		"postgres": `SELECT * FROM "a?" WHERE x = $1 AND y = '?' AND z IN ($2, $3)`,
+		"sqlite":   query,
+	}
+	for _, d := range dialects {
+		if got := d.Rebind(query); got != want[d.Name()] {
+			t.Errorf("%s: Rebind = %s", d.Name(), got)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		identifier   string
+		mysql, other string
+	}{
+		{"users", "`users`", `"users"`},
+		{"users.id", "`users`.`id`", `"users"."id"`},
+		{"users.*", "`users`.*", `"users".*`},
+		{"odd`\"name", "`odd``\"name`", "\"odd`\"\"name\""},
+	}
+	for _, tt := range tests {
+		for _, d := range dialects {
+			want := tt.other
+			if d.Name() == "mysql" {
+				want = tt.mysql
+			}
+			if got := d.Quote(tt.identifier); got != want {
+				t.Errorf("%s: Quote(%q) = %s, want %s", d.Name(), tt.identifier, got, want)
+			}
+		}
+	}
+}
+
+func TestLimitOffset(t *testing.T) {
+	tests := []struct {
+		limit, offset           int
+		mysql, postgres, sqlite string
+	}{
+		{0, 0, "", "", ""},
+		{10, 0, " LIMIT 10", " LIMIT 10", " LIMIT 10"},
+		{10, 20, " LIMIT 10 OFFSET 20", " LIMIT 10 OFFSET 20", " LIMIT 10 OFFSET 20"},
+		{0, 20, " LIMIT 18446744073709551615 OFFSET 20", " OFFSET 20", " LIMIT -1 OFFSET 20"},
+	}
+	for _, tt := range tests {
+		want := map[string]string{"mysql": tt.mysql, "postgres": tt.postgres, "sqlite": tt.sqlite}
+		for _, d := range dialects {
+			if got := d.LimitOffset(tt.limit, tt.offset); got != want[d.Name()] {
+				t.Errorf("%s: LimitOffset(%d, %d) = %q", d.Name(), tt.limit, tt.offset, got)
+			}
+		}
+	}
+}
+
+func TestUpsertSQL(t *testing.T) {
+	columns := []string{"key", "value"}
+	conflict := []string{"key"}
+
+	tests := []struct {
+		dialect Dialect
+		update  []string
+		want    string
+	}{
+		{MySQLDialect{}, []string{"value"}, "INSERT INTO `settings` (`key`, `value`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `value` = VALUES(`value`)"},
+		{MySQLDialect{}, nil, "INSERT IGNORE INTO `settings` (`key`, `value`) VALUES (?, ?)"},
+		{PostgresDialect{}, []string{"value"}, `INSERT INTO "settings" ("key", "value") VALUES (?, ?) ON CONFLICT ("key") DO UPDATE SET "value" = EXCLUDED."value"`},
+		{SQLiteDialect{}, nil, `INSERT INTO "settings" ("key", "value") VALUES (?, ?) ON CONFLICT ("key") DO NOTHING`},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.Upsert("settings", columns, conflict, tt.update); got != tt.want {
+			t.Errorf("%s: Upsert = %s", tt.dialect.Name(), got)
+		}
+	}
+}
+
+func TestUpsertRunsOnSQLite(t *testing.T) {
+	database := newTestDatabase(t)
+	err := NewSchema(database).Create("settings", func(table *Blueprint) {
+		table.String("key", 50).Unique()
+		table.String("value", 50)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := database.Dialect()
+	columns := []string{"key", "value"}
+	steps := []struct {
+		update []string
+		value  string
+		want   string
+	}{
+		{nil, "first", "first"},
+		{nil, "ignored", "first"},
+		{[]string{"value"}, "second", "second"},
+	}
+	for _, step := range steps {
+		query := d.Upsert("settings", columns, []string{"key"}, step.update)
+		if err := database.Exec(query, "theme", step.value); err != nil {
+			t.Fatal(err)
+		}
+
+		var value string
+		if err := database.QueryRow(`SELECT "value" FROM "settings" WHERE "key" = ?`, "theme").Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		if value != step.want {
+			t.Errorf("after upserting %q: value = %q, want %q", step.value, value, step.want)
+		}
+	}
+}
@@ -1,12 +1,11 @@
 package db
 
 import (
-	"errors"
 	"fmt"
 	"path/filepath"
-	// "plugin"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,6 +23,40 @@ type MigrationInterface interface {
 	Down(migrator *Migrator) error
 }
 
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]MigrationInterface)
+)
+
+// RegisterMigration makes a migration available to the Migrator under name,
+// its file name without the .go extension. Migrations register themselves
+// from an init function:
+//
+//	func init() {
+//		db.RegisterMigration("20230615120000_create_users_table", &Migration_20230615120000{})
+//	}
+func RegisterMigration(name string, migration MigrationInterface) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic("db: migration registered twice: " + name)
+	}
+	registry[name] = migration
+}
+
+// RegisteredMigrations returns the names of all registered migrations in the
+// order they run
+func RegisteredMigrations() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Migrator handles database migrations
 type Migrator struct {
 	db *Database
@@ -39,7 +72,8 @@ func (m *Migrator) DB() *Database {
 	return m.db
 }
 
-// RunMigrations runs all pending migrations
+// RunMigrations runs all pending migrations. files are migration file paths
+// or names, as returned by RegisteredMigrations.
 func (m *Migrator) RunMigrations(files []string) (int, error) {
 	// Create migrations table if it doesn't exist
 	if err := m.createMigrationsTable(); err != nil {
@@ -47,8 +81,8 @@ func (m *Migrator) RunMigrations(files []string) (int, error) {
 	}
 
 	// Get already run migrations
-	var migrations []Migration
-	if err := m.db.Table(Migration{}).Find(&migrations); err != nil {
+	migrations, err := m.ranMigrations()
+	if err != nil {
 		return 0, err
 	}
 
@@ -85,8 +119,7 @@ func (m *Migrator) RunMigrations(files []string) (int, error) {
 		}
 
 		// Record migration
-		name := filepath.Base(file)
-		if err := m.recordMigration(name, batch); err != nil {
+		if err := m.recordMigration(migrationName(file), batch); err != nil {
 			return count, err
 		}
 
@@ -99,8 +132,8 @@ func (m *Migrator) RunMigrations(files []string) (int, error) {
 // RollbackMigrations rolls back the last batch of migrations
 func (m *Migrator) RollbackMigrations(files []string, step int) (int, error) {
 	// Get already run migrations
-	var migrations []Migration
-	if err := m.db.Table(Migration{}).OrderBy("batch DESC, id DESC").Find(&migrations); err != nil {
+	migrations, err := m.ranMigrations()
+	if err != nil {
 		return 0, err
 	}
 
@@ -133,7 +166,7 @@ func (m *Migrator) RollbackMigrations(files []string, step int) (int, error) {
 			// Find migration file
 			var migrationFile string
 			for _, file := range files {
-				if filepath.Base(file) == migration.Name {
+				if migrationName(file) == migrationName(migration.Name) {
 					migrationFile = file
 					break
 				}
@@ -169,8 +202,8 @@ func (m *Migrator) RollbackMigrations(files []string, step int) (int, error) {
 // ResetMigrations rolls back all migrations
 func (m *Migrator) ResetMigrations(files []string) (int, error) {
 	// Get already run migrations
-	var migrations []Migration
-	if err := m.db.Table(Migration{}).OrderBy("batch DESC, id DESC").Find(&migrations); err != nil {
+	migrations, err := m.ranMigrations()
+	if err != nil {
 		return 0, err
 	}
 
@@ -184,7 +217,7 @@ func (m *Migrator) ResetMigrations(files []string) (int, error) {
 		// Find migration file
 		var migrationFile string
 		for _, file := range files {
-			if filepath.Base(file) == migration.Name {
+			if migrationName(file) == migrationName(migration.Name) {
 				migrationFile = file
 				break
 			}
@@ -218,15 +251,27 @@ func (m *Migrator) ResetMigrations(files []string) (int, error) {
 
 // createMigrationsTable creates the migrations table if it doesn't exist
 func (m *Migrator) createMigrationsTable() error {
-	sql := `
-		CREATE TABLE IF NOT EXISTS migrations (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			batch INTEGER NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)
-	`
-	return m.db.Exec(sql)
+	return NewSchema(m.db).CreateIfNotExists("migrations", func(table *Blueprint) {
+		table.ID()
+		table.String("name", 255)
+		table.Integer("batch", false)
+		table.DateTime("created_at").UseCurrent()
+	})
+}
+
+// ranMigrations returns the migrations that have run, most recent first
+func (m *Migrator) ranMigrations() ([]Migration, error) {
+	var migrations []Migration
+	err := NewQueryBuilder(m.db, "migrations").
+		OrderBy("batch", "DESC").
+		OrderBy("id", "DESC").
+		Get(&migrations)
+	return migrations, err
+}
+
+// migrationName returns the registered name for a migration file path
+func migrationName(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), ".go")
 }
 
 // getPendingMigrations returns migrations that haven't been run yet
@@ -234,14 +279,13 @@ func (m *Migrator) getPendingMigrations(files []string, migrations []Migration)
 	// Create a map of already run migrations
 	migrationMap := make(map[string]bool)
 	for _, migration := range migrations {
-		migrationMap[migration.Name] = true
+		migrationMap[migrationName(migration.Name)] = true
 	}
 
 	// Get pending migrations
 	var pendingMigrations []string
 	for _, file := range files {
-		name := filepath.Base(file)
-		if !migrationMap[name] {
+		if !migrationMap[migrationName(file)] {
 			pendingMigrations = append(pendingMigrations, file)
 		}
 	}
@@ -249,41 +293,18 @@ func (m *Migrator) getPendingMigrations(files []string, migrations []Migration)
 	return pendingMigrations
 }
 
-// loadMigration loads a migration from a file
+// loadMigration returns the registered migration for a file
 func (m *Migrator) loadMigration(file string) (MigrationInterface, error) {
-	// In a real implementation, this would load the migration from the file
-	// For simplicity, we'll just return a dummy migration
-	
-	// This is a simplified version - in a real implementation, 
-	// you would use Go's plugin system or reflection to load the migration
-	
-	// Extract migration name from file
-	name := strings.TrimSuffix(filepath.Base(file), ".go")
-	parts := strings.SplitN(name, "_", 2)
-	if len(parts) != 2 {
-		return nil, errors.New("invalid migration filename format")
-	}
-	
-	// In a real implementation, you would load the migration class
-	// For now, we'll return a dummy migration
-	return &dummyMigration{name: parts[1]}, nil
-}
-
-// dummyMigration is a placeholder for a real migration
-type dummyMigration struct {
-	name string
-}
+	name := migrationName(file)
 
-// Up runs the migration
-func (m *dummyMigration) Up(migrator *Migrator) error {
-	fmt.Printf("Running migration: %s\n", m.name)
-	return nil
-}
+	registryMu.RLock()
+	migration, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("migration %s is not registered", name)
+	}
 
-// Down rolls back the migration
-func (m *dummyMigration) Down(migrator *Migrator) error {
-	fmt.Printf("Rolling back migration: %s\n", m.name)
-	return nil
+	return migration, nil
 }
 
 // recordMigration records a migration in the migrations table
@@ -0,0 +1,93 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/example/goframe/db"
+	_ "github.com/example/goframe/migrations"
+)
+
+// tables returns how many tables database holds besides SQLite's own
+func tables(t *testing.T, database *db.Database) int {
+	t.Helper()
+	var n int
+	err := database.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestRegisteredMigrationsUpAndDown(t *testing.T) {
+	database, err := db.NewDatabase(&db.DatabaseConfig{Driver: "sqlite", Name: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	files := db.RegisteredMigrations()
+	if len(files) == 0 {
+		t.Fatal("no migrations are registered")
+	}
+	migrator := db.NewMigrator(database)
+
+	// Each direction is run twice to check that down undoes up completely
+	for round := 1; round <= 2; round++ {
+		ran, err := migrator.RunMigrations(files)
+		if err != nil {
+			t.Fatalf("round %d: up: %v", round, err)
+		}
+		if ran != len(files) {
+			t.Errorf("round %d: ran %d of %d migrations", round, ran, len(files))
+		}
+		if ran, err := migrator.RunMigrations(files); err != nil || ran != 0 {
+			t.Errorf("round %d: second up ran %d, %v", round, ran, err)
+		}
+		if tables(t, database) <= 1 {
+			t.Errorf("round %d: no tables were created", round)
+		}
+
+		rolledBack, err := migrator.RollbackMigrations(files, 1)
+		if err != nil {
+			t.Fatalf("round %d: down: %v", round, err)
+		}
+		if rolledBack != len(files) {
+			t.Errorf("round %d: rolled back %d of %d migrations", round, rolledBack, len(files))
+		}
+		if n := tables(t, database); n != 1 {
+			t.Errorf("round %d: %d tables left besides migrations", round, n-1)
+		}
+	}
+}
+
+func TestRollbackStepsThroughBatches(t *testing.T) {
+	database, err := db.NewDatabase(&db.DatabaseConfig{Driver: "sqlite", Name: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	files := db.RegisteredMigrations()
+	if len(files) < 2 {
+		t.Skip("needs at least two migrations")
+	}
+	migrator := db.NewMigrator(database)
+
+	// Two batches: the first migration, then the rest
+	if _, err := migrator.RunMigrations(files[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.RunMigrations(files); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := migrator.RollbackMigrations(files, 1); err != nil || n != len(files)-1 {
+		t.Fatalf("rolling back the last batch: %d, %v", n, err)
+	}
+	if n, err := migrator.ResetMigrations(files); err != nil || n != 1 {
+		t.Fatalf("resetting: %d, %v", n, err)
+	}
+	if n := tables(t, database); n != 1 {
+		t.Errorf("%d tables left besides migrations", n-1)
+	}
+}
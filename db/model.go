@@ -0,0 +1,350 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// TableNamer is implemented by models that name their own table. Models
+// without it use the pluralized snake_case of their type name, so BlogPost
+// is stored in blog_posts.
+type TableNamer interface {
+	TableName() string
+}
+
+// Hydrator is implemented by models that keep the database they were read
+// from, to look up more data on demand. Every query that scans rows into
+// models, relation loading included, calls Hydrate on each of them. Inside a
+// transaction the handle is the transaction's database, so lookups made
+// later run outside it.
+type Hydrator interface {
+	Hydrate(database *Database)
+}
+
+// hydrate calls Hydrate on each model in dest that implements Hydrator
+func hydrate(e Executor, dest interface{}) {
+	var database *Database
+	switch e := e.(type) {
+	case *Database:
+		database = e
+	case *Transaction:
+		database = e.db
+	default:
+		return
+	}
+
+	for _, model := range modelValues(reflect.ValueOf(dest)) {
+		if h, ok := model.Addr().Interface().(Hydrator); ok {
+			h.Hydrate(database)
+		}
+	}
+}
+
+// modelMeta describes how a struct type maps to a table. It is built once
+// per type and cached.
+type modelMeta struct {
+	model     reflect.Type
+	name      string
+	table     string
+	fields    []*fieldMeta
+	columns   map[string]*fieldMeta // Keyed by lower-cased column name
+	pk        *fieldMeta
+	relations map[string]*relationMeta // Keyed by field name
+}
+
+// fieldMeta describes a struct field stored in a column. The db tag names the
+// column and may add options after a comma:
+//
+//	pk        the field is the primary key; a field named "id" is by default
+//	autoincr  the database assigns the value, so a zero value is not inserted
+//	readonly  the field is read but never inserted or updated
+//	omitempty a zero value is not inserted or updated
+type fieldMeta struct {
+	column        string
+	index         []int // Path for FieldByIndex, through embedded structs
+	primaryKey    bool
+	autoIncrement bool
+	readOnly      bool
+	omitEmpty     bool
+}
+
+var (
+	modelCache  sync.Map // reflect.Type to *modelMeta
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// modelOf returns the metadata for model, which may be a struct, a pointer to
+// one, or a slice or pointer to a slice of either
+func modelOf(model interface{}) (*modelMeta, error) {
+	return modelOfType(reflect.TypeOf(model))
+}
+
+// modelOfType returns the metadata for the struct type underlying typ
+func modelOfType(typ reflect.Type) (*modelMeta, error) {
+	if typ == nil {
+		return nil, errors.New("model must be a struct, got nil")
+	}
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model must be a struct, got %s", typ)
+	}
+
+	if meta, ok := modelCache.Load(typ); ok {
+		return meta.(*modelMeta), nil
+	}
+
+	meta := &modelMeta{
+		model:     typ,
+		name:      typ.Name(),
+		table:     tableName(typ),
+		columns:   make(map[string]*fieldMeta),
+		relations: make(map[string]*relationMeta),
+	}
+	if err := meta.collect(typ, typ, nil); err != nil {
+		return nil, err
+	}
+	for _, field := range meta.fields {
+		if field.primaryKey {
+			meta.pk = field
+			break
+		}
+	}
+	if meta.pk == nil {
+		if field, ok := meta.columns["id"]; ok {
+			field.primaryKey = true
+			field.autoIncrement = isInteger(typ.FieldByIndex(field.index).Type)
+			meta.pk = field
+		}
+	}
+
+	actual, _ := modelCache.LoadOrStore(typ, meta)
+	return actual.(*modelMeta), nil
+}
+
+// collect adds the columns and relations of typ, whose fields are reached
+// from the model through index. Untagged embedded structs are flattened, and
+// as in Go a shallower field hides a deeper one with the same column.
+func (m *modelMeta) collect(model, typ reflect.Type, index []int) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, tagged := field.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+
+		path := append(append([]int(nil), index...), i)
+		if rel, ok := field.Tag.Lookup("rel"); ok {
+			relation, err := parseRelation(model, field, rel)
+			if err != nil {
+				return err
+			}
+			relation.index = path
+			m.relations[field.Name] = relation
+			continue
+		}
+		if field.Anonymous && !tagged {
+			if field.Type.Kind() == reflect.Struct {
+				if err := m.collect(model, field.Type, path); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !field.IsExported() || tag == "" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		meta := &fieldMeta{column: name, index: path}
+		for _, option := range strings.Split(options, ",") {
+			switch strings.TrimSpace(option) {
+			case "pk":
+				meta.primaryKey = true
+			case "autoincr":
+				meta.autoIncrement = true
+			case "readonly":
+				meta.readOnly = true
+			case "omitempty":
+				meta.omitEmpty = true
+			}
+		}
+
+		key := strings.ToLower(name)
+		if existing, ok := m.columns[key]; ok {
+			if len(existing.index) <= len(path) {
+				continue
+			}
+			for j, f := range m.fields {
+				if f == existing {
+					m.fields = append(m.fields[:j], m.fields[j+1:]...)
+					break
+				}
+			}
+		}
+		m.columns[key] = meta
+		m.fields = append(m.fields, meta)
+	}
+	return nil
+}
+
+// writable returns the columns and values of the struct v to store. Readonly
+// fields and zero omitempty fields are always left out. On insert an unset
+// auto-increment key is left for the database to assign; on update the
+// primary key is left out.
+func (m *modelMeta) writable(v reflect.Value, insert bool) ([]string, []interface{}) {
+	var columns []string
+	var values []interface{}
+	for _, field := range m.fields {
+		value := v.FieldByIndex(field.index)
+		switch {
+		case field.readOnly:
+			continue
+		case field.omitEmpty && value.IsZero():
+			continue
+		case field.primaryKey && !insert:
+			continue
+		case field.primaryKey && field.autoIncrement && value.IsZero():
+			continue
+		}
+		columns = append(columns, field.column)
+		values = append(values, value.Interface())
+	}
+	return columns, values
+}
+
+// column returns the field stored in column
+func (m *modelMeta) column(column string) (*fieldMeta, error) {
+	field, ok := m.columns[strings.ToLower(column)]
+	if !ok {
+		return nil, fmt.Errorf("%s has no %s column", m.name, column)
+	}
+	return field, nil
+}
+
+// primaryKey returns the primary key column, defaulting to "id"
+func (m *modelMeta) primaryKey() string {
+	if m.pk == nil {
+		return "id"
+	}
+	return m.pk.column
+}
+
+// tableName returns the table for typ: its TableName(), if it has one, or
+// the pluralized snake_case of the type name
+func tableName(typ reflect.Type) string {
+	if namer, ok := reflect.New(typ).Interface().(TableNamer); ok {
+		return namer.TableName()
+	}
+	return pluralize(snakeCase(typ.Name()))
+}
+
+// snakeCase converts a Go identifier such as HTTPRequestLog to http_request_log
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var out strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				out.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// pluralize returns the regular English plural of word
+func pluralize(word string) string {
+	switch {
+	case word == "":
+		return word
+	case strings.HasSuffix(word, "y") && len(word) > 1 && !strings.ContainsRune("aeiou", rune(word[len(word)-2])):
+		return word[:len(word)-1] + "ies"
+	case strings.HasSuffix(word, "s"), strings.HasSuffix(word, "x"), strings.HasSuffix(word, "z"),
+		strings.HasSuffix(word, "ch"), strings.HasSuffix(word, "sh"):
+		return word + "es"
+	}
+	return word + "s"
+}
+
+// isModelPointer reports whether dest points to a struct that is scanned
+// field by field, rather than a value such as time.Time that scans itself
+func isModelPointer(dest interface{}) bool {
+	typ := reflect.TypeOf(dest)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	return !typ.Implements(scannerType) && typ.Elem() != reflect.TypeOf(time.Time{})
+}
+
+// setInteger stores id in an integer field
+func setInteger(field reflect.Value, id int64) error {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(id))
+	default:
+		return fmt.Errorf("cannot store a generated ID in a %s field", field.Type())
+	}
+	return nil
+}
+
+// isInteger reports whether typ is one of Go's integer kinds
+func isInteger(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// scanStruct scans the current row into the struct v. Columns without a
+// matching field are discarded, and a NULL leaves a non-pointer field at its
+// zero value.
+func scanStruct(rows *sql.Rows, columns []string, meta *modelMeta, v reflect.Value) error {
+	type nullable struct {
+		field  reflect.Value
+		holder reflect.Value // A **T that database/sql sets to nil for NULL
+	}
+
+	targets := make([]interface{}, len(columns))
+	var nullables []nullable
+	for i, column := range columns {
+		field, ok := meta.columns[strings.ToLower(column)]
+		if !ok {
+			targets[i] = new(interface{})
+			continue
+		}
+
+		value := v.FieldByIndex(field.index)
+		if value.Kind() == reflect.Ptr || value.Addr().Type().Implements(scannerType) {
+			targets[i] = value.Addr().Interface()
+			continue
+		}
+		holder := reflect.New(reflect.PtrTo(value.Type()))
+		targets[i] = holder.Interface()
+		nullables = append(nullables, nullable{field: value, holder: holder})
+	}
+
+	if err := rows.Scan(targets...); err != nil {
+		return err
+	}
+	for _, n := range nullables {
+		if ptr := n.holder.Elem(); !ptr.IsNil() {
+			n.field.Set(ptr.Elem())
+		}
+	}
+	return nil
+}
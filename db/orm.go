@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+	"fmt"
 	// "errors"
 	"reflect"
 	// "strings"
@@ -9,36 +11,73 @@ import (
 
 // Entity is the base struct for all ORM models
 type Entity struct {
-	ID        uint      `db:"id" json:"id"`
+	ID        uint      `db:"id,pk,autoincr" json:"id"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
 // Repository provides a generic way to interact with entities
 type Repository[T any] struct {
-	db *Database
+	db Executor
 }
 
-// NewRepository creates a new repository for the given entity type
-func NewRepository[T any](db *Database) *Repository[T] {
+// NewRepository creates a new repository for the given entity type. Pass a
+// *Transaction to run the repository's queries inside that transaction.
+func NewRepository[T any](db Executor) *Repository[T] {
 	return &Repository[T]{db: db}
 }
 
 // Create creates a new entity
 func (r *Repository[T]) Create(entity *T) error {
+	return r.CreateContext(context.Background(), entity)
+}
+
+// CreateContext creates a new entity, cancelling the insert when ctx is done.
+// A zero auto-increment ID is filled in with the one the database generated.
+func (r *Repository[T]) CreateContext(ctx context.Context, entity *T) error {
 	// Set created_at and updated_at
 	setTimestamps(entity)
 	
-	return r.db.Create(entity)
+	return create(ctx, r.db, entity)
+}
+
+// Refresh reloads entity from the database, picking up values the database
+// filled in, such as column defaults, that the struct does not have yet
+func (r *Repository[T]) Refresh(entity *T) error {
+	return r.RefreshContext(context.Background(), entity)
+}
+
+// RefreshContext is Refresh, cancelling the query when ctx is done
+func (r *Repository[T]) RefreshContext(ctx context.Context, entity *T) error {
+	column, id, err := primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	return newQuery(r.db, entity).WithContext(ctx).Where(column+" = ?", id).First(entity)
 }
 
 // FindByID finds an entity by ID
 func (r *Repository[T]) FindByID(id uint, dest *T) error {
-	return r.db.Table(dest).Where("id = ?", id).First(dest)
+	return r.FindByIDContext(context.Background(), id, dest)
+}
+
+// FindByIDContext finds an entity by ID, cancelling the query when ctx is done
+func (r *Repository[T]) FindByIDContext(ctx context.Context, id uint, dest *T) error {
+	meta, err := modelOf(dest)
+	if err != nil {
+		return err
+	}
+	return newQuery(r.db, dest).WithContext(ctx).Where(meta.primaryKey()+" = ?", id).First(dest)
 }
 
 func (r Repository[T]) FindByString(column string, dest *T, value string) error {
-	return r.db.Table(dest).Where(column+" = ?", value).First(dest)
+	return r.FindByStringContext(context.Background(), column, dest, value)
+}
+
+// FindByStringContext finds an entity by a string column, cancelling the
+// query when ctx is done
+func (r Repository[T]) FindByStringContext(ctx context.Context, column string, dest *T, value string) error {
+	return newQuery(r.db, dest).WithContext(ctx).Where(column+" = ?", value).First(dest)
 }
 
 func (r *Repository[T]) FindByIDOrFail(id uint, dest *T) error {
@@ -52,7 +91,13 @@ func (r *Repository[T]) FindByIDOrFail(id uint, dest *T) error {
 
 // FindAll finds all entities matching the query
 func (r *Repository[T]) FindAll(dest *[]T, conditions ...interface{}) error {
-	query := r.db.Table(*new(T))
+	return r.FindAllContext(context.Background(), dest, conditions...)
+}
+
+// FindAllContext finds all entities matching the query, cancelling it when
+// ctx is done
+func (r *Repository[T]) FindAllContext(ctx context.Context, dest *[]T, conditions ...interface{}) error {
+	query := newQuery(r.db, *new(T)).WithContext(ctx)
 	
 	if len(conditions) > 0 {
 		if condition, ok := conditions[0].(string); ok {
@@ -63,21 +108,93 @@ func (r *Repository[T]) FindAll(dest *[]T, conditions ...interface{}) error {
 	return query.Find(dest)
 }
 
+// Query starts a query for entities, for conditions, ordering and eager
+// loading that the Find methods do not cover:
+//
+//	repo.Query().Where("published = ?", true).With("Author", "Comments.User").Find(&posts)
+func (r *Repository[T]) Query() *Query {
+	return newQuery(r.db, *new(T))
+}
+
 // Update updates an entity
 func (r *Repository[T]) Update(entity *T) error {
+	return r.UpdateContext(context.Background(), entity)
+}
+
+// UpdateContext updates an entity, cancelling the update when ctx is done
+func (r *Repository[T]) UpdateContext(ctx context.Context, entity *T) error {
 	// Set updated_at
 	setUpdatedAt(entity)
 	
-	values := toMap(entity)
-	delete(values, "id")       // Don't update ID
+	values, err := toMap(entity)
+	if err != nil {
+		return err
+	}
 	delete(values, "created_at") // Don't update created_at
 	
-	return r.db.Table(entity).Where("id = ?", getID(entity)).Update(values)
+	column, id, err := primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	return newQuery(r.db, entity).WithContext(ctx).Where(column+" = ?", id).Update(values)
 }
 
 // Delete deletes an entity
 func (r *Repository[T]) Delete(entity *T) error {
-	return r.db.Table(entity).Where("id = ?", getID(entity)).Delete()
+	return r.DeleteContext(context.Background(), entity)
+}
+
+// DeleteContext deletes an entity, cancelling the delete when ctx is done
+func (r *Repository[T]) DeleteContext(ctx context.Context, entity *T) error {
+	column, id, err := primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	return newQuery(r.db, entity).WithContext(ctx).Where(column+" = ?", id).Delete()
+}
+
+// Load loads the named relations of dest, a *T or a *[]T, batching each
+// relation into one query for all of the entities
+func (r *Repository[T]) Load(dest interface{}, relations ...string) error {
+	return r.LoadContext(context.Background(), dest, relations...)
+}
+
+// LoadContext is Load, cancelling the queries when ctx is done
+func (r *Repository[T]) LoadContext(ctx context.Context, dest interface{}, relations ...string) error {
+	return Load(ctx, r.db, dest, relations...)
+}
+
+// Attach links entity to the models with the given IDs through a manyToMany
+// relation's pivot table. Existing links are left as they are.
+func (r *Repository[T]) Attach(entity *T, relation string, ids ...interface{}) error {
+	return r.AttachContext(context.Background(), entity, relation, ids...)
+}
+
+// AttachContext is Attach, cancelling the inserts when ctx is done
+func (r *Repository[T]) AttachContext(ctx context.Context, entity *T, relation string, ids ...interface{}) error {
+	return attachPivot(ctx, r.db, entity, relation, ids)
+}
+
+// Detach unlinks entity from the models with the given IDs, or from every
+// model when no IDs are given
+func (r *Repository[T]) Detach(entity *T, relation string, ids ...interface{}) error {
+	return r.DetachContext(context.Background(), entity, relation, ids...)
+}
+
+// DetachContext is Detach, cancelling the delete when ctx is done
+func (r *Repository[T]) DetachContext(ctx context.Context, entity *T, relation string, ids ...interface{}) error {
+	return detachPivot(ctx, r.db, entity, relation, ids)
+}
+
+// Sync links entity to exactly the models with the given IDs, attaching
+// and detaching as needed in a single transaction
+func (r *Repository[T]) Sync(entity *T, relation string, ids ...interface{}) error {
+	return r.SyncContext(context.Background(), entity, relation, ids...)
+}
+
+// SyncContext is Sync, cancelling the transaction when ctx is done
+func (r *Repository[T]) SyncContext(ctx context.Context, entity *T, relation string, ids ...interface{}) error {
+	return syncPivot(ctx, r.db, entity, relation, ids)
 }
 
 // setTimestamps sets the created_at and updated_at fields
@@ -106,32 +223,32 @@ func setUpdatedAt(entity interface{}) {
 	}
 }
 
-// getID gets the ID field value
-func getID(entity interface{}) uint {
-	val := reflect.ValueOf(entity).Elem()
-	
-	if idField := val.FieldByName("ID"); idField.IsValid() {
-		return uint(idField.Uint())
+// primaryKey returns the primary key column of entity and its value
+func primaryKey(entity interface{}) (string, interface{}, error) {
+	meta, err := modelOf(entity)
+	if err != nil {
+		return "", nil, err
+	}
+	if meta.pk == nil {
+		return "", nil, fmt.Errorf("model %T has no primary key", entity)
 	}
 	
-	return 0
+	return meta.pk.column, reflect.Indirect(reflect.ValueOf(entity)).FieldByIndex(meta.pk.index).Interface(), nil
 }
 
-// toMap converts a struct to a map
-func toMap(entity interface{}) map[string]interface{} {
-	result := make(map[string]interface{})
-	val := reflect.ValueOf(entity).Elem()
-	typ := val.Type()
+// toMap converts a struct to a map of the columns it may update, flattening
+// embedded structs and leaving out the primary key and readonly fields
+func toMap(entity interface{}) (map[string]interface{}, error) {
+	meta, err := modelOf(entity)
+	if err != nil {
+		return nil, err
+	}
 	
-	for i := 0; i < val.NumField(); i++ {
-		field := typ.Field(i)
-		tag := field.Tag.Get("db")
-		
-		if tag != "" && tag != "-" {
-			result[tag] = val.Field(i).Interface()
-		}
+	columns, values := meta.writable(reflect.Indirect(reflect.ValueOf(entity)), false)
+	result := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		result[column] = values[i]
 	}
 	
-	return result
+	return result, nil
 }
-
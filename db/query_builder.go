@@ -1,12 +1,18 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 )
 
+// QueryBuilder builds and runs SQL against one table. Table and column names
+// are quoted for the database's dialect; anything that is not a plain,
+// optionally dotted name, such as COUNT(*) or LOWER(email), is used as is.
 type QueryBuilder struct {
-	db         *Database
+	db         Executor
+	ctx        context.Context
 	table      string
 	columns    []string
 	wheres     []string
@@ -22,7 +28,7 @@ type QueryBuilder struct {
 	binds      []interface{}
 }
 
-func NewQueryBuilder(db *Database, table string) *QueryBuilder {
+func NewQueryBuilder(db Executor, table string) *QueryBuilder {
 	return &QueryBuilder{
 		db:       db,
 		table:    table,
@@ -36,6 +42,58 @@ func NewQueryBuilder(db *Database, table string) *QueryBuilder {
 	}
 }
 
+// WithContext runs the query under ctx, so it is cancelled when ctx is done
+func (q *QueryBuilder) WithContext(ctx context.Context) *QueryBuilder {
+	q.ctx = ctx
+	return q
+}
+
+// quote quotes identifier for the dialect, leaving expressions as they are
+func (q *QueryBuilder) quote(identifier string) string {
+	if !isIdentifier(identifier) {
+		return identifier
+	}
+	return q.db.Dialect().Quote(identifier)
+}
+
+// quoteAll quotes each of identifiers
+func (q *QueryBuilder) quoteAll(identifiers []string) []string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = q.quote(identifier)
+	}
+	return quoted
+}
+
+// isIdentifier reports whether s is a plain name, optionally dotted, such as
+// email, users.email or users.*
+func isIdentifier(s string) bool {
+	parts := strings.Split(s, ".")
+	for i, part := range parts {
+		if part == "*" && i == len(parts)-1 {
+			continue
+		}
+		if part == "" {
+			return false
+		}
+		for j, c := range part {
+			letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+			if !letter && (j == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// context returns the query's context, defaulting to context.Background()
+func (q *QueryBuilder) context() context.Context {
+	if q.ctx == nil {
+		return context.Background()
+	}
+	return q.ctx
+}
+
 func (q *QueryBuilder) Select(columns ...string) *QueryBuilder {
 	if len(columns) > 0 {
 		q.columns = columns
@@ -49,7 +107,7 @@ func (q *QueryBuilder) Distinct() *QueryBuilder {
 }
 
 func (q *QueryBuilder) Where(column string, operator string, value interface{}) *QueryBuilder {
-	q.wheres = append(q.wheres, fmt.Sprintf("%s %s ?", column, operator))
+	q.wheres = append(q.wheres, fmt.Sprintf("%s %s ?", q.quote(column), operator))
 	q.whereBinds = append(q.whereBinds, value)
 	return q
 }
@@ -64,7 +122,7 @@ func (q *QueryBuilder) OrWhere(column string, operator string, value interface{}
 	if len(q.wheres) == 0 {
 		return q.Where(column, operator, value)
 	}
-	q.wheres = append(q.wheres, fmt.Sprintf("OR %s %s ?", column, operator))
+	q.wheres = append(q.wheres, fmt.Sprintf("OR %s %s ?", q.quote(column), operator))
 	q.whereBinds = append(q.whereBinds, value)
 	return q
 }
@@ -78,7 +136,7 @@ func (q *QueryBuilder) WhereIn(column string, values ...interface{}) *QueryBuild
 	for i := range values {
 		placeholders[i] = "?"
 	}
-	q.wheres = append(q.wheres, fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")))
+	q.wheres = append(q.wheres, fmt.Sprintf("%s IN (%s)", q.quote(column), strings.Join(placeholders, ", ")))
 	q.whereBinds = append(q.whereBinds, values...)
 	return q
 }
@@ -91,33 +149,33 @@ func (q *QueryBuilder) WhereNotIn(column string, values ...interface{}) *QueryBu
 	for i := range values {
 		placeholders[i] = "?"
 	}
-	q.wheres = append(q.wheres, fmt.Sprintf("%s NOT IN (%s)", column, strings.Join(placeholders, ", ")))
+	q.wheres = append(q.wheres, fmt.Sprintf("%s NOT IN (%s)", q.quote(column), strings.Join(placeholders, ", ")))
 	q.whereBinds = append(q.whereBinds, values...)
 	return q
 }
 
 func (q *QueryBuilder) WhereNull(column string) *QueryBuilder {
-	q.wheres = append(q.wheres, fmt.Sprintf("%s IS NULL", column))
+	q.wheres = append(q.wheres, fmt.Sprintf("%s IS NULL", q.quote(column)))
 	return q
 }
 
 func (q *QueryBuilder) WhereNotNull(column string) *QueryBuilder {
-	q.wheres = append(q.wheres, fmt.Sprintf("%s IS NOT NULL", column))
+	q.wheres = append(q.wheres, fmt.Sprintf("%s IS NOT NULL", q.quote(column)))
 	return q
 }
 
 func (q *QueryBuilder) Join(table string, first string, operator string, second string) *QueryBuilder {
-	q.joins = append(q.joins, fmt.Sprintf("JOIN %s ON %s %s %s", table, first, operator, second))
+	q.joins = append(q.joins, fmt.Sprintf("JOIN %s ON %s %s %s", q.quote(table), q.quote(first), operator, q.quote(second)))
 	return q
 }
 
 func (q *QueryBuilder) LeftJoin(table string, first string, operator string, second string) *QueryBuilder {
-	q.joins = append(q.joins, fmt.Sprintf("LEFT JOIN %s ON %s %s %s", table, first, operator, second))
+	q.joins = append(q.joins, fmt.Sprintf("LEFT JOIN %s ON %s %s %s", q.quote(table), q.quote(first), operator, q.quote(second)))
 	return q
 }
 
 func (q *QueryBuilder) RightJoin(table string, first string, operator string, second string) *QueryBuilder {
-	q.joins = append(q.joins, fmt.Sprintf("RIGHT JOIN %s ON %s %s %s", table, first, operator, second))
+	q.joins = append(q.joins, fmt.Sprintf("RIGHT JOIN %s ON %s %s %s", q.quote(table), q.quote(first), operator, q.quote(second)))
 	return q
 }
 
@@ -126,17 +184,17 @@ func (q *QueryBuilder) OrderBy(column string, direction string) *QueryBuilder {
 	if dir != "ASC" && dir != "DESC" {
 		dir = "ASC"
 	}
-	q.orderBys = append(q.orderBys, fmt.Sprintf("%s %s", column, dir))
+	q.orderBys = append(q.orderBys, fmt.Sprintf("%s %s", q.quote(column), dir))
 	return q
 }
 
 func (q *QueryBuilder) GroupBy(columns ...string) *QueryBuilder {
-	q.groupBys = append(q.groupBys, columns...)
+	q.groupBys = append(q.groupBys, q.quoteAll(columns)...)
 	return q
 }
 
 func (q *QueryBuilder) Having(column string, operator string, value interface{}) *QueryBuilder {
-	q.havings = append(q.havings, fmt.Sprintf("%s %s ?", column, operator))
+	q.havings = append(q.havings, fmt.Sprintf("%s %s ?", q.quote(column), operator))
 	q.binds = append(q.binds, value)
 	return q
 }
@@ -156,20 +214,28 @@ func (q *QueryBuilder) Offset(offset int) *QueryBuilder {
 }
 
 func (q *QueryBuilder) Union(query *QueryBuilder) *QueryBuilder {
-	sql, binds := query.ToSql()
+	sql, binds := query.toSql()
 	q.unions = append(q.unions, fmt.Sprintf("UNION (%s)", sql))
 	q.binds = append(q.binds, binds...)
 	return q
 }
 
 func (q *QueryBuilder) UnionAll(query *QueryBuilder) *QueryBuilder {
-	sql, binds := query.ToSql()
+	sql, binds := query.toSql()
 	q.unions = append(q.unions, fmt.Sprintf("UNION ALL (%s)", sql))
 	q.binds = append(q.binds, binds...)
 	return q
 }
 
+// ToSql returns the SELECT statement and its bindings, with placeholders in
+// the style of the database's dialect
 func (q *QueryBuilder) ToSql() (string, []interface{}) {
+	sql, binds := q.toSql()
+	return q.db.Dialect().Rebind(sql), binds
+}
+
+// toSql builds the SELECT statement with ? placeholders
+func (q *QueryBuilder) toSql() (string, []interface{}) {
 	var query strings.Builder
 	var binds []interface{}
 
@@ -177,9 +243,9 @@ func (q *QueryBuilder) ToSql() (string, []interface{}) {
 	if q.distinct {
 		query.WriteString("DISTINCT ")
 	}
-	query.WriteString(strings.Join(q.columns, ", "))
+	query.WriteString(strings.Join(q.quoteAll(q.columns), ", "))
 	query.WriteString(" FROM ")
-	query.WriteString(q.table)
+	query.WriteString(q.quote(q.table))
 
 	if len(q.joins) > 0 {
 		query.WriteString(" ")
@@ -187,20 +253,8 @@ func (q *QueryBuilder) ToSql() (string, []interface{}) {
 	}
 
 	if len(q.wheres) > 0 {
-		query.WriteString(" WHERE ")
-		query.WriteString(q.wheres[0])
-		binds = append(binds, q.whereBinds[0])
-		for i := 1; i < len(q.wheres); i++ {
-			clause := q.wheres[i]
-			if strings.HasPrefix(clause, "OR ") {
-				query.WriteString(" ")
-				query.WriteString(clause)
-			} else {
-				query.WriteString(" AND ")
-				query.WriteString(clause)
-			}
-			binds = append(binds, q.whereBinds[i])
-		}
+		query.WriteString(q.whereSQL())
+		binds = append(binds, q.whereBinds...)
 	}
 
 	if len(q.groupBys) > 0 {
@@ -219,13 +273,7 @@ func (q *QueryBuilder) ToSql() (string, []interface{}) {
 		query.WriteString(strings.Join(q.orderBys, ", "))
 	}
 
-	if q.limit > 0 {
-		query.WriteString(fmt.Sprintf(" LIMIT %d", q.limit))
-	}
-
-	if q.offset > 0 {
-		query.WriteString(fmt.Sprintf(" OFFSET %d", q.offset))
-	}
+	query.WriteString(q.db.Dialect().LimitOffset(q.limit, q.offset))
 
 	if len(q.unions) > 0 {
 		query.WriteString(" ")
@@ -235,33 +283,61 @@ func (q *QueryBuilder) ToSql() (string, []interface{}) {
 	return query.String(), binds
 }
 
+// whereSQL returns the WHERE clause, with a leading space. Clauses are joined
+// with AND, except those added by OrWhere.
+func (q *QueryBuilder) whereSQL() string {
+	var clause strings.Builder
+	clause.WriteString(" WHERE ")
+	clause.WriteString(q.wheres[0])
+	for _, where := range q.wheres[1:] {
+		if strings.HasPrefix(where, "OR ") {
+			clause.WriteString(" ")
+		} else {
+			clause.WriteString(" AND ")
+		}
+		clause.WriteString(where)
+	}
+	return clause.String()
+}
+
 func (q *QueryBuilder) Get(dest interface{}) error {
-	sql, binds := q.ToSql()
-	rows, err := q.db.Query(sql, binds...)
+	sql, binds := q.toSql()
+	rows, err := q.db.QueryContext(q.context(), sql, binds...)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
-	return q.db.scanRows(rows, dest)
+	if err := rowsScan(rows, dest); err != nil {
+		return err
+	}
+	hydrate(q.db, dest)
+	return nil
 }
 
+// First scans the first matching row into dest. A pointer to a model is
+// filled by column name, and returns sql.ErrNoRows when nothing matches; any
+// other dest is scanned directly, for single-column queries.
 func (q *QueryBuilder) First(dest interface{}) error {
 	q.Limit(1)
-	sql, binds := q.ToSql()
-	row := q.db.QueryRow(sql, binds...)
+	if isModelPointer(dest) {
+		return q.Get(dest)
+	}
+	sql, binds := q.toSql()
+	row := q.db.QueryRowContext(q.context(), sql, binds...)
 	return row.Scan(dest)
 }
 
 func (q *QueryBuilder) Count() (int, error) {
 	var count int
 	countQuery := NewQueryBuilder(q.db, q.table)
+	countQuery.ctx = q.ctx
 	countQuery.wheres = q.wheres
 	countQuery.whereBinds = q.whereBinds
 	countQuery.joins = q.joins
 	countQuery.columns = []string{"COUNT(*) as count"}
 	countQuery.orderBys = []string{}
-	sql, binds := countQuery.ToSql()
-	row := q.db.QueryRow(sql, binds...)
+	sql, binds := countQuery.toSql()
+	row := q.db.QueryRowContext(q.context(), sql, binds...)
 	err := row.Scan(&count)
 	return count, err
 }
@@ -274,41 +350,130 @@ func (q *QueryBuilder) Insert(values map[string]interface{}) error {
 	var placeholders []string
 	var binds []interface{}
 	for column, value := range values {
-		columns = append(columns, column)
+		columns = append(columns, q.quote(column))
 		placeholders = append(placeholders, "?")
 		binds = append(binds, value)
 	}
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", q.table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
-	err := q.db.Exec(query, binds...)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", q.quote(q.table), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	err := q.db.ExecContext(q.context(), query, binds...)
 	return err
 }
 
+// InsertGetID inserts values and returns the auto-increment ID the database
+// generated for the id column
+func (q *QueryBuilder) InsertGetID(values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, fmt.Errorf("no values provided for insert")
+	}
+	d := q.db.Dialect()
+	columns := sortedColumns(values)
+	binds := make([]interface{}, len(columns))
+	for i, column := range columns {
+		binds[i] = values[column]
+	}
+	return insertGetID(q.context(), q.db, insertSQL(d, q.table, columns), binds, "id")
+}
+
+// Upsert inserts values, or updates the existing row when one with the same
+// conflict columns exists. Only the update columns are changed; when none
+// are given, every inserted column other than conflict is updated. MySQL
+// matches on any unique key rather than just conflict.
+func (q *QueryBuilder) Upsert(values map[string]interface{}, conflict []string, update ...string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no values provided for upsert")
+	}
+	columns := sortedColumns(values)
+	if len(update) == 0 {
+		isConflict := make(map[string]bool, len(conflict))
+		for _, column := range conflict {
+			isConflict[column] = true
+		}
+		for _, column := range columns {
+			if !isConflict[column] {
+				update = append(update, column)
+			}
+		}
+	}
+	return q.upsert(values, columns, conflict, update)
+}
+
+// InsertOrIgnore inserts values unless a row with the same conflict columns
+// already exists, in which case nothing happens. MySQL ignores a duplicate
+// on any unique key rather than just conflict.
+func (q *QueryBuilder) InsertOrIgnore(values map[string]interface{}, conflict ...string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no values provided for insert")
+	}
+	return q.upsert(values, sortedColumns(values), conflict, nil)
+}
+
+// upsert runs the dialect's upsert of columns from values
+func (q *QueryBuilder) upsert(values map[string]interface{}, columns, conflict, update []string) error {
+	binds := make([]interface{}, len(columns))
+	for i, column := range columns {
+		binds[i] = values[column]
+	}
+	query := q.db.Dialect().Upsert(q.table, columns, conflict, update)
+	return q.db.ExecContext(q.context(), query, binds...)
+}
+
+// sortedColumns returns the keys of values in a stable order
+func sortedColumns(values map[string]interface{}) []string {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
 func (q *QueryBuilder) Update(values map[string]interface{}) error {
+	_, err := q.UpdateCount(values)
+	return err
+}
+
+// UpdateCount is Update, returning the number of rows changed. Checking it
+// lets a conditional update, such as one on a column still being NULL, act
+// as a compare-and-swap.
+func (q *QueryBuilder) UpdateCount(values map[string]interface{}) (int64, error) {
 	if len(values) == 0 {
-		return fmt.Errorf("no values provided for update")
+		return 0, fmt.Errorf("no values provided for update")
 	}
 	var sets []string
 	var binds []interface{}
 	for column, value := range values {
-		sets = append(sets, fmt.Sprintf("%s = ?", column))
+		sets = append(sets, fmt.Sprintf("%s = ?", q.quote(column)))
 		binds = append(binds, value)
 	}
-	query := fmt.Sprintf("UPDATE %s SET %s", q.table, strings.Join(sets, ", "))
+	query := fmt.Sprintf("UPDATE %s SET %s", q.quote(q.table), strings.Join(sets, ", "))
 	if len(q.wheres) > 0 {
-		query += " WHERE " + strings.Join(q.wheres, " AND ")
+		query += q.whereSQL()
 		binds = append(binds, q.whereBinds...)
 	}
-	err := q.db.Exec(query, binds...)
-	return err
+	return q.execCount(query, binds)
 }
 
 func (q *QueryBuilder) Delete() error {
-	query := fmt.Sprintf("DELETE FROM %s", q.table)
+	_, err := q.DeleteCount()
+	return err
+}
+
+// DeleteCount is Delete, returning the number of rows removed
+func (q *QueryBuilder) DeleteCount() (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s", q.quote(q.table))
 	var binds []interface{}
 	if len(q.wheres) > 0 {
-		query += " WHERE " + strings.Join(q.wheres, " AND ")
+		query += q.whereSQL()
 		binds = append(binds, q.whereBinds...)
 	}
-	err := q.db.Exec(query, binds...)
-	return err
-}
\ No newline at end of file
+	return q.execCount(query, binds)
+}
+
+// execCount runs query and returns the number of rows it affected
+func (q *QueryBuilder) execCount(query string, binds []interface{}) (int64, error) {
+	result, err := q.db.ExecResultContext(q.context(), query, binds...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,652 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Relation kinds, named in a field's rel tag
+const (
+	BelongsTo  = "belongsTo"
+	HasMany    = "hasMany"
+	ManyToMany = "manyToMany"
+)
+
+// relationMeta describes a relation field. Relations are declared with a rel
+// tag naming the kind, optionally followed by key=value options:
+//
+//	Author   *User     `rel:"belongsTo,foreignKey=user_id"`
+//	Comments []Comment `rel:"hasMany"`
+//	Tags     []Tag     `rel:"manyToMany,pivot=post_tag"`
+//
+// foreignKey is the column holding the reference: on this model for
+// belongsTo, on the related model for hasMany, and on the pivot table for
+// manyToMany. It defaults to the field name plus _id for belongsTo, and to
+// this model's name plus _id otherwise, so post_id for Post. ownerKey is the
+// column referenced, by default the primary key. A manyToMany pivot defaults
+// to both singular table names in alphabetical order, and its relatedKey,
+// pointing at the related model, to the related model's name plus _id.
+type relationMeta struct {
+	name       string
+	kind       string
+	index      []int
+	related    reflect.Type // The related model's struct type
+	many       bool         // The field is a slice
+	pointers   bool         // The field, or its elements, are pointers
+	foreignKey string
+	ownerKey   string
+	pivot      string
+	relatedKey string
+}
+
+// parseRelation parses the rel tag of field on model
+func parseRelation(model reflect.Type, field reflect.StructField, tag string) (*relationMeta, error) {
+	parts := strings.Split(tag, ",")
+	rel := &relationMeta{name: field.Name, kind: strings.TrimSpace(parts[0])}
+
+	typ := field.Type
+	if typ.Kind() == reflect.Slice {
+		rel.many = true
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Ptr {
+		rel.pointers = true
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s.%s: relation must be a struct, pointer or slice of them", model.Name(), field.Name)
+	}
+	rel.related = typ
+
+	for _, option := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "foreignKey":
+			rel.foreignKey = value
+		case "ownerKey":
+			rel.ownerKey = value
+		case "pivot":
+			rel.pivot = value
+		case "relatedKey":
+			rel.relatedKey = value
+		default:
+			return nil, fmt.Errorf("%s.%s: unknown relation option %q", model.Name(), field.Name, key)
+		}
+	}
+
+	switch rel.kind {
+	case BelongsTo:
+		if rel.many {
+			return nil, fmt.Errorf("%s.%s: belongsTo needs a struct or pointer field", model.Name(), field.Name)
+		}
+		if rel.foreignKey == "" {
+			rel.foreignKey = snakeCase(field.Name) + "_id"
+		}
+	case HasMany, ManyToMany:
+		if !rel.many {
+			return nil, fmt.Errorf("%s.%s: %s needs a slice field", model.Name(), field.Name, rel.kind)
+		}
+		if rel.foreignKey == "" {
+			rel.foreignKey = snakeCase(model.Name()) + "_id"
+		}
+		if rel.kind == ManyToMany {
+			if rel.relatedKey == "" {
+				rel.relatedKey = snakeCase(typ.Name()) + "_id"
+			}
+			if rel.pivot == "" {
+				names := []string{snakeCase(model.Name()), snakeCase(typ.Name())}
+				sort.Strings(names)
+				rel.pivot = strings.Join(names, "_")
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s.%s: unknown relation %q", model.Name(), field.Name, rel.kind)
+	}
+	return rel, nil
+}
+
+// Load loads the named relations of dest, a pointer to a model or to a slice
+// of models. Each relation takes one query, or two for manyToMany, however
+// many models dest holds. A dotted path such as "Comments.User" loads
+// Comments and then User on every comment loaded.
+func Load(ctx context.Context, e Executor, dest interface{}, relations ...string) error {
+	meta, err := modelOf(dest)
+	if err != nil {
+		return err
+	}
+
+	var loads []*eagerLoad
+	for _, path := range relations {
+		loads = addEagerLoad(loads, path, nil)
+	}
+	return loadEager(ctx, e, meta, modelValues(reflect.ValueOf(dest)), loads)
+}
+
+// eagerLoad is a relation to load, along with the relations to load in turn
+// on the models it returns
+type eagerLoad struct {
+	name      string
+	constrain func(q *QueryBuilder) // Optional, narrows the related query
+	nested    []*eagerLoad
+}
+
+// addEagerLoad adds a dotted relation path to loads, sharing the relations
+// loads already has. constrain, when not nil, applies to the last relation.
+func addEagerLoad(loads []*eagerLoad, path string, constrain func(q *QueryBuilder)) []*eagerLoad {
+	name, rest, nested := strings.Cut(path, ".")
+
+	var load *eagerLoad
+	for _, existing := range loads {
+		if existing.name == name {
+			load = existing
+			break
+		}
+	}
+	if load == nil {
+		load = &eagerLoad{name: name}
+		loads = append(loads, load)
+	}
+
+	if nested {
+		load.nested = addEagerLoad(load.nested, rest, constrain)
+	} else if constrain != nil {
+		load.constrain = constrain
+	}
+	return loads
+}
+
+// loadEager loads each relation in loads for models, then their nested
+// relations on all of the models loaded
+func loadEager(ctx context.Context, e Executor, owner *modelMeta, models []reflect.Value, loads []*eagerLoad) error {
+	for _, load := range loads {
+		rel, ok := owner.relations[load.name]
+		if !ok {
+			return fmt.Errorf("%s has no relation %s", owner.name, load.name)
+		}
+		if err := loadRelation(ctx, e, owner, rel, models, load.constrain); err != nil {
+			return fmt.Errorf("loading %s.%s: %w", owner.name, load.name, err)
+		}
+		if len(load.nested) == 0 {
+			continue
+		}
+
+		related, err := modelOfType(rel.related)
+		if err != nil {
+			return err
+		}
+		var loaded []reflect.Value
+		for _, model := range models {
+			loaded = append(loaded, modelValues(model.FieldByIndex(rel.index).Addr())...)
+		}
+		if err := loadEager(ctx, e, related, loaded, load.nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadCounts sets the <Relation>Count field of every model to its number of
+// related models, for each hasMany or manyToMany relation named. The count
+// field is an integer without a db tag, such as CommentsCount for Comments.
+func loadCounts(ctx context.Context, e Executor, owner *modelMeta, models []reflect.Value, relations []string) error {
+	if len(models) == 0 {
+		return nil
+	}
+
+	for _, name := range relations {
+		rel, ok := owner.relations[name]
+		if !ok {
+			return fmt.Errorf("%s has no relation %s", owner.name, name)
+		}
+		var table string
+		switch rel.kind {
+		case HasMany:
+			related, err := modelOfType(rel.related)
+			if err != nil {
+				return err
+			}
+			table = related.table
+		case ManyToMany:
+			table = rel.pivot
+		default:
+			return fmt.Errorf("counting %s.%s: only hasMany and manyToMany relations can be counted", owner.name, name)
+		}
+		field, ok := models[0].Type().FieldByName(name + "Count")
+		if !ok {
+			return fmt.Errorf("%s has no %sCount field", owner.name, name)
+		}
+		ownerKey, err := owner.column(orDefault(rel.ownerKey, owner.primaryKey()))
+		if err != nil {
+			return err
+		}
+
+		counts, err := countRelated(ctx, e, table, rel.foreignKey, keysOf(models, ownerKey))
+		if err != nil {
+			return fmt.Errorf("counting %s.%s: %w", owner.name, name, err)
+		}
+		for _, model := range models {
+			key, _ := keyOf(model.FieldByIndex(ownerKey.index))
+			count := model.FieldByIndex(field.Index)
+			switch count.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				count.SetInt(counts[key])
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				count.SetUint(uint64(counts[key]))
+			default:
+				return fmt.Errorf("%s.%sCount must be an integer", owner.name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// countRelated returns the number of rows in table for each of the keys in
+// column, keyed as by keyOf
+func countRelated(ctx context.Context, e Executor, table, column string, keys []interface{}) (map[string]int64, error) {
+	counts := make(map[string]int64, len(keys))
+	if len(keys) == 0 {
+		return counts, nil
+	}
+	d := e.Dialect()
+	query := fmt.Sprintf("SELECT %s, COUNT(*) FROM %s WHERE %s IN (%s) GROUP BY %s",
+		d.Quote(column), d.Quote(table), d.Quote(column), placeholders(len(keys)), d.Quote(column))
+
+	rows, err := e.QueryContext(ctx, query, keys...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key sql.NullString
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, err
+		}
+		counts[key.String] = count
+	}
+	return counts, rows.Err()
+}
+
+// modelValues returns the addressable structs held by v, which may be a
+// struct, a slice or pointers to either. Nil pointers are skipped.
+func modelValues(v reflect.Value) []reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.CanAddr() {
+			return []reflect.Value{v}
+		}
+	case reflect.Slice:
+		var models []reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			models = append(models, modelValues(v.Index(i).Addr())...)
+		}
+		return models
+	}
+	return nil
+}
+
+// loadRelation loads rel for every model and stores the results in their
+// relation fields. constrain, when not nil, narrows the related query.
+func loadRelation(ctx context.Context, e Executor, owner *modelMeta, rel *relationMeta, models []reflect.Value, constrain func(q *QueryBuilder)) error {
+	if len(models) == 0 {
+		return nil
+	}
+	related, err := modelOfType(rel.related)
+	if err != nil {
+		return err
+	}
+
+	switch rel.kind {
+	case BelongsTo:
+		return loadBelongsTo(ctx, e, owner, related, rel, models, constrain)
+	case HasMany:
+		return loadHasMany(ctx, e, owner, related, rel, models, constrain)
+	default:
+		return loadManyToMany(ctx, e, owner, related, rel, models, constrain)
+	}
+}
+
+// loadBelongsTo fetches the models referenced by each model's foreign key
+func loadBelongsTo(ctx context.Context, e Executor, owner, related *modelMeta, rel *relationMeta, models []reflect.Value, constrain func(q *QueryBuilder)) error {
+	foreignKey, err := owner.column(rel.foreignKey)
+	if err != nil {
+		return err
+	}
+	ownerKey, err := related.column(orDefault(rel.ownerKey, related.primaryKey()))
+	if err != nil {
+		return err
+	}
+
+	rows, err := fetchRelated(ctx, e, related, ownerKey.column, keysOf(models, foreignKey), constrain)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]reflect.Value, rows.Len())
+	for i := 0; i < rows.Len(); i++ {
+		if key, ok := keyOf(rows.Index(i).FieldByIndex(ownerKey.index)); ok {
+			byKey[key] = rows.Index(i)
+		}
+	}
+
+	for _, model := range models {
+		field := model.FieldByIndex(rel.index)
+		key, ok := keyOf(model.FieldByIndex(foreignKey.index))
+		row, found := byKey[key]
+		switch {
+		case !ok || !found:
+			field.Set(reflect.Zero(field.Type()))
+		case rel.pointers:
+			field.Set(row.Addr())
+		default:
+			field.Set(row)
+		}
+	}
+	return nil
+}
+
+// loadHasMany fetches the models whose foreign key references each model
+func loadHasMany(ctx context.Context, e Executor, owner, related *modelMeta, rel *relationMeta, models []reflect.Value, constrain func(q *QueryBuilder)) error {
+	ownerKey, err := owner.column(orDefault(rel.ownerKey, owner.primaryKey()))
+	if err != nil {
+		return err
+	}
+	foreignKey, err := related.column(rel.foreignKey)
+	if err != nil {
+		return err
+	}
+
+	rows, err := fetchRelated(ctx, e, related, foreignKey.column, keysOf(models, ownerKey), constrain)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string][]reflect.Value)
+	for i := 0; i < rows.Len(); i++ {
+		if key, ok := keyOf(rows.Index(i).FieldByIndex(foreignKey.index)); ok {
+			byKey[key] = append(byKey[key], rows.Index(i))
+		}
+	}
+
+	for _, model := range models {
+		key, _ := keyOf(model.FieldByIndex(ownerKey.index))
+		setMany(model.FieldByIndex(rel.index), rel, byKey[key])
+	}
+	return nil
+}
+
+// loadManyToMany fetches the models linked to each model through the pivot
+func loadManyToMany(ctx context.Context, e Executor, owner, related *modelMeta, rel *relationMeta, models []reflect.Value, constrain func(q *QueryBuilder)) error {
+	ownerKey, err := owner.column(orDefault(rel.ownerKey, owner.primaryKey()))
+	if err != nil {
+		return err
+	}
+	relatedKey, err := related.column(related.primaryKey())
+	if err != nil {
+		return err
+	}
+
+	links, err := pivotLinks(ctx, e, rel, keysOf(models, ownerKey)...)
+	if err != nil {
+		return err
+	}
+	var relatedIDs []interface{}
+	seen := make(map[string]bool)
+	for _, link := range links {
+		if !seen[link[1]] {
+			seen[link[1]] = true
+			relatedIDs = append(relatedIDs, link[1])
+		}
+	}
+
+	rows, err := fetchRelated(ctx, e, related, relatedKey.column, relatedIDs, constrain)
+	if err != nil {
+		return err
+	}
+	// Rows are handed out in the order they were fetched, so that an order
+	// set by a constraint holds for each model
+	owners := make(map[string][]string)
+	for _, link := range links {
+		owners[link[1]] = append(owners[link[1]], link[0])
+	}
+	byKey := make(map[string][]reflect.Value)
+	for i := 0; i < rows.Len(); i++ {
+		id, ok := keyOf(rows.Index(i).FieldByIndex(relatedKey.index))
+		if !ok {
+			continue
+		}
+		for _, key := range owners[id] {
+			byKey[key] = append(byKey[key], rows.Index(i))
+		}
+	}
+
+	for _, model := range models {
+		key, _ := keyOf(model.FieldByIndex(ownerKey.index))
+		setMany(model.FieldByIndex(rel.index), rel, byKey[key])
+	}
+	return nil
+}
+
+// ErrEagerLimit is returned when an eager load constraint sets a limit or
+// offset. The related models of every parent are loaded in one query, so a
+// limit would apply to all of them together rather than to each parent.
+var ErrEagerLimit = errors.New("eager load constraints cannot set a limit or offset")
+
+// fetchRelated returns a slice of the related models whose column is one of
+// keys, narrowed by constrain when it is not nil. No query is run when keys
+// is empty.
+func fetchRelated(ctx context.Context, e Executor, related *modelMeta, column string, keys []interface{}, constrain func(q *QueryBuilder)) (reflect.Value, error) {
+	rows := reflect.New(reflect.SliceOf(related.model))
+	query := NewQueryBuilder(e, related.table).WithContext(ctx).WhereIn(column, keys...)
+	if constrain != nil {
+		constrain(query)
+		if query.limit > 0 || query.offset > 0 {
+			return reflect.Value{}, ErrEagerLimit
+		}
+	}
+	if len(keys) > 0 {
+		if err := query.Get(rows.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return rows.Elem(), nil
+}
+
+// pivotLinks returns the [foreign key, related key] pairs in rel's pivot
+// table for the given foreign keys, as strings
+func pivotLinks(ctx context.Context, e Executor, rel *relationMeta, keys ...interface{}) ([][2]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	d := e.Dialect()
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s IN (%s)",
+		d.Quote(rel.foreignKey), d.Quote(rel.relatedKey), d.Quote(rel.pivot), d.Quote(rel.foreignKey), placeholders(len(keys)))
+
+	rows, err := e.QueryContext(ctx, query, keys...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links [][2]string
+	for rows.Next() {
+		var foreignKey, relatedKey sql.NullString
+		if err := rows.Scan(&foreignKey, &relatedKey); err != nil {
+			return nil, err
+		}
+		links = append(links, [2]string{foreignKey.String, relatedKey.String})
+	}
+	return links, rows.Err()
+}
+
+// setMany stores rows in a slice relation field, leaving an empty rather
+// than nil slice when there are none
+func setMany(field reflect.Value, rel *relationMeta, rows []reflect.Value) {
+	slice := reflect.MakeSlice(field.Type(), 0, len(rows))
+	for _, row := range rows {
+		if rel.pointers {
+			slice = reflect.Append(slice, row.Addr())
+		} else {
+			slice = reflect.Append(slice, row)
+		}
+	}
+	field.Set(slice)
+}
+
+// keysOf returns the distinct, non-nil values of field across models
+func keysOf(models []reflect.Value, field *fieldMeta) []interface{} {
+	var keys []interface{}
+	seen := make(map[string]bool)
+	for _, model := range models {
+		value := model.FieldByIndex(field.index)
+		key, ok := keyOf(value)
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, reflect.Indirect(value).Interface())
+	}
+	return keys
+}
+
+// keyOf returns a key value as a string, so that keys of different integer
+// types, or read back as text, compare equal. It reports false for nil.
+func keyOf(value reflect.Value) (string, bool) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", false
+		}
+		value = value.Elem()
+	}
+	if b, ok := value.Interface().([]byte); ok {
+		return string(b), true
+	}
+	return fmt.Sprint(value.Interface()), true
+}
+
+// placeholders returns n comma-separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// orDefault returns value, or fallback when value is empty
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// pivotRelation returns the manyToMany relation named relation on entity and
+// the entity's key in its pivot table
+func pivotRelation(entity interface{}, relation string) (*relationMeta, interface{}, error) {
+	meta, err := modelOf(entity)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel, ok := meta.relations[relation]
+	if !ok || rel.kind != ManyToMany {
+		return nil, nil, fmt.Errorf("%s has no manyToMany relation %s", meta.name, relation)
+	}
+	ownerKey, err := meta.column(orDefault(rel.ownerKey, meta.primaryKey()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return rel, reflect.Indirect(reflect.ValueOf(entity)).FieldByIndex(ownerKey.index).Interface(), nil
+}
+
+// attachPivot adds pivot rows linking entity to ids, ignoring links that exist
+func attachPivot(ctx context.Context, e Executor, entity interface{}, relation string, ids []interface{}) error {
+	rel, key, err := pivotRelation(entity, relation)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err := NewQueryBuilder(e, rel.pivot).WithContext(ctx).InsertOrIgnore(map[string]interface{}{
+			rel.foreignKey: key,
+			rel.relatedKey: id,
+		}, rel.foreignKey, rel.relatedKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detachPivot removes the pivot rows linking entity to ids, or all of its pivot
+// rows when no ids are given
+func detachPivot(ctx context.Context, e Executor, entity interface{}, relation string, ids []interface{}) error {
+	rel, key, err := pivotRelation(entity, relation)
+	if err != nil {
+		return err
+	}
+	query := NewQueryBuilder(e, rel.pivot).WithContext(ctx).Where(rel.foreignKey, "=", key)
+	if len(ids) > 0 {
+		query.WhereIn(rel.relatedKey, ids...)
+	}
+	return query.Delete()
+}
+
+// syncPivot makes ids the only models linked to entity, in a transaction
+func syncPivot(ctx context.Context, e Executor, entity interface{}, relation string, ids []interface{}) error {
+	rel, key, err := pivotRelation(entity, relation)
+	if err != nil {
+		return err
+	}
+
+	return inTransaction(ctx, e, func(tx Executor) error {
+		links, err := pivotLinks(ctx, tx, rel, key)
+		if err != nil {
+			return err
+		}
+		wanted := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			k, _ := keyOf(reflect.ValueOf(id))
+			wanted[k] = true
+		}
+
+		var stale, missing []interface{}
+		linked := make(map[string]bool, len(links))
+		for _, link := range links {
+			linked[link[1]] = true
+			if !wanted[link[1]] {
+				stale = append(stale, link[1])
+			}
+		}
+		for _, id := range ids {
+			if k, _ := keyOf(reflect.ValueOf(id)); !linked[k] {
+				missing = append(missing, id)
+			}
+		}
+
+		if len(stale) > 0 {
+			if err := detachPivot(ctx, tx, entity, relation, stale); err != nil {
+				return err
+			}
+		}
+		return attachPivot(ctx, tx, entity, relation, missing)
+	})
+}
+
+// inTransaction runs fn in a transaction on e, or in a savepoint when e is
+// already a transaction
+func inTransaction(ctx context.Context, e Executor, fn func(tx Executor) error) error {
+	switch e := e.(type) {
+	case *Database:
+		return e.Transaction(ctx, func(tx *Transaction) error { return fn(tx) })
+	case *Transaction:
+		return e.Transaction(ctx, func(tx *Transaction) error { return fn(tx) })
+	}
+	return fn(e)
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type author struct {
+	Entity
+	Name       string `db:"name"`
+	Books      []book `rel:"hasMany"`
+	BooksCount int
+}
+
+type book struct {
+	Entity
+	AuthorID uint    `db:"author_id"`
+	Title    string  `db:"title"`
+	Author   *author `rel:"belongsTo"`
+	Tags     []tag   `rel:"manyToMany"`
+}
+
+type tag struct {
+	Entity
+	Name string `db:"name"`
+}
+
+// newLibrary creates two authors, three books and three tags:
+//
+//	Ann: Alpha (fiction, classic), Beta (fiction)
+//	Bob: Gamma
+func newLibrary(t *testing.T) *Database {
+	t.Helper()
+	database := newTestDatabase(t)
+	schema := NewSchema(database)
+
+	tables := map[string]func(*Blueprint){
+		"authors": func(table *Blueprint) {
+			table.ID()
+			table.String("name", 100)
+			table.Timestamps()
+		},
+		"books": func(table *Blueprint) {
+			table.ID()
+			table.ForeignID("author_id")
+			table.String("title", 100)
+			table.Timestamps()
+			table.Foreign("author_id").References("authors")
+		},
+		"tags": func(table *Blueprint) {
+			table.ID()
+			table.String("name", 100)
+			table.Timestamps()
+		},
+		"book_tag": func(table *Blueprint) {
+			table.ForeignID("book_id")
+			table.ForeignID("tag_id")
+			table.Primary("book_id", "tag_id")
+		},
+	}
+	for _, name := range []string{"authors", "books", "tags", "book_tag"} {
+		if err := schema.Create(name, tables[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	authors := NewRepository[author](database)
+	books := NewRepository[book](database)
+	tags := NewRepository[tag](database)
+	for _, a := range []*author{{Name: "Ann"}, {Name: "Bob"}} {
+		if err := authors.Create(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, b := range []*book{{AuthorID: 1, Title: "Alpha"}, {AuthorID: 1, Title: "Beta"}, {AuthorID: 2, Title: "Gamma"}} {
+		if err := books.Create(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tg := range []*tag{{Name: "fiction"}, {Name: "classic"}, {Name: "poetry"}} {
+		if err := tags.Create(tg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alpha, beta := &book{}, &book{}
+	alpha.ID, beta.ID = 1, 2
+	if err := books.Attach(alpha, "Tags", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := books.Attach(beta, "Tags", 1); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+// titles joins the titles of books
+func titles(books []book) string {
+	names := make([]string, len(books))
+	for i, b := range books {
+		names[i] = b.Title
+	}
+	return strings.Join(names, ",")
+}
+
+// tagNames joins the names of tags
+func tagNames(tags []tag) string {
+	names := make([]string, len(tags))
+	for i, tg := range tags {
+		names[i] = tg.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLoadBelongsTo(t *testing.T) {
+	database := newLibrary(t)
+
+	var books []book
+	if err := NewRepository[book](database).Query().OrderBy("id").With("Author").Find(&books); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Ann", "Ann", "Bob"}
+	for i, b := range books {
+		if b.Author == nil || b.Author.Name != want[i] {
+			t.Errorf("%s: author = %+v, want %s", b.Title, b.Author, want[i])
+		}
+	}
+}
+
+func TestLoadHasManyAndNested(t *testing.T) {
+	database := newLibrary(t)
+
+	var authors []author
+	err := NewRepository[author](database).Query().OrderBy("id").
+		WithConstraint("Books", func(q *QueryBuilder) { q.OrderBy("title", "DESC") }).
+		With("Books.Tags").
+		Find(&authors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authors) != 2 {
+		t.Fatalf("found %d authors", len(authors))
+	}
+
+	if got := titles(authors[0].Books); got != "Beta,Alpha" {
+		t.Errorf("Ann's books = %s", got)
+	}
+	if got := titles(authors[1].Books); got != "Gamma" {
+		t.Errorf("Bob's books = %s", got)
+	}
+
+	tagsByTitle := map[string]string{}
+	for _, a := range authors {
+		for _, b := range a.Books {
+			tagsByTitle[b.Title] = tagNames(b.Tags)
+		}
+	}
+	for title, want := range map[string]string{"Alpha": "fiction,classic", "Beta": "fiction", "Gamma": ""} {
+		if got := tagsByTitle[title]; got != want {
+			t.Errorf("%s tags = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestLoadManyToManyOnOneModel(t *testing.T) {
+	database := newLibrary(t)
+	books := NewRepository[book](database)
+
+	var alpha book
+	if err := books.FindByID(1, &alpha); err != nil {
+		t.Fatal(err)
+	}
+	if err := books.Load(&alpha, "Tags", "Author"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(alpha.Tags); got != "fiction,classic" {
+		t.Errorf("tags = %s", got)
+	}
+	if alpha.Author == nil || alpha.Author.Name != "Ann" {
+		t.Errorf("author = %+v", alpha.Author)
+	}
+}
+
+func TestSyncManyToMany(t *testing.T) {
+	database := newLibrary(t)
+	books := NewRepository[book](database)
+
+	alpha := &book{}
+	alpha.ID = 1
+	if err := books.Sync(alpha, "Tags", uint(2), uint(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := books.Load(alpha, "Tags"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(alpha.Tags); got != "classic,poetry" {
+		t.Errorf("tags after sync = %s", got)
+	}
+
+	// Beta's links are untouched
+	beta := &book{}
+	beta.ID = 2
+	if err := books.Load(beta, "Tags"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(beta.Tags); got != "fiction" {
+		t.Errorf("other book's tags = %s", got)
+	}
+
+	if err := books.Sync(alpha, "Tags"); err != nil {
+		t.Fatal(err)
+	}
+	alpha.Tags = nil
+	if err := books.Load(alpha, "Tags"); err != nil {
+		t.Fatal(err)
+	}
+	if len(alpha.Tags) != 0 {
+		t.Errorf("tags after syncing to none = %s", tagNames(alpha.Tags))
+	}
+}
+
+func TestWithCount(t *testing.T) {
+	database := newLibrary(t)
+	if err := database.Create(&author{Name: "Cat"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var authors []author
+	if err := NewRepository[author](database).Query().OrderBy("id").WithCount("Books").Find(&authors); err != nil {
+		t.Fatal(err)
+	}
+	want := []int{2, 1, 0}
+	for i, a := range authors {
+		if a.BooksCount != want[i] {
+			t.Errorf("%s: BooksCount = %d, want %d", a.Name, a.BooksCount, want[i])
+		}
+		if a.Books != nil {
+			t.Errorf("%s: WithCount loaded the books", a.Name)
+		}
+	}
+}
+
+func TestEagerConstraintRejectsLimit(t *testing.T) {
+	database := newLibrary(t)
+	authors := NewRepository[author](database)
+
+	constraints := map[string]func(q *QueryBuilder){
+		"limit":  func(q *QueryBuilder) { q.Limit(1) },
+		"offset": func(q *QueryBuilder) { q.Offset(1) },
+	}
+	for name, constrain := range constraints {
+		var found []author
+		err := authors.Query().WithConstraint("Books", constrain).Find(&found)
+		if !errors.Is(err, ErrEagerLimit) {
+			t.Errorf("%s: Find returned %v, want ErrEagerLimit", name, err)
+		}
+	}
+}
@@ -17,29 +17,36 @@ func NewSchema(db *Database) *Schema {
 
 // Blueprint defines the structure of a table
 type Blueprint struct {
-	table     string
-	columns   []Column
-	indexes   []Index
-	primary   string
-	engine    string
-	charset   string
-	collation string
-	temporary bool
+	table       string
+	columns     []Column
+	indexes     []Index
+	foreignKeys []*ForeignKey
+	drops       []string
+	primary     []string
+	engine      string
+	charset     string
+	collation   string
+	temporary   bool
+	ifNotExists bool
 }
 
 // Column represents a table column
 type Column struct {
-	name       string
-	columnType string // Changed from 'type' to 'columnType' since 'type' is a reserved word
-	length     int
-	nullable   bool
-	default_   interface{}
-	unsigned   bool
-	unique     bool
-	index      bool
-	comment    string
-	after      string
-	first      bool
+	name          string
+	columnType    string // Abstract type such as "string" or "dateTime", mapped by the Dialect
+	length        int
+	precision     int
+	scale         int
+	autoIncrement bool
+	nullable      bool
+	default_      interface{}
+	useCurrent    bool
+	unsigned      bool
+	unique        bool
+	index         bool
+	comment       string
+	after         string
+	first         bool
 }
 
 // Index represents a table index
@@ -49,6 +56,14 @@ type Index struct {
 	unique  bool
 }
 
+// ForeignKey represents a foreign key constraint
+type ForeignKey struct {
+	columns    []string
+	references []string
+	on         string
+	onDelete   string
+}
+
 // Create creates a new table
 func (s *Schema) Create(table string, callback func(*Blueprint)) error {
 	blueprint := &Blueprint{
@@ -66,6 +81,14 @@ func (s *Schema) Create(table string, callback func(*Blueprint)) error {
 	return s.createTable(blueprint)
 }
 
+// CreateIfNotExists creates a table and its indexes unless the table already exists
+func (s *Schema) CreateIfNotExists(table string, callback func(*Blueprint)) error {
+	return s.Create(table, func(blueprint *Blueprint) {
+		blueprint.ifNotExists = true
+		callback(blueprint)
+	})
+}
+
 // Table modifies an existing table
 func (s *Schema) Table(table string, callback func(*Blueprint)) error {
 	blueprint := &Blueprint{
@@ -81,7 +104,7 @@ func (s *Schema) Table(table string, callback func(*Blueprint)) error {
 
 // Drop drops a table
 func (s *Schema) Drop(table string) error {
-	sql := fmt.Sprintf("DROP TABLE IF EXISTS `%s`", table)
+	sql := fmt.Sprintf("DROP TABLE IF EXISTS %s", s.quote(table))
 	return s.db.Exec(sql)
 }
 
@@ -92,10 +115,56 @@ func (s *Schema) DropIfExists(table string) error {
 
 // Rename renames a table
 func (s *Schema) Rename(from, to string) error {
-	sql := fmt.Sprintf("ALTER TABLE `%s` RENAME TO `%s`", from, to)
+	sql := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", s.quote(from), s.quote(to))
 	return s.db.Exec(sql)
 }
 
+// RenameColumn renames a column
+func (s *Schema) RenameColumn(table, from, to string) error {
+	sql := fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", s.quote(table), s.quote(from), s.quote(to))
+	return s.db.Exec(sql)
+}
+
+// quote quotes an identifier for the database's dialect
+func (s *Schema) quote(identifier string) string {
+	return s.db.Dialect().Quote(identifier)
+}
+
+// quoteAll quotes each identifier and joins them with commas
+func (s *Schema) quoteAll(identifiers []string) string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = s.quote(identifier)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// isMySQL reports whether MySQL-only table and column options apply
+func (s *Schema) isMySQL() bool {
+	_, ok := s.db.Dialect().(MySQLDialect)
+	return ok
+}
+
+// createIndex returns a standalone CREATE INDEX statement for dialects that
+// cannot declare indexes inside CREATE or ALTER TABLE
+func (s *Schema) createIndex(table string, index Index) string {
+	unique := ""
+	if index.unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, s.quote(index.name), s.quote(table), s.quoteAll(index.columns))
+}
+
+// foreignKeyDefinition returns the SQL definition for a foreign key
+func (s *Schema) foreignKeyDefinition(foreignKey *ForeignKey) string {
+	def := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)",
+		s.quoteAll(foreignKey.columns), s.quote(foreignKey.on), s.quoteAll(foreignKey.references))
+	if foreignKey.onDelete != "" {
+		def += " ON DELETE " + strings.ToUpper(foreignKey.onDelete)
+	}
+	return def
+}
+
 // createTable creates a new table
 func (s *Schema) createTable(blueprint *Blueprint) error {
 	var sql strings.Builder
@@ -105,69 +174,135 @@ func (s *Schema) createTable(blueprint *Blueprint) error {
 	} else {
 		sql.WriteString("CREATE TABLE ")
 	}
+	if blueprint.ifNotExists {
+		sql.WriteString("IF NOT EXISTS ")
+	}
 	
-	sql.WriteString(fmt.Sprintf("`%s` (", blueprint.table))
+	sql.WriteString(s.quote(blueprint.table) + " (")
 	
 	// Add columns
 	var columnDefs []string
+	inlinePrimary := false
 	for _, column := range blueprint.columns {
-		columnDefs = append(columnDefs, s.getColumnDefinition(column))
+		def := s.getColumnDefinition(column)
+		if strings.Contains(def, " PRIMARY KEY") {
+			inlinePrimary = true
+		}
+		columnDefs = append(columnDefs, def)
 	}
 	
-	// Add primary key
-	if blueprint.primary != "" {
-		columnDefs = append(columnDefs, fmt.Sprintf("PRIMARY KEY (`%s`)", blueprint.primary))
+	// Add primary key, unless a column already declared it
+	if len(blueprint.primary) > 0 && !inlinePrimary {
+		columnDefs = append(columnDefs, fmt.Sprintf("PRIMARY KEY (%s)", s.quoteAll(blueprint.primary)))
 	}
 	
-	// Add indexes
+	// Add foreign keys
+	for _, foreignKey := range blueprint.foreignKeys {
+		columnDefs = append(columnDefs, s.foreignKeyDefinition(foreignKey))
+	}
+
+	// Add indexes; only MySQL declares them inside CREATE TABLE
+	var indexStatements []string
 	for _, index := range blueprint.indexes {
-		cols := make([]string, len(index.columns))
-		for i, col := range index.columns {
-			cols[i] = fmt.Sprintf("`%s`", col)
+		if !s.isMySQL() {
+			statement := s.createIndex(blueprint.table, index)
+			if blueprint.ifNotExists {
+				statement = strings.Replace(statement, "INDEX ", "INDEX IF NOT EXISTS ", 1)
+			}
+			indexStatements = append(indexStatements, statement)
+			continue
 		}
 		if index.unique {
-			columnDefs = append(columnDefs, fmt.Sprintf("UNIQUE INDEX `%s` (%s)", index.name, strings.Join(cols, ", ")))
+			columnDefs = append(columnDefs, fmt.Sprintf("UNIQUE INDEX %s (%s)", s.quote(index.name), s.quoteAll(index.columns)))
 		} else {
-			columnDefs = append(columnDefs, fmt.Sprintf("INDEX `%s` (%s)", index.name, strings.Join(cols, ", ")))
+			columnDefs = append(columnDefs, fmt.Sprintf("INDEX %s (%s)", s.quote(index.name), s.quoteAll(index.columns)))
 		}
 	}
 	
 	sql.WriteString(strings.Join(columnDefs, ", "))
 	sql.WriteString(")")
 	
-	// Add engine
-	if blueprint.engine != "" {
-		sql.WriteString(fmt.Sprintf(" ENGINE=%s", blueprint.engine))
+	if s.isMySQL() {
+		// Add engine
+		if blueprint.engine != "" {
+			sql.WriteString(fmt.Sprintf(" ENGINE=%s", blueprint.engine))
+		}
+
+		// Add charset
+		if blueprint.charset != "" {
+			sql.WriteString(fmt.Sprintf(" DEFAULT CHARSET=%s", blueprint.charset))
+		}
+
+		// Add collation
+		if blueprint.collation != "" {
+			sql.WriteString(fmt.Sprintf(" COLLATE=%s", blueprint.collation))
+		}
 	}
 	
-	// Add charset
-	if blueprint.charset != "" {
-		sql.WriteString(fmt.Sprintf(" DEFAULT CHARSET=%s", blueprint.charset))
+	if err := s.db.Exec(sql.String()); err != nil {
+		return err
 	}
 	
-	// Add collation
-	if blueprint.collation != "" {
-		sql.WriteString(fmt.Sprintf(" COLLATE=%s", blueprint.collation))
+	for _, statement := range indexStatements {
+		if err := s.db.Exec(statement); err != nil {
+			return err
+		}
 	}
 	
-	return s.db.Exec(sql.String())
+	return nil
 }
 
-// alterTable alters an existing table
+// alterTable alters an existing table. MySQL applies every change in one
+// statement; other dialects change one column per statement and create
+// indexes separately. SQLite cannot add foreign keys to an existing table.
 func (s *Schema) alterTable(blueprint *Blueprint) error {
+	table := s.quote(blueprint.table)
+
+	if !s.isMySQL() {
+		if _, ok := s.db.Dialect().(SQLiteDialect); ok && len(blueprint.foreignKeys) > 0 {
+			return fmt.Errorf("sqlite cannot add foreign keys to existing table %s", blueprint.table)
+		}
+
+		var statements []string
+		for _, column := range blueprint.drops {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, s.quote(column)))
+		}
+		for _, column := range blueprint.columns {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, s.getColumnDefinition(column)))
+		}
+		for _, foreignKey := range blueprint.foreignKeys {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s", table, s.foreignKeyDefinition(foreignKey)))
+		}
+		for _, index := range blueprint.indexes {
+			statements = append(statements, s.createIndex(blueprint.table, index))
+		}
+
+		for _, statement := range statements {
+			if err := s.db.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	var sql strings.Builder
 	
 	sql.WriteString("ALTER TABLE ")
-	sql.WriteString(fmt.Sprintf("`%s`", blueprint.table))
+	sql.WriteString(table)
 	
 	var alterations []string
 	
+	// Drop columns
+	for _, column := range blueprint.drops {
+		alterations = append(alterations, fmt.Sprintf("DROP COLUMN %s", s.quote(column)))
+	}
+
 	// Add columns
 	for _, column := range blueprint.columns {
 		var alteration string
 		
 		if column.after != "" {
-			alteration = fmt.Sprintf("ADD COLUMN %s AFTER `%s`", s.getColumnDefinition(column), column.after)
+			alteration = fmt.Sprintf("ADD COLUMN %s AFTER %s", s.getColumnDefinition(column), s.quote(column.after))
 		} else if column.first {
 			alteration = fmt.Sprintf("ADD COLUMN %s FIRST", s.getColumnDefinition(column))
 		} else {
@@ -180,20 +315,21 @@ func (s *Schema) alterTable(blueprint *Blueprint) error {
 	// Add indexes
 	for _, index := range blueprint.indexes {
 		var alteration string
-		cols := make([]string, len(index.columns))
-		for i, col := range index.columns {
-			cols[i] = fmt.Sprintf("`%s`", col)
-		}
 		
 		if index.unique {
-			alteration = fmt.Sprintf("ADD UNIQUE INDEX `%s` (%s)", index.name, strings.Join(cols, ", "))
+			alteration = fmt.Sprintf("ADD UNIQUE INDEX %s (%s)", s.quote(index.name), s.quoteAll(index.columns))
 		} else {
-			alteration = fmt.Sprintf("ADD INDEX `%s` (%s)", index.name, strings.Join(cols, ", "))
+			alteration = fmt.Sprintf("ADD INDEX %s (%s)", s.quote(index.name), s.quoteAll(index.columns))
 		}
 		
 		alterations = append(alterations, alteration)
 	}
 	
+	// Add foreign keys
+	for _, foreignKey := range blueprint.foreignKeys {
+		alterations = append(alterations, "ADD "+s.foreignKeyDefinition(foreignKey))
+	}
+
 	sql.WriteString(" ")
 	sql.WriteString(strings.Join(alterations, ", "))
 	
@@ -204,16 +340,8 @@ func (s *Schema) alterTable(blueprint *Blueprint) error {
 func (s *Schema) getColumnDefinition(column Column) string {
 	var def strings.Builder
 	
-	def.WriteString(fmt.Sprintf("`%s` ", column.name))
-	def.WriteString(column.columnType)
-	
-	if column.length > 0 {
-		def.WriteString(fmt.Sprintf("(%d)", column.length))
-	}
-	
-	if column.unsigned {
-		def.WriteString(" UNSIGNED")
-	}
+	def.WriteString(s.quote(column.name) + " ")
+	def.WriteString(s.db.Dialect().ColumnType(column))
 	
 	if !column.nullable {
 		def.WriteString(" NOT NULL")
@@ -221,10 +349,12 @@ func (s *Schema) getColumnDefinition(column Column) string {
 		def.WriteString(" NULL")
 	}
 	
-	if column.default_ != nil {
+	if column.useCurrent {
+		def.WriteString(" DEFAULT CURRENT_TIMESTAMP")
+	} else if column.default_ != nil {
 		switch v := column.default_.(type) {
 		case string:
-			def.WriteString(fmt.Sprintf(" DEFAULT '%s'", v))
+			def.WriteString(fmt.Sprintf(" DEFAULT '%s'", strings.ReplaceAll(v, "'", "''")))
 		default:
 			def.WriteString(fmt.Sprintf(" DEFAULT %v", v))
 		}
@@ -234,7 +364,7 @@ func (s *Schema) getColumnDefinition(column Column) string {
 		def.WriteString(" UNIQUE")
 	}
 	
-	if column.comment != "" {
+	if column.comment != "" && s.isMySQL() {
 		def.WriteString(fmt.Sprintf(" COMMENT '%s'", strings.ReplaceAll(column.comment, "'", "''")))
 	}
 	
@@ -253,7 +383,7 @@ func (b *Blueprint) ID() *Column {
 func (b *Blueprint) String(name string, length int) *Column {
 	column := Column{
 		name:       name,
-		columnType: "VARCHAR",
+		columnType: "string",
 		length:     length,
 		nullable:   false,
 	}
@@ -266,7 +396,7 @@ func (b *Blueprint) String(name string, length int) *Column {
 func (b *Blueprint) Text(name string) *Column {
 	column := Column{
 		name:       name,
-		columnType: "TEXT",
+		columnType: "text",
 		nullable:   false,
 	}
 	
@@ -278,12 +408,9 @@ func (b *Blueprint) Text(name string) *Column {
 func (b *Blueprint) Integer(name string, autoIncrement bool) *Column {
 	column := Column{
 		name:       name,
-		columnType: "INTEGER",
-		nullable:   false,
-	}
-	
-	if autoIncrement {
-		column.columnType = "SERIAL"
+		columnType:    "integer",
+		nullable:      false,
+		autoIncrement: autoIncrement,
 	}
 	
 	b.columns = append(b.columns, column)
@@ -294,12 +421,9 @@ func (b *Blueprint) Integer(name string, autoIncrement bool) *Column {
 func (b *Blueprint) BigInteger(name string, autoIncrement bool) *Column {
 	column := Column{
 		name:       name,
-		columnType: "BIGINT",
-		nullable:   false,
-	}
-	
-	if autoIncrement {
-		column.columnType = "BIGSERIAL"
+		columnType:    "bigInteger",
+		nullable:      false,
+		autoIncrement: autoIncrement,
 	}
 	
 	b.columns = append(b.columns, column)
@@ -310,7 +434,7 @@ func (b *Blueprint) BigInteger(name string, autoIncrement bool) *Column {
 func (b *Blueprint) Boolean(name string) *Column {
 	column := Column{
 		name:       name,
-		columnType: "BOOLEAN",
+		columnType: "boolean",
 		nullable:   false,
 	}
 	
@@ -322,7 +446,7 @@ func (b *Blueprint) Boolean(name string) *Column {
 func (b *Blueprint) Date(name string) *Column {
 	column := Column{
 		name:       name,
-		columnType: "DATE",
+		columnType: "date",
 		nullable:   false,
 	}
 	
@@ -334,7 +458,7 @@ func (b *Blueprint) Date(name string) *Column {
 func (b *Blueprint) DateTime(name string) *Column {
 	column := Column{
 		name:       name,
-		columnType: "TIMESTAMP",
+		columnType: "dateTime",
 		nullable:   false,
 	}
 	
@@ -346,7 +470,9 @@ func (b *Blueprint) DateTime(name string) *Column {
 func (b *Blueprint) Decimal(name string, precision, scale int) *Column {
 	column := Column{
 		name:       name,
-		columnType: fmt.Sprintf("DECIMAL(%d,%d)", precision, scale),
+		columnType: "decimal",
+		precision:  precision,
+		scale:      scale,
 		nullable:   false,
 	}
 	
@@ -358,7 +484,7 @@ func (b *Blueprint) Decimal(name string, precision, scale int) *Column {
 func (b *Blueprint) Float(name string) *Column {
 	column := Column{
 		name:       name,
-		columnType: "FLOAT",
+		columnType: "float",
 		nullable:   false,
 	}
 	
@@ -370,7 +496,7 @@ func (b *Blueprint) Float(name string) *Column {
 func (b *Blueprint) JSON(name string) *Column {
 	column := Column{
 		name:       name,
-		columnType: "JSON",
+		columnType: "json",
 		nullable:   false,
 	}
 	
@@ -378,6 +504,11 @@ func (b *Blueprint) JSON(name string) *Column {
 	return &b.columns[len(b.columns)-1]
 }
 
+// ForeignID adds an unsigned BIGINT column matching the type of ID
+func (b *Blueprint) ForeignID(name string) *Column {
+	return b.BigInteger(name, false).Unsigned()
+}
+
 // Timestamps adds created_at and updated_at columns
 func (b *Blueprint) Timestamps() {
 	b.DateTime("created_at").Nullable()
@@ -397,11 +528,7 @@ func (b *Blueprint) SoftDeletes() *Column {
 
 // Primary sets the primary key
 func (b *Blueprint) Primary(columns ...string) {
-	quoted := make([]string, len(columns))
-	for i, col := range columns {
-		quoted[i] = fmt.Sprintf("`%s`", col)
-	}
-	b.primary = strings.Join(quoted, ", ")
+	b.primary = columns
 }
 
 // Index adds an index
@@ -426,6 +553,18 @@ func (b *Blueprint) Unique(columns ...string) {
 	})
 }
 
+// Foreign adds a foreign key on columns; complete it with References
+func (b *Blueprint) Foreign(columns ...string) *ForeignKey {
+	foreignKey := &ForeignKey{columns: columns}
+	b.foreignKeys = append(b.foreignKeys, foreignKey)
+	return foreignKey
+}
+
+// DropColumn drops columns from an existing table
+func (b *Blueprint) DropColumn(columns ...string) {
+	b.drops = append(b.drops, columns...)
+}
+
 // Engine sets the storage engine
 func (b *Blueprint) Engine(engine string) *Blueprint {
 	b.engine = engine
@@ -458,6 +597,12 @@ func (c *Column) Nullable() *Column {
 	return c
 }
 
+// UseCurrent defaults a date column to the current timestamp
+func (c *Column) UseCurrent() *Column {
+	c.useCurrent = true
+	return c
+}
+
 // Default sets the default value
 func (c *Column) Default(value interface{}) *Column {
 	c.default_ = value
@@ -504,4 +649,23 @@ func (c *Column) First() *Column {
 func (c *Column) Primary() *Column {
 	c.unique = true
 	return c
-}
\ No newline at end of file
+}
+
+// Foreign key modifiers
+
+// References sets the referenced table and columns, which default to id
+func (f *ForeignKey) References(table string, columns ...string) *ForeignKey {
+	if len(columns) == 0 {
+		columns = []string{"id"}
+	}
+	f.on = table
+	f.references = columns
+	return f
+}
+
+// OnDelete sets the action taken when the referenced row is deleted, such
+// as "cascade" or "set null"
+func (f *ForeignKey) OnDelete(action string) *ForeignKey {
+	f.onDelete = action
+	return f
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sqliteObjects returns the names of the tables or indexes in database,
+// leaving out SQLite's own
+func sqliteObjects(t *testing.T, database *Database, kind string) []string {
+	t.Helper()
+	rows, err := database.Query(`SELECT name FROM sqlite_master WHERE type = ? AND name NOT LIKE 'sqlite_%'`, kind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func createPets(t *testing.T, schema *Schema) {
+	t.Helper()
+	err := schema.Create("owners", func(table *Blueprint) {
+		table.ID()
+		table.String("name", 100)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = schema.Create("pets", func(table *Blueprint) {
+		table.ID()
+		table.ForeignID("owner_id")
+		table.String("name", 100)
+		table.Boolean("vaccinated").Default(false)
+		table.Index("name")
+		table.Unique("owner_id", "name")
+		table.Foreign("owner_id").References("owners").OnDelete("cascade")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaCreate(t *testing.T) {
+	database := newTestDatabase(t)
+	schema := NewSchema(database)
+	createPets(t, schema)
+
+	if got := strings.Join(sqliteObjects(t, database, "table"), ","); got != "owners,pets" {
+		t.Errorf("tables = %s", got)
+	}
+	if got := strings.Join(sqliteObjects(t, database, "index"), ","); got != "idx_pets_name,unq_pets_owner_id_name" {
+		t.Errorf("indexes = %s", got)
+	}
+
+	var ddl string
+	if err := database.QueryRow(`SELECT sql FROM sqlite_master WHERE name = 'pets'`).Scan(&ddl); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"id" INTEGER PRIMARY KEY AUTOINCREMENT`,
+		`"vaccinated" BOOLEAN NOT NULL DEFAULT false`,
+		`FOREIGN KEY ("owner_id") REFERENCES "owners" ("id") ON DELETE CASCADE`,
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("pets DDL is missing %s:\n%s", want, ddl)
+		}
+	}
+
+	// Running it again with IF NOT EXISTS leaves the tables alone
+	err := schema.CreateIfNotExists("pets", func(table *Blueprint) {
+		table.ID()
+		table.Index("id")
+	})
+	if err != nil {
+		t.Errorf("CreateIfNotExists on an existing table: %v", err)
+	}
+}
+
+func TestSchemaConstraints(t *testing.T) {
+	database := newTestDatabase(t)
+	createPets(t, NewSchema(database))
+
+	if err := database.Exec(`INSERT INTO pets (owner_id, name) VALUES (1, 'Rex')`); err == nil {
+		t.Error("inserted a pet with a missing owner")
+	}
+	if err := database.Exec(`INSERT INTO owners (name) VALUES ('Ann')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Exec(`INSERT INTO pets (owner_id, name) VALUES (1, 'Rex')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Exec(`INSERT INTO pets (owner_id, name) VALUES (1, 'Rex')`); err == nil {
+		t.Error("inserted a duplicate of a unique index")
+	}
+
+	if err := database.Exec(`DELETE FROM owners`); err != nil {
+		t.Fatal(err)
+	}
+	var pets int
+	database.QueryRow(`SELECT COUNT(*) FROM pets`).Scan(&pets)
+	if pets != 0 {
+		t.Errorf("%d pets left after deleting their owner", pets)
+	}
+}
+
+func TestSchemaAlter(t *testing.T) {
+	database := newTestDatabase(t)
+	schema := NewSchema(database)
+	createPets(t, schema)
+
+	err := schema.Table("pets", func(table *Blueprint) {
+		table.String("species", 50).Nullable()
+		table.Index("species")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Exec(`INSERT INTO owners (name) VALUES ('Ann')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Exec(`INSERT INTO pets (owner_id, name, species) VALUES (1, 'Tom', 'cat')`); err != nil {
+		t.Errorf("insert into the added column: %v", err)
+	}
+
+	err = schema.Table("pets", func(table *Blueprint) {
+		table.Foreign("owner_id").References("owners")
+	})
+	if err == nil {
+		t.Error("SQLite accepted a foreign key on an existing table")
+	}
+
+	if err := schema.RenameColumn("pets", "species", "kind"); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Rename("pets", "animals"); err != nil {
+		t.Fatal(err)
+	}
+	var kind string
+	if err := database.QueryRow(`SELECT kind FROM animals`).Scan(&kind); err != nil || kind != "cat" {
+		t.Errorf("after renaming: kind = %q, %v", kind, err)
+	}
+
+	if err := schema.DropIfExists("animals"); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.DropIfExists("animals"); err != nil {
+		t.Errorf("dropping a missing table: %v", err)
+	}
+	if got := strings.Join(sqliteObjects(t, database, "table"), ","); got != "owners" {
+		t.Errorf("tables after drop = %s", got)
+	}
+}
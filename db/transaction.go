@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Executor runs SQL statements. Both *Database and *Transaction implement it,
+// so Query, QueryBuilder and Repository work the same inside a transaction.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) error
+	ExecResultContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	Dialect() Dialect
+}
+
+// ErrNestedTransaction is returned by Commit and Rollback on a savepoint; it
+// is released or rolled back when its callback returns
+var ErrNestedTransaction = errors.New("a nested transaction ends when its callback returns")
+
+// Transaction represents a database transaction
+type Transaction struct {
+	db    *Database
+	tx    *sql.Tx
+	depth int // 0 for the transaction itself, plus one per nested savepoint
+}
+
+// Begin starts a new transaction
+func (db *Database) Begin() (*Transaction, error) {
+	return db.BeginContext(context.Background())
+}
+
+// BeginContext starts a new transaction that is rolled back if ctx is done
+// before it is committed
+func (db *Database) BeginContext(ctx context.Context) (*Transaction, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{db: db, tx: tx}, nil
+}
+
+// Transaction runs fn in a transaction that is committed when fn returns nil
+// and rolled back when it returns an error or panics; the panic is raised
+// again after the rollback. A deadlock or serialization failure retries the
+// whole transaction up to TransactionRetries times, so fn must be safe to run
+// more than once.
+//
+// Every query in fn must go through tx. With a single connection, as with
+// SQLite's :memory:, a query on the Database would wait forever for the
+// connection held by the transaction.
+func (db *Database) Transaction(ctx context.Context, fn func(tx *Transaction) error) error {
+	for attempt := 1; ; attempt++ {
+		err := db.transaction(ctx, fn)
+		if err == nil || attempt > db.config.TransactionRetries || !db.Dialect().IsRetryable(err) {
+			return err
+		}
+
+		// Give the competing transaction a moment to finish
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+}
+
+// transaction makes a single attempt at running fn in a transaction
+func (db *Database) transaction(ctx context.Context, fn func(tx *Transaction) error) error {
+	tx, err := db.BeginContext(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.run(fn, tx.tx.Commit, tx.tx.Rollback)
+}
+
+// Transaction runs fn in a savepoint inside tx. The savepoint is released
+// when fn returns nil and rolled back, leaving the rest of tx intact, when it
+// returns an error or panics.
+func (tx *Transaction) Transaction(ctx context.Context, fn func(tx *Transaction) error) error {
+	savepoint := fmt.Sprintf("sp_%d", tx.depth+1)
+	if err := tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+
+	nested := &Transaction{db: tx.db, tx: tx.tx, depth: tx.depth + 1}
+	return nested.run(fn,
+		func() error { return tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint) },
+		func() error { return tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint) },
+	)
+}
+
+// run calls fn with tx, then commits or rolls back depending on the outcome
+func (tx *Transaction) run(fn func(tx *Transaction) error, commit, rollback func() error) error {
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return commit()
+}
+
+// Commit commits the transaction
+func (tx *Transaction) Commit() error {
+	if tx.depth > 0 {
+		return ErrNestedTransaction
+	}
+	return tx.tx.Commit()
+}
+
+// Rollback rolls back the transaction
+func (tx *Transaction) Rollback() error {
+	if tx.depth > 0 {
+		return ErrNestedTransaction
+	}
+	return tx.tx.Rollback()
+}
+
+// Dialect returns the SQL dialect of the transaction's database
+func (tx *Transaction) Dialect() Dialect {
+	return tx.db.Dialect()
+}
+
+// Exec executes a SQL query in the transaction and returns only error
+func (tx *Transaction) Exec(query string, args ...interface{}) error {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Exec, cancelled when ctx is done
+func (tx *Transaction) ExecContext(ctx context.Context, query string, args ...interface{}) error {
+	_, err := tx.ExecResultContext(ctx, query, args...)
+	return err
+}
+
+// ExecResultContext is ExecContext, returning the sql.Result
+func (tx *Transaction) ExecResultContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.tx.ExecContext(ctx, tx.Dialect().Rebind(query), args...)
+}
+
+// Query executes a SQL query in the transaction that returns rows
+func (tx *Transaction) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is Query, cancelled when ctx is done
+func (tx *Transaction) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.tx.QueryContext(ctx, tx.Dialect().Rebind(query), args...)
+}
+
+// QueryRow executes a SQL query in the transaction that returns at most one row
+func (tx *Transaction) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext is QueryRow, cancelled when ctx is done
+func (tx *Transaction) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.tx.QueryRowContext(ctx, tx.Dialect().Rebind(query), args...)
+}
+
+// Table creates a new query for the given model inside the transaction
+func (tx *Transaction) Table(model interface{}) *Query {
+	return newQuery(tx, model)
+}
+
+// Create inserts a new record inside the transaction
+func (tx *Transaction) Create(value interface{}) error {
+	return tx.CreateContext(context.Background(), value)
+}
+
+// CreateContext is Create, cancelled when ctx is done
+func (tx *Transaction) CreateContext(ctx context.Context, value interface{}) error {
+	return create(ctx, tx, value)
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// widgetNames returns the names of every widget, sorted
+func widgetNames(t *testing.T, e Executor) string {
+	t.Helper()
+	var widgets []widget
+	if err := newQuery(e, widget{}).Find(&widgets); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(widgets))
+	for i, w := range widgets {
+		names[i] = w.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestNestedTransactionRollsBackToSavepoint(t *testing.T) {
+	database := newTestDatabase(t)
+	createWidgets(t, database)
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	err := database.Transaction(ctx, func(tx *Transaction) error {
+		if err := tx.Create(&widget{Name: "outer"}); err != nil {
+			return err
+		}
+
+		err := tx.Transaction(ctx, func(tx *Transaction) error {
+			if err := tx.Create(&widget{Name: "middle"}); err != nil {
+				return err
+			}
+
+			err := tx.Transaction(ctx, func(tx *Transaction) error {
+				if err := tx.Create(&widget{Name: "inner"}); err != nil {
+					return err
+				}
+				if got := widgetNames(t, tx); got != "inner,middle,outer" {
+					t.Errorf("inside the innermost savepoint: %s", got)
+				}
+				return errFailed
+			})
+			if !errors.Is(err, errFailed) {
+				t.Errorf("inner savepoint returned %v", err)
+			}
+
+			if err := tx.Commit(); !errors.Is(err, ErrNestedTransaction) {
+				t.Errorf("Commit in a savepoint returned %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Transaction(ctx, func(tx *Transaction) error {
+			if err := tx.Create(&widget{Name: "discarded"}); err != nil {
+				return err
+			}
+			return errFailed
+		})
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("transaction returned %v", err)
+	}
+
+	// The last savepoint's error failed the whole transaction
+	if got := widgetNames(t, database); got != "" {
+		t.Errorf("after a failed transaction: %q", got)
+	}
+
+	err = database.Transaction(ctx, func(tx *Transaction) error {
+		if err := tx.Create(&widget{Name: "kept"}); err != nil {
+			return err
+		}
+		tx.Transaction(ctx, func(tx *Transaction) error {
+			tx.Create(&widget{Name: "dropped"})
+			return errFailed
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := widgetNames(t, database); got != "kept" {
+		t.Errorf("after a committed transaction: %q", got)
+	}
+}
+
+func TestNestedTransactionRollsBackOnPanic(t *testing.T) {
+	database := newTestDatabase(t)
+	createWidgets(t, database)
+	ctx := context.Background()
+
+	err := database.Transaction(ctx, func(tx *Transaction) error {
+		if err := tx.Create(&widget{Name: "kept"}); err != nil {
+			return err
+		}
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("the panic was swallowed")
+				}
+			}()
+			tx.Transaction(ctx, func(tx *Transaction) error {
+				tx.Create(&widget{Name: "dropped"})
+				panic("boom")
+			})
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := widgetNames(t, database); got != "kept" {
+		t.Errorf("after recovering from the panic: %q", got)
+	}
+}
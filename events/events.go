@@ -0,0 +1,83 @@
+// Package events provides a simple synchronous event dispatcher. Packages
+// publish events and the application registers listeners for them, so the
+// publisher does not need to know who consumes them.
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// Event is something that happened which listeners may react to
+type Event interface {
+	// EventName identifies the kind of event, such as "auth.lockout"
+	EventName() string
+}
+
+// Listener handles a dispatched event
+type Listener func(Event)
+
+// Dispatcher delivers events to the listeners registered for them
+type Dispatcher struct {
+	mu        sync.RWMutex
+	listeners map[string][]Listener
+}
+
+// NewDispatcher creates a dispatcher with no listeners
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{listeners: make(map[string][]Listener)}
+}
+
+// Listen registers a listener for events with the given name. The name "*"
+// receives every event.
+func (d *Dispatcher) Listen(name string, listener Listener) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners[name] = append(d.listeners[name], listener)
+}
+
+// Dispatch calls every listener for the event in registration order. A
+// panicking listener is logged and does not stop the others.
+func (d *Dispatcher) Dispatch(event Event) {
+	d.mu.RLock()
+	listeners := append([]Listener{}, d.listeners[event.EventName()]...)
+	listeners = append(listeners, d.listeners["*"]...)
+	d.mu.RUnlock()
+
+	for _, listener := range listeners {
+		call(listener, event)
+	}
+}
+
+// HasListeners reports whether any listener would receive events with the given name
+func (d *Dispatcher) HasListeners(name string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.listeners[name]) > 0 || len(d.listeners["*"]) > 0
+}
+
+func call(listener Listener, event Event) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("Event listener for %s panicked: %v", event.EventName(), err)
+		}
+	}()
+	listener(event)
+}
+
+var defaultDispatcher = NewDispatcher()
+
+// Default returns the application-wide dispatcher
+func Default() *Dispatcher {
+	return defaultDispatcher
+}
+
+// Listen registers a listener on the default dispatcher
+func Listen(name string, listener Listener) {
+	defaultDispatcher.Listen(name, listener)
+}
+
+// Dispatch sends an event through the default dispatcher
+func Dispatch(event Event) {
+	defaultDispatcher.Dispatch(event)
+}
@@ -0,0 +1,102 @@
+package hashing
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Argon2idHasher hashes passwords with argon2id (RFC 9106). Hashes use the
+// PHC string format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// NewArgon2idHasher creates an argon2id hasher. Zero values default to 64 MiB
+// of memory, 3 iterations and a parallelism of 2.
+func NewArgon2idHasher(memory, iterations uint32, parallelism uint8) *Argon2idHasher {
+	if memory == 0 {
+		memory = 64 * 1024
+	}
+	if iterations == 0 {
+		iterations = 3
+	}
+	if parallelism == 0 {
+		parallelism = 2
+	}
+	return &Argon2idHasher{memory: memory, iterations: iterations, parallelism: parallelism}
+}
+
+// Make hashes password with a random salt
+func (h *Argon2idHasher) Make(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, argon2KeyLength)
+
+	b64 := base64.RawStdEncoding.EncodeToString
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.iterations, h.parallelism, b64(salt), b64(key)), nil
+}
+
+// Check reports whether password matches hash
+func (h *Argon2idHasher) Check(password, hash string) bool {
+	return check(password, hash)
+}
+
+// NeedsRehash reports whether hash is not argon2id with the configured parameters
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.memory != h.memory || params.iterations != h.iterations ||
+		params.parallelism != h.parallelism || len(key) != argon2KeyLength
+}
+
+// decodeArgon2id parses a PHC formatted argon2id hash
+func decodeArgon2id(hash string) (params Argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 parameters")
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, err
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, err
+	}
+	return params, salt, key, nil
+}
+
+func checkArgon2id(password, hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
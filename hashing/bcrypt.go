@@ -0,0 +1,59 @@
+package hashing
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultBcryptCost = bcrypt.DefaultCost
+
+// BcryptHasher hashes passwords with bcrypt. bcrypt only uses the first 72
+// bytes of a password, so longer passwords are rejected by Make.
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a bcrypt hasher with the given cost, or 10 if zero
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost == 0 {
+		cost = defaultBcryptCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &BcryptHasher{cost: cost}, nil
+}
+
+// Make hashes password
+func (h *BcryptHasher) Make(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Check reports whether password matches hash
+func (h *BcryptHasher) Check(password, hash string) bool {
+	return check(password, hash)
+}
+
+// NeedsRehash reports whether hash is not bcrypt at the configured cost
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+// isBcrypt reports whether hash is in bcrypt's modular crypt format
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func checkBcrypt(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,77 @@
+// Package hashing hashes and verifies passwords with bcrypt or argon2id.
+// Hashes are self-describing, so every hasher can verify hashes made by the
+// other driver or with other parameters, and NeedsRehash reports when a hash
+// should be upgraded to the current settings.
+package hashing
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Hasher hashes and verifies passwords
+type Hasher interface {
+	// Make returns a hash of password using the hasher's current settings
+	Make(password string) (string, error)
+
+	// Check reports whether password matches hash, whichever driver made it
+	Check(password, hash string) bool
+
+	// NeedsRehash reports whether hash was made with another driver or with
+	// different parameters than Make would use now
+	NeedsRehash(hash string) bool
+}
+
+// Config represents the hashing configuration
+type Config struct {
+	Driver string // "bcrypt" or "argon2id"
+
+	BcryptCost int // Defaults to 10
+
+	Argon2Memory      uint32 // KiB; defaults to 64 MiB
+	Argon2Iterations  uint32 // Defaults to 3
+	Argon2Parallelism uint8  // Defaults to 2
+}
+
+// New creates a hasher for the configured driver
+func New(cfg Config) (Hasher, error) {
+	switch cfg.Driver {
+	case "", "bcrypt":
+		return NewBcryptHasher(cfg.BcryptCost)
+	case "argon2id":
+		return NewArgon2idHasher(cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism), nil
+	default:
+		return nil, fmt.Errorf("unsupported hashing driver: %s", cfg.Driver)
+	}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultHasher Hasher = &BcryptHasher{cost: defaultBcryptCost}
+)
+
+// Default returns the application-wide hasher
+func Default() Hasher {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultHasher
+}
+
+// SetDefault replaces the application-wide hasher
+func SetDefault(h Hasher) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultHasher = h
+}
+
+// check verifies password against a hash from any supported driver
+func check(password, hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return checkArgon2id(password, hash)
+	case isBcrypt(hash):
+		return checkBcrypt(password, hash)
+	}
+	return false
+}
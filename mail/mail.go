@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message represents an outgoing email
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config represents the mailer configuration
+type Config struct {
+	Driver string // "log" or "file"
+	From   string
+	Path   string // Output directory for the file driver
+}
+
+// New creates a mailer for the configured driver
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return NewLogMailer(cfg.From), nil
+	case "file":
+		return NewFileMailer(cfg.From, cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported mail driver: %s", cfg.Driver)
+	}
+}
+
+// LogMailer writes messages to the standard logger. Intended for development.
+type LogMailer struct {
+	from string
+}
+
+// NewLogMailer creates a mailer that logs every message
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+// Send logs the message
+func (m *LogMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+	log.Printf("Mail to %s from %s: %s\n%s", msg.To, msg.From, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message to its own .eml file. Intended for development.
+type FileMailer struct {
+	from string
+	dir  string
+	mu   sync.Mutex
+	seq  int
+}
+
+// NewFileMailer creates a mailer that writes messages into dir
+func NewFileMailer(from, dir string) (*FileMailer, error) {
+	if dir == "" {
+		dir = filepath.Join("storage", "mail")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileMailer{from: from, dir: dir}, nil
+}
+
+// Send writes the message to disk
+func (m *FileMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%03d.eml", time.Now().Format("20060102150405"), m.seq)
+	m.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", msg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(b.String()), 0644)
+}
@@ -20,16 +20,7 @@ func main() {
 	}
 
 	// Initialize database
-	dbConfig := db.DatabaseConfig{
-		Driver:   cfg.Database.Driver,
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Name:     cfg.Database.Name,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-	}
-
-	database, err := db.NewDatabase(&dbConfig)
+	database, err := db.NewDatabase(db.ConfigFrom(cfg))
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -46,8 +37,9 @@ func main() {
 	// Set up authentication
 
 	authConfig := auth.AuthConfig{
-		Secret:   cfg.Auth.Secret,
-		Duration:     cfg.Auth.Duration,
+		Secret:          cfg.Auth.Secret,
+		Duration:        cfg.Auth.Duration,
+		RefreshDuration: cfg.Auth.RefreshDuration,
 	}
 	authProvider := auth.NewProvider(database, authConfig)
 
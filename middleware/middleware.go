@@ -6,6 +6,8 @@ import (
 	"runtime/debug"
 	"sync"
 	"time"
+
+	"github.com/example/goframe/auth"
 )
 
 // Logger is a middleware that logs request details
@@ -39,6 +41,15 @@ func Recover() func(http.Handler) http.Handler {
 	}
 }
 
+// Timeout is a middleware that cancels the request's context once timeout has
+// passed, which also cancels any database query run with that context. If the
+// handler has not finished by then the client gets 503 Service Unavailable.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, timeout, "Request timed out")
+	}
+}
+
 // RateLimit is a middleware that limits the number of requests in a period
 func RateLimit(requests int, period time.Duration) func(http.Handler) http.Handler {
 	type client struct {
@@ -103,3 +114,147 @@ func RateLimit(requests int, period time.Duration) func(http.Handler) http.Handl
 	}
 }
 
+// Verified is a middleware that rejects users who have not confirmed their email.
+// It must run after an authentication middleware.
+func Verified() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.GetUser(r.Context())
+			if user == nil || !user.HasVerifiedEmail() {
+				http.Error(w, "Your email address is not verified", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TokenCan is a middleware that rejects requests whose credentials do not
+// grant every one of the given abilities. Sessions and JWTs grant everything,
+// so in practice it limits personal access tokens to their scopes. It must
+// run after an authentication middleware.
+func TokenCan(abilities ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if auth.GetUser(r.Context()) == nil {
+				http.Error(w, "Not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			if !tokenCan(r, abilities...) {
+				http.Error(w, "Token does not grant this ability", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Can is a middleware that rejects users who may not perform ability on the
+// default gate, or whose token is not scoped to it. It must run after an
+// authentication middleware.
+func Can(ability string, args ...interface{}) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.GetUser(r.Context())
+			if user == nil {
+				http.Error(w, "Not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			if !loadAccess(w, r, user) {
+				return
+			}
+
+			if auth.Denies(user, ability, args...) || !tokenCan(r, ability) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Role is a middleware that only admits users holding at least one of the given
+// roles. Roles are not token abilities, so pair it with TokenCan to keep
+// scoped personal access tokens out. It must run after an authentication
+// middleware.
+func Role(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.GetUser(r.Context())
+			if user == nil {
+				http.Error(w, "Not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			if !loadAccess(w, r, user) {
+				return
+			}
+
+			if !user.HasAnyRole(roles...) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Permission is a middleware that only admits users granted every one of the
+// given permissions, through credentials scoped to them. It must run after an
+// authentication middleware.
+func Permission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.GetUser(r.Context())
+			if user == nil {
+				http.Error(w, "Not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			if !loadAccess(w, r, user) {
+				return
+			}
+
+			for _, permission := range permissions {
+				if !user.HasPermission(permission) {
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					return
+				}
+			}
+			if !tokenCan(r, permissions...) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// loadAccess loads the user's roles and permissions with the request's
+// context. When the lookup fails it responds 500 and returns false, so the
+// failure is not mistaken for a denial.
+func loadAccess(w http.ResponseWriter, r *http.Request, user *auth.UserModel) bool {
+	if err := user.LoadAccess(r.Context()); err != nil {
+		log.Printf("Failed to check access: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// tokenCan reports whether the request's credentials grant every ability
+func tokenCan(r *http.Request, abilities ...string) bool {
+	for _, ability := range abilities {
+		if !auth.TokenCan(r.Context(), ability) {
+			return false
+		}
+	}
+	return true
+}
@@ -4,32 +4,32 @@ import (
 	"github.com/example/goframe/db"
 )
 
+func init() {
+	db.RegisterMigration("20230615120000_create_users_table", &Migration_20230615120000{})
+}
+
 // Migration_20230615120000 represents the create_users_table migration
 type Migration_20230615120000 struct{}
 
 // Up runs the migration
 func (m *Migration_20230615120000) Up(migrator *db.Migrator) error {
-	// Create table
-	sql := `
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		email VARCHAR(255) NOT NULL UNIQUE,
-		password VARCHAR(255) NOT NULL,
-		remember_token VARCHAR(100) NULL,
-		email_verified_at TIMESTAMP NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	)
-	`
+	schema := db.NewSchema(migrator.DB())
 
-	return migrator.DB().Exec(sql)
+	return schema.Create("users", func(table *db.Blueprint) {
+		table.ID()
+		table.String("name", 255)
+		table.String("email", 255).Unique()
+		table.String("password", 255)
+		table.String("remember_token", 100).Nullable()
+		table.DateTime("email_verified_at").Nullable()
+		table.DateTime("created_at").UseCurrent()
+		table.DateTime("updated_at").UseCurrent()
+	})
 }
 
 // Down rolls back the migration
 func (m *Migration_20230615120000) Down(migrator *db.Migrator) error {
-	// Drop table
-	sql := "DROP TABLE IF EXISTS users"
-	return migrator.DB().Exec(sql)
-}
+	schema := db.NewSchema(migrator.DB())
 
+	return schema.DropIfExists("users")
+}
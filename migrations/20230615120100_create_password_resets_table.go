@@ -4,28 +4,28 @@ import (
 	"github.com/example/goframe/db"
 )
 
+func init() {
+	db.RegisterMigration("20230615120100_create_password_resets_table", &Migration_20230615120100{})
+}
+
 // Migration_20230615120100 represents the create_password_resets_table migration
 type Migration_20230615120100 struct{}
 
 // Up runs the migration
 func (m *Migration_20230615120100) Up(migrator *db.Migrator) error {
-	// Create table
-	sql := `
-	CREATE TABLE IF NOT EXISTS password_resets (
-		email VARCHAR(255) NOT NULL,
-		token VARCHAR(255) NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		PRIMARY KEY (email, token)
-	)
-	`
+	schema := db.NewSchema(migrator.DB())
 
-	return migrator.DB().Exec(sql)
+	return schema.Create("password_resets", func(table *db.Blueprint) {
+		table.String("email", 255)
+		table.String("token", 255)
+		table.DateTime("created_at").UseCurrent()
+		table.Primary("email", "token")
+	})
 }
 
 // Down rolls back the migration
 func (m *Migration_20230615120100) Down(migrator *db.Migrator) error {
-	// Drop table
-	sql := "DROP TABLE IF EXISTS password_resets"
-	return migrator.DB().Exec(sql)
-}
+	schema := db.NewSchema(migrator.DB())
 
+	return schema.DropIfExists("password_resets")
+}
@@ -4,57 +4,38 @@ import (
 	"github.com/example/goframe/db"
 )
 
+func init() {
+	db.RegisterMigration("20230615120200_create_posts_table", &Migration_20230615120200{})
+}
+
 // Migration_20230615120200 represents the create_posts_table migration
 type Migration_20230615120200 struct{}
 
 // Up runs the migration
 func (m *Migration_20230615120200) Up(migrator *db.Migrator) error {
-	// Create table
-	sql := `
-	CREATE TABLE IF NOT EXISTS posts (
-		id SERIAL PRIMARY KEY,
-		title VARCHAR(255) NOT NULL,
-		slug VARCHAR(255) NOT NULL UNIQUE,
-		content TEXT NOT NULL,
-		excerpt TEXT NULL,
-		user_id INTEGER NOT NULL,
-		published BOOLEAN NOT NULL DEFAULT FALSE,
-		published_at TIMESTAMP NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	)
-	`
-
-	// Create indexes
-	indexSql := `
-	CREATE INDEX idx_posts_user_id ON posts(user_id);
-	CREATE INDEX idx_posts_slug ON posts(slug);
-	CREATE INDEX idx_posts_published ON posts(published);
-	`
-
-	if err := migrator.DB().Exec(sql); err != nil {
-		return err
-	}
-
-	return migrator.DB().Exec(indexSql)
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Create("posts", func(table *db.Blueprint) {
+		table.ID()
+		table.String("title", 255)
+		table.String("slug", 255).Unique()
+		table.Text("content")
+		table.Text("excerpt").Nullable()
+		table.ForeignID("user_id")
+		table.Boolean("published").Default(false)
+		table.DateTime("published_at").Nullable()
+		table.DateTime("created_at").UseCurrent()
+		table.DateTime("updated_at").UseCurrent()
+		table.Foreign("user_id").References("users").OnDelete("cascade")
+		table.Index("user_id")
+		table.Index("slug")
+		table.Index("published")
+	})
 }
 
 // Down rolls back the migration
 func (m *Migration_20230615120200) Down(migrator *db.Migrator) error {
-	// Drop indexes
-	indexSql := `
-	DROP INDEX IF EXISTS idx_posts_user_id;
-	DROP INDEX IF EXISTS idx_posts_slug;
-	DROP INDEX IF EXISTS idx_posts_published;
-	`
-
-	if err := migrator.DB().Exec(indexSql); err != nil {
-		return err
-	}
+	schema := db.NewSchema(migrator.DB())
 
-	// Drop table
-	sql := "DROP TABLE IF EXISTS posts"
-	return migrator.DB().Exec(sql)
+	return schema.DropIfExists("posts")
 }
-
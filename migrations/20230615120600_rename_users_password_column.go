@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20230615120600_rename_users_password_column", &Migration_20230615120600{})
+}
+
+// Migration_20230615120600 represents the rename_users_password_column migration
+type Migration_20230615120600 struct{}
+
+// Up runs the migration
+func (m *Migration_20230615120600) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.RenameColumn("users", "password", "password_hash")
+}
+
+// Down rolls back the migration
+func (m *Migration_20230615120600) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.RenameColumn("users", "password_hash", "password")
+}
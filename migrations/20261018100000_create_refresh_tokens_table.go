@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20261018100000_create_refresh_tokens_table", &Migration_20261018100000{})
+}
+
+// Migration_20261018100000 represents the create_refresh_tokens_table migration
+type Migration_20261018100000 struct{}
+
+// Up runs the migration
+func (m *Migration_20261018100000) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Create("refresh_tokens", func(table *db.Blueprint) {
+		table.ID()
+		table.ForeignID("user_id")
+		table.String("family_id", 64)
+		table.String("token_hash", 64).Unique()
+		table.DateTime("expires_at")
+		table.DateTime("revoked_at").Nullable()
+		table.DateTime("created_at").UseCurrent()
+		table.DateTime("updated_at").UseCurrent()
+		table.Foreign("user_id").References("users").OnDelete("cascade")
+		table.Index("user_id")
+		table.Index("family_id")
+	})
+}
+
+// Down rolls back the migration
+func (m *Migration_20261018100000) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.DropIfExists("refresh_tokens")
+}
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20261018100100_create_roles_and_permissions_tables", &Migration_20261018100100{})
+}
+
+// Migration_20261018100100 represents the create_roles_and_permissions_tables migration
+type Migration_20261018100100 struct{}
+
+// Up runs the migration
+func (m *Migration_20261018100100) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	if err := schema.Create("roles", func(table *db.Blueprint) {
+		table.ID()
+		table.String("name", 255).Unique()
+		table.String("label", 255).Nullable()
+		table.Timestamps()
+	}); err != nil {
+		return err
+	}
+
+	if err := schema.Create("permissions", func(table *db.Blueprint) {
+		table.ID()
+		table.String("name", 255).Unique()
+		table.String("label", 255).Nullable()
+		table.Timestamps()
+	}); err != nil {
+		return err
+	}
+
+	if err := schema.Create("role_user", func(table *db.Blueprint) {
+		table.BigInteger("user_id", false)
+		table.BigInteger("role_id", false)
+		table.Primary("user_id", "role_id")
+		table.Index("role_id")
+	}); err != nil {
+		return err
+	}
+
+	return schema.Create("permission_role", func(table *db.Blueprint) {
+		table.BigInteger("permission_id", false)
+		table.BigInteger("role_id", false)
+		table.Primary("permission_id", "role_id")
+		table.Index("role_id")
+	})
+}
+
+// Down rolls back the migration
+func (m *Migration_20261018100100) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	for _, table := range []string{"permission_role", "role_user", "permissions", "roles"} {
+		if err := schema.DropIfExists(table); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20261018100200_create_personal_access_tokens_table", &Migration_20261018100200{})
+}
+
+// Migration_20261018100200 represents the create_personal_access_tokens_table migration
+type Migration_20261018100200 struct{}
+
+// Up runs the migration
+func (m *Migration_20261018100200) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Create("personal_access_tokens", func(table *db.Blueprint) {
+		table.ID()
+		table.ForeignID("user_id")
+		table.String("name", 255)
+		table.String("token_hash", 64).Unique()
+		table.Text("abilities").Nullable()
+		table.DateTime("last_used_at").Nullable()
+		table.DateTime("expires_at").Nullable()
+		table.DateTime("created_at").UseCurrent()
+		table.DateTime("updated_at").UseCurrent()
+		table.Foreign("user_id").References("users").OnDelete("cascade")
+		table.Index("user_id")
+	})
+}
+
+// Down rolls back the migration
+func (m *Migration_20261018100200) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.DropIfExists("personal_access_tokens")
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20261018100300_create_sessions_table", &Migration_20261018100300{})
+}
+
+// Migration_20261018100300 represents the create_sessions_table migration
+type Migration_20261018100300 struct{}
+
+// Up runs the migration
+func (m *Migration_20261018100300) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Create("sessions", func(table *db.Blueprint) {
+		table.String("id", 64)
+		table.Text("payload")
+		table.DateTime("expires_at")
+		table.Primary("id")
+		table.Index("expires_at")
+	})
+}
+
+// Down rolls back the migration
+func (m *Migration_20261018100300) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.DropIfExists("sessions")
+}
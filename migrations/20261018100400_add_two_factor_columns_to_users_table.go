@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20261018100400_add_two_factor_columns_to_users_table", &Migration_20261018100400{})
+}
+
+// Migration_20261018100400 represents the add_two_factor_columns_to_users_table migration
+type Migration_20261018100400 struct{}
+
+// Up runs the migration
+func (m *Migration_20261018100400) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Table("users", func(table *db.Blueprint) {
+		table.String("two_factor_secret", 64).Nullable()
+		table.Text("two_factor_recovery_codes").Nullable()
+		table.DateTime("two_factor_confirmed_at").Nullable()
+		table.BigInteger("two_factor_last_step", false).Default(0)
+	})
+}
+
+// Down rolls back the migration
+func (m *Migration_20261018100400) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Table("users", func(table *db.Blueprint) {
+		table.DropColumn("two_factor_secret", "two_factor_recovery_codes", "two_factor_confirmed_at", "two_factor_last_step")
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20261018100500_create_social_accounts_table", &Migration_20261018100500{})
+}
+
+// Migration_20261018100500 represents the create_social_accounts_table migration
+type Migration_20261018100500 struct{}
+
+// Up runs the migration
+func (m *Migration_20261018100500) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Create("social_accounts", func(table *db.Blueprint) {
+		table.ID()
+		table.ForeignID("user_id")
+		table.String("provider", 50)
+		table.String("provider_user_id", 255)
+		table.String("email", 255).Nullable()
+		table.String("name", 255).Nullable()
+		table.Text("avatar_url").Nullable()
+		table.DateTime("created_at").UseCurrent()
+		table.DateTime("updated_at").UseCurrent()
+		table.Unique("provider", "provider_user_id")
+		table.Foreign("user_id").References("users").OnDelete("cascade")
+		table.Index("user_id")
+	})
+}
+
+// Down rolls back the migration
+func (m *Migration_20261018100500) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.DropIfExists("social_accounts")
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20261018100600_create_magic_links_table", &Migration_20261018100600{})
+}
+
+// Migration_20261018100600 represents the create_magic_links_table migration
+type Migration_20261018100600 struct{}
+
+// Up runs the migration
+func (m *Migration_20261018100600) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Create("magic_links", func(table *db.Blueprint) {
+		table.ID()
+		table.ForeignID("user_id")
+		table.String("token_hash", 64).Unique()
+		table.String("browser_hash", 64)
+		table.String("redirect", 255)
+		table.DateTime("expires_at")
+		table.DateTime("created_at").UseCurrent()
+		table.Foreign("user_id").References("users").OnDelete("cascade")
+		table.Index("user_id")
+	})
+}
+
+// Down rolls back the migration
+func (m *Migration_20261018100600) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.DropIfExists("magic_links")
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/example/goframe/db"
+)
+
+func init() {
+	db.RegisterMigration("20261018100700_create_auth_events_table", &Migration_20261018100700{})
+}
+
+// Migration_20261018100700 represents the create_auth_events_table migration
+type Migration_20261018100700 struct{}
+
+// Up runs the migration
+func (m *Migration_20261018100700) Up(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.Create("auth_events", func(table *db.Blueprint) {
+		table.ID()
+		table.String("type", 50)
+		table.ForeignID("user_id").Nullable()
+		table.ForeignID("impersonator_id").Nullable()
+		table.String("email", 255).Default("")
+		table.String("ip", 45).Default("")
+		table.Text("user_agent")
+		table.Text("details")
+		table.DateTime("created_at").UseCurrent()
+		table.Index("user_id")
+		table.Index("type")
+		table.Index("created_at")
+	})
+}
+
+// Down rolls back the migration
+func (m *Migration_20261018100700) Down(migrator *db.Migrator) error {
+	schema := db.NewSchema(migrator.DB())
+
+	return schema.DropIfExists("auth_events")
+}
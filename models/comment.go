@@ -0,0 +1,77 @@
+package models
+
+import (
+	"github.com/example/goframe/auth"
+	"github.com/example/goframe/db"
+)
+
+// Comment represents the comment model. Replies point at the comment they
+// answer through ParentID.
+type Comment struct {
+	db.Entity
+	Content  string `db:"content" json:"content"`
+	UserID   uint   `db:"user_id" json:"user_id"`
+	PostID   uint   `db:"post_id" json:"post_id"`
+	ParentID *uint  `db:"parent_id" json:"parent_id"`
+
+	User    *auth.UserModel `rel:"belongsTo" json:"user,omitempty"`
+	Post    *Post           `rel:"belongsTo" json:"post,omitempty"`
+	Parent  *Comment        `rel:"belongsTo" json:"parent,omitempty"`
+	Replies []Comment       `rel:"hasMany,foreignKey=parent_id" json:"replies,omitempty"`
+}
+
+// TableName returns the table name for the model
+func (Comment) TableName() string {
+	return "comments"
+}
+
+// CommentRepository provides methods to interact with comments
+type CommentRepository struct {
+	repo *db.Repository[Comment]
+}
+
+// NewCommentRepository creates a new comment repository
+func NewCommentRepository(database *db.Database) *CommentRepository {
+	return &CommentRepository{
+		repo: db.NewRepository[Comment](database),
+	}
+}
+
+// Create creates a new comment
+func (r *CommentRepository) Create(model *Comment) error {
+	return r.repo.Create(model)
+}
+
+// FindByID finds a comment by ID
+func (r *CommentRepository) FindByID(id uint) (*Comment, error) {
+	var model Comment
+	if err := r.repo.FindByID(id, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// FindAll finds all comments
+func (r *CommentRepository) FindAll() ([]Comment, error) {
+	var models []Comment
+	if err := r.repo.FindAll(&models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Load loads the named relations, such as "User", "Post" or "Replies", of a
+// *Comment or a *[]Comment
+func (r *CommentRepository) Load(dest interface{}, relations ...string) error {
+	return r.repo.Load(dest, relations...)
+}
+
+// Update updates a comment
+func (r *CommentRepository) Update(model *Comment) error {
+	return r.repo.Update(model)
+}
+
+// Delete deletes a comment
+func (r *CommentRepository) Delete(model *Comment) error {
+	return r.repo.Delete(model)
+}
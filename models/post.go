@@ -0,0 +1,113 @@
+package models
+
+import (
+	"time"
+
+	"github.com/example/goframe/auth"
+	"github.com/example/goframe/db"
+)
+
+// Post represents the post model
+type Post struct {
+	db.Entity
+	Title       string     `db:"title" json:"title"`
+	Slug        string     `db:"slug" json:"slug"`
+	Content     string     `db:"content" json:"content"`
+	Excerpt     *string    `db:"excerpt" json:"excerpt"`
+	UserID      uint       `db:"user_id" json:"user_id"`
+	Published   bool       `db:"published" json:"published"`
+	PublishedAt *time.Time `db:"published_at" json:"published_at"`
+
+	Author   *auth.UserModel `rel:"belongsTo,foreignKey=user_id" json:"author,omitempty"`
+	Comments []Comment       `rel:"hasMany" json:"comments,omitempty"`
+	Tags     []Tag           `rel:"manyToMany,pivot=post_tag" json:"tags,omitempty"`
+
+	CommentsCount int `json:"comments_count"` // Filled by WithCount("Comments")
+}
+
+// TableName returns the table name for the model
+func (Post) TableName() string {
+	return "posts"
+}
+
+// PostRepository provides methods to interact with posts
+type PostRepository struct {
+	repo *db.Repository[Post]
+}
+
+// NewPostRepository creates a new post repository
+func NewPostRepository(database *db.Database) *PostRepository {
+	return &PostRepository{
+		repo: db.NewRepository[Post](database),
+	}
+}
+
+// Create creates a new post
+func (r *PostRepository) Create(model *Post) error {
+	return r.repo.Create(model)
+}
+
+// FindByID finds a post by ID
+func (r *PostRepository) FindByID(id uint) (*Post, error) {
+	var model Post
+	if err := r.repo.FindByID(id, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// FindAll finds all posts
+func (r *PostRepository) FindAll() ([]Post, error) {
+	var models []Post
+	if err := r.repo.FindAll(&models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Query starts a query for posts, for filtering and eager loading:
+//
+//	repo.Query().With("Author", "Tags", "Comments.User").Find(&posts)
+func (r *PostRepository) Query() *db.Query {
+	return r.repo.Query()
+}
+
+// Update updates a post
+func (r *PostRepository) Update(model *Post) error {
+	return r.repo.Update(model)
+}
+
+// Delete deletes a post
+func (r *PostRepository) Delete(model *Post) error {
+	return r.repo.Delete(model)
+}
+
+// Load loads the named relations, such as "Author", "Comments" or "Tags",
+// of a *Post or a *[]Post
+func (r *PostRepository) Load(dest interface{}, relations ...string) error {
+	return r.repo.Load(dest, relations...)
+}
+
+// AttachTags adds tags to a post, keeping the tags it already has
+func (r *PostRepository) AttachTags(model *Post, tagIDs ...uint) error {
+	return r.repo.Attach(model, "Tags", ids(tagIDs)...)
+}
+
+// DetachTags removes tags from a post, or all of its tags when none are given
+func (r *PostRepository) DetachTags(model *Post, tagIDs ...uint) error {
+	return r.repo.Detach(model, "Tags", ids(tagIDs)...)
+}
+
+// SyncTags replaces a post's tags with the given ones
+func (r *PostRepository) SyncTags(model *Post, tagIDs ...uint) error {
+	return r.repo.Sync(model, "Tags", ids(tagIDs)...)
+}
+
+// ids converts model IDs for the pivot helpers
+func ids(values []uint) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
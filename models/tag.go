@@ -0,0 +1,78 @@
+package models
+
+import (
+	"github.com/example/goframe/db"
+)
+
+// Tag represents the tag model
+type Tag struct {
+	db.Entity
+	Name string `db:"name" json:"name"`
+	Slug string `db:"slug" json:"slug"`
+
+	Posts []Post `rel:"manyToMany,pivot=post_tag" json:"posts,omitempty"`
+}
+
+// TableName returns the table name for the model
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// TagRepository provides methods to interact with tags
+type TagRepository struct {
+	repo *db.Repository[Tag]
+}
+
+// NewTagRepository creates a new tag repository
+func NewTagRepository(database *db.Database) *TagRepository {
+	return &TagRepository{
+		repo: db.NewRepository[Tag](database),
+	}
+}
+
+// Create creates a new tag
+func (r *TagRepository) Create(model *Tag) error {
+	return r.repo.Create(model)
+}
+
+// FindByID finds a tag by ID
+func (r *TagRepository) FindByID(id uint) (*Tag, error) {
+	var model Tag
+	if err := r.repo.FindByID(id, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// FindBySlug finds a tag by its slug
+func (r *TagRepository) FindBySlug(slug string) (*Tag, error) {
+	var model Tag
+	if err := r.repo.FindByString("slug", &model, slug); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// FindAll finds all tags
+func (r *TagRepository) FindAll() ([]Tag, error) {
+	var models []Tag
+	if err := r.repo.FindAll(&models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Load loads the named relations, such as "Posts", of a *Tag or a *[]Tag
+func (r *TagRepository) Load(dest interface{}, relations ...string) error {
+	return r.repo.Load(dest, relations...)
+}
+
+// Update updates a tag
+func (r *TagRepository) Update(model *Tag) error {
+	return r.repo.Update(model)
+}
+
+// Delete deletes a tag
+func (r *TagRepository) Delete(model *Tag) error {
+	return r.repo.Delete(model)
+}
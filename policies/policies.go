@@ -0,0 +1,24 @@
+package policies
+
+import (
+	"github.com/example/goframe/auth"
+	"github.com/example/goframe/models"
+)
+
+// Register registers the application's policies and abilities on gate
+func Register(gate *auth.Gate) {
+	// Admins may do anything, and a stored permission grants the ability of
+	// the same name (e.g. "posts.publish")
+	gate.Before(func(user *auth.UserModel, ability string) (bool, bool) {
+		if user.HasRole("admin") || user.HasPermission(ability) {
+			return true, true
+		}
+		return false, false
+	})
+
+	postPolicy := PostPolicy{}
+
+	gate.Policy(models.Post{}, postPolicy)
+	gate.Define("update-post", postPolicy.Update)
+	gate.Define("delete-post", postPolicy.Delete)
+}
@@ -0,0 +1,28 @@
+package policies
+
+import (
+	"github.com/example/goframe/auth"
+	"github.com/example/goframe/models"
+)
+
+// PostPolicy decides what a user may do with a post: its author and
+// administrators may change it, and nobody else
+type PostPolicy struct{}
+
+// Update allows the author or an administrator to edit a post
+func (PostPolicy) Update(user *auth.UserModel, post *models.Post) bool {
+	return authorOrAdmin(user, post)
+}
+
+// Delete allows the author or an administrator to delete a post
+func (PostPolicy) Delete(user *auth.UserModel, post *models.Post) bool {
+	return authorOrAdmin(user, post)
+}
+
+// authorOrAdmin reports whether user wrote post or is an administrator
+func authorOrAdmin(user *auth.UserModel, post *models.Post) bool {
+	if post == nil {
+		return false
+	}
+	return user.ID == post.UserID || user.HasRole("admin")
+}
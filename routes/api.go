@@ -6,11 +6,12 @@ import (
 
 	"github.com/example/goframe/auth"
 	"github.com/example/goframe/config"
+	"github.com/example/goframe/middleware"
 	"github.com/example/goframe/router"
 )
 
 // RegisterAPIRoutes registers API routes
-func RegisterAPIRoutes(r *router.Router, cfg *config.Config, authProvider *auth.Provider) {
+func RegisterAPIRoutes(r *router.Router, cfg *config.Config, authProvider *auth.Provider, authController *auth.Controller) {
 	// Create API group
 	api := r.Group("/api")
 
@@ -20,6 +21,24 @@ func RegisterAPIRoutes(r *router.Router, cfg *config.Config, authProvider *auth.
 	// Register routes
 	api.Get("/user", getUserHandler)
 
+	// Personal access tokens
+	api.Get("/tokens", authController.Tokens)
+	api.Post("/tokens", authController.CreateToken)
+	api.Delete("/tokens", authController.RevokeToken)
+
+	// Support tools
+	audit := r.Group("/api/admin")
+	audit.Use(authProvider.Middleware())
+	audit.Use(middleware.TokenCan(auth.ViewAuthEventsPermission))
+	audit.Use(middleware.Permission(auth.ViewAuthEventsPermission))
+	audit.Get("/auth-events", authController.AuthEvents)
+
+	impersonation := r.Group("/api/admin")
+	impersonation.Use(authProvider.Middleware())
+	impersonation.Use(middleware.TokenCan(auth.ImpersonatePermission))
+	impersonation.Use(middleware.Permission(auth.ImpersonatePermission))
+	impersonation.Post("/impersonate", authController.Impersonate)
+
 	// Register resource routes
 	// Example: RegisterResourceRoutes(api, "/users", &UserController{})
 }
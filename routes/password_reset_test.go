@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/goframe/config"
+	"github.com/example/goframe/router"
+	"github.com/example/goframe/view"
+)
+
+// newTestRouter boots the application against an in-memory database, with
+// mail written to mailDir
+func newTestRouter(t *testing.T, mailDir string) *router.Router {
+	t.Helper()
+	view.Initialize(view.Config{ViewsDir: filepath.Join("..", "views")})
+
+	cfg := &config.Config{}
+	cfg.App.URL = "http://example.test"
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.Name = ":memory:"
+	cfg.RateLimit.Requests = 1000
+	cfg.RateLimit.Period = time.Minute
+	cfg.Auth.Secret = "test-secret"
+	cfg.Session.Driver = "memory"
+	cfg.Mail.Driver = "file"
+	cfg.Mail.Path = mailDir
+	cfg.Hashing.BcryptCost = 4
+
+	r, err := InitializeRouter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func send(r *router.Router, method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// mailedLink returns the link to path from the messages written to mailDir
+func mailedLink(t *testing.T, mailDir, path string) *url.URL {
+	t.Helper()
+	files, err := os.ReadDir(mailDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		message, err := os.ReadFile(filepath.Join(mailDir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, link := range regexp.MustCompile(`https?://\S+`).FindAll(message, -1) {
+			if u, err := url.Parse(string(link)); err == nil && u.Path == path {
+				return u
+			}
+		}
+	}
+	t.Fatalf("no link to %s in %d mailed messages", path, len(files))
+	return nil
+}
+
+func TestPasswordResetFollowsMailedLink(t *testing.T) {
+	mailDir := t.TempDir()
+	r := newTestRouter(t, mailDir)
+
+	const email = "reset@example.test"
+	w := send(r, http.MethodPost, "/register", "application/json",
+		`{"name":"Reset","email":"`+email+`","password":"old-password-1"}`)
+	if w.Code >= 300 {
+		t.Fatalf("register: %d %s", w.Code, w.Body)
+	}
+
+	w = send(r, http.MethodPost, "/password/forgot", "application/json", `{"email":"`+email+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("forgot password: %d %s", w.Code, w.Body)
+	}
+
+	link := mailedLink(t, mailDir, "/password/reset")
+	token := link.Query().Get("token")
+
+	w = send(r, http.MethodGet, link.RequestURI(), "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET mailed link: %d %s", w.Code, w.Body)
+	}
+	page := w.Body.String()
+	for _, want := range []string{`action="/password/reset"`, `name="token" value="` + token + `"`, `name="password"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("reset page is missing %s", want)
+		}
+	}
+
+	form := url.Values{"token": {"wrong"}, "email": {email}, "password": {"new-password-1"}}
+	w = send(r, http.MethodPost, "/password/reset", "application/x-www-form-urlencoded", form.Encode())
+	if w.Code != http.StatusSeeOther || !strings.Contains(w.Header().Get("Location"), "error=invalid") {
+		t.Fatalf("wrong token: %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	form.Set("token", token)
+	w = send(r, http.MethodPost, "/password/reset", "application/x-www-form-urlencoded", form.Encode())
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?reset=1" {
+		t.Fatalf("reset: %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = send(r, http.MethodPost, "/login", "application/json", `{"email":"`+email+`","password":"new-password-1"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login with new password: %d %s", w.Code, w.Body)
+	}
+
+	// The token is single use
+	w = send(r, http.MethodPost, "/password/reset", "application/x-www-form-urlencoded", form.Encode())
+	if w.Code != http.StatusSeeOther || !strings.Contains(w.Header().Get("Location"), "error=invalid") {
+		t.Fatalf("reused token: %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
@@ -1,35 +1,45 @@
 package routes
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
-	"fmt"
-	"encoding/json"
 
 	"github.com/example/goframe/auth"
+	"github.com/example/goframe/auth/oauth"
 	"github.com/example/goframe/config"
 	"github.com/example/goframe/db"
+	"github.com/example/goframe/events"
+	"github.com/example/goframe/hashing"
+	"github.com/example/goframe/mail"
 	"github.com/example/goframe/middleware"
+	_ "github.com/example/goframe/migrations"
+	"github.com/example/goframe/models"
+	"github.com/example/goframe/policies"
 	"github.com/example/goframe/router"
+	"github.com/example/goframe/session"
 	"github.com/example/goframe/view"
 )
 
 // InitializeRouter initializes and configures the application router
 func InitializeRouter(cfg *config.Config) (*router.Router, error) {
-	dbConfig := db.DatabaseConfig{
-		Driver:   cfg.Database.Driver,
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Name:     cfg.Database.Name,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-	}
 	// Initialize database connection
-	database, err := db.NewDatabase(&dbConfig)
+	database, err := db.NewDatabase(db.ConfigFrom(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// An in-memory SQLite database starts out empty on every boot
+	if cfg.Database.Driver == "sqlite" && cfg.Database.Name == ":memory:" {
+		if _, err := db.NewMigrator(database).RunMigrations(db.RegisteredMigrations()); err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
 	// Create new router instance
 	r := router.New()
 	if r == nil { // Add this check
@@ -40,28 +50,205 @@ func InitializeRouter(cfg *config.Config) (*router.Router, error) {
 	r.Use(middleware.Logger())
 	r.Use(middleware.RateLimit(cfg.RateLimit.Requests, cfg.RateLimit.Period))
 	r.Use(middleware.Recover())
+	if cfg.Server.RequestTimeout > 0 {
+		r.Use(middleware.Timeout(cfg.Server.RequestTimeout))
+	}
+
+	// Setup sessions
+	sessionSecret := cfg.Session.Secret
+	if sessionSecret == "" {
+		sessionSecret = cfg.Auth.Secret
+	}
+	sessions, err := session.New(session.Config{
+		Driver:      cfg.Session.Driver,
+		Cookie:      cfg.Session.Cookie,
+		IdleTimeout: cfg.Session.IdleTimeout,
+		Lifetime:    cfg.Session.Lifetime,
+		Secure:      cfg.Session.Secure,
+		Secret:      sessionSecret,
+		Path:        cfg.Session.Path,
+		GCInterval:  cfg.Session.GCInterval,
+	}, database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure sessions: %w", err)
+	}
+	sessions.StartGC()
+	r.Use(sessions.Middleware())
+
+	// Setup mail delivery
+	mailer, err := mail.New(mail.Config{
+		Driver: cfg.Mail.Driver,
+		From:   cfg.Mail.From,
+		Path:   cfg.Mail.Path,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure mailer: %w", err)
+	}
+
+	// Setup password hashing
+	hasher, err := hashing.New(hashing.Config{
+		Driver:            cfg.Hashing.Driver,
+		BcryptCost:        cfg.Hashing.BcryptCost,
+		Argon2Memory:      cfg.Hashing.Argon2.Memory,
+		Argon2Iterations:  cfg.Hashing.Argon2.Iterations,
+		Argon2Parallelism: cfg.Hashing.Argon2.Parallelism,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure password hashing: %w", err)
+	}
+	hashing.SetDefault(hasher)
 
 	// Setup authentication system
 	authProvider := auth.NewProvider(database, auth.AuthConfig{
-		Secret:    cfg.Auth.Secret,
-		Duration:  cfg.Auth.Duration,
+		Secret:                cfg.Auth.Secret,
+		Issuer:                cfg.Auth.Issuer,
+		Audience:              cfg.Auth.Audience,
+		Duration:              cfg.Auth.Duration,
+		RefreshDuration:       cfg.Auth.RefreshDuration,
+		PasswordResetExpire:   cfg.Auth.PasswordReset.Expire,
+		PasswordResetThrottle: cfg.Auth.PasswordReset.Throttle,
+		VerificationExpire:    cfg.Auth.Verification.Expire,
+		AppURL:                cfg.App.URL,
+		AppName:               cfg.App.Name,
+		TwoFactorChallenge:    cfg.Auth.TwoFactor.Challenge,
+		MagicLinkExpire:       cfg.Auth.MagicLink.Expire,
+		MagicLinkThrottle:     cfg.Auth.MagicLink.Throttle,
+		ImpersonationDuration: cfg.Auth.Impersonation.Duration,
+		PasswordMinLength:     cfg.Auth.Password.MinLength,
+		PasswordMaxLength:     cfg.Auth.Password.MaxLength,
+
+		LoginMaxAttempts:        cfg.Auth.LoginThrottle.MaxAttempts,
+		LoginMaxAccountAttempts: cfg.Auth.LoginThrottle.MaxAccountAttempts,
+		LoginLockout:            cfg.Auth.LoginThrottle.Lockout,
+		LoginMaxLockout:         cfg.Auth.LoginThrottle.MaxLockout,
+		LoginDecay:              cfg.Auth.LoginThrottle.Decay,
 	})
+	if cfg.Auth.Signing.Active != "" {
+		var keys []auth.KeyConfig
+		for _, key := range cfg.Auth.Signing.Keys {
+			keys = append(keys, auth.KeyConfig{
+				ID:             key.ID,
+				Algorithm:      key.Algorithm,
+				PrivateKeyFile: key.PrivateKey,
+				PublicKeyFile:  key.PublicKey,
+				RetiredAt:      key.RetiredAt,
+			})
+		}
+		keySet, err := auth.LoadKeySet(keys, cfg.Auth.Signing.Active, cfg.Auth.Signing.RotationWindow)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signing keys: %w", err)
+		}
+		authProvider.SetKeySet(keySet)
+	}
 	userRepo := auth.NewUserRepository(database)
-	authController := auth.NewController(authProvider, userRepo)
+	authController := auth.NewController(authProvider, userRepo, mailer)
+
+	// Record auth events in the audit log
+	auditLog := auth.NewAuditLog(database)
+	auditLog.Listen(events.Default())
+	authController.SetAuditLog(auditLog)
+
+	// Setup social login
+	socialAccounts := oauth.NewSocialAccountRepository(database)
+	oauthController := oauth.NewController(authProvider, authController, oauth.NewResolver(socialAccounts, userRepo))
+	for name := range cfg.Auth.OAuth.Providers {
+		provider, err := oauth.New(oauthProviderConfig(cfg, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure login provider: %w", err)
+		}
+		oauthController.Register(provider)
+	}
+
+	// Register authorization policies and expose them to templates
+	policies.Register(auth.DefaultGate())
+	view.RegisterFunction("can", auth.Allows)
 
 	// Register application routes
-	RegisterWebRoutes(r, cfg, authProvider, authController)
-	RegisterAPIRoutes(r, cfg, authProvider)
+	RegisterWebRoutes(r, cfg, authProvider, authController, oauthController, models.NewPostRepository(database))
+	RegisterAPIRoutes(r, cfg, authProvider, authController)
 
 	// Health check endpoint
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	r.Get("/health", healthHandler(database))
 
 	return r, nil
 }
 
+// healthHandler reports whether the database is reachable, along with the
+// connection pool statistics. It responds 503 when the ping fails.
+func healthHandler(database *db.Database) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		err := database.Ping(ctx)
+		latency := time.Since(start)
+		stats := database.Stats()
+
+		status, code := "ok", http.StatusOK
+		dbStatus := map[string]interface{}{
+			"status":               "up",
+			"driver":               database.Dialect().Name(),
+			"latency_ms":           float64(latency.Microseconds()) / 1000,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"max_open_connections": stats.MaxOpenConnections,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		}
+		if err != nil {
+			// The error can name hosts, so it is logged rather than returned
+			log.Printf("Health check: database ping failed: %v", err)
+			status, code = "unavailable", http.StatusServiceUnavailable
+			dbStatus["status"] = "down"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   status,
+			"database": dbStatus,
+		})
+	}
+}
+
+// oauthProviderConfig builds a login provider's config from its preset, if
+// there is one, and the values set in config.yaml
+func oauthProviderConfig(cfg *config.Config, name string) oauth.Config {
+	settings := cfg.Auth.OAuth.Providers[name]
+
+	providerConfig := oauth.Config{Name: name}
+	if name == "microsoft" {
+		providerConfig = oauth.Microsoft(settings.Tenant)
+	} else if preset, ok := oauth.Presets()[name]; ok {
+		providerConfig = preset
+	}
+
+	providerConfig.ClientID = settings.ClientID
+	providerConfig.ClientSecret = settings.ClientSecret
+	providerConfig.RedirectURL = settings.RedirectURL
+	if providerConfig.RedirectURL == "" {
+		providerConfig.RedirectURL = strings.TrimSuffix(cfg.App.URL, "/") + "/auth/" + name + "/callback"
+	}
+	if len(settings.Scopes) > 0 {
+		providerConfig.Scopes = settings.Scopes
+	}
+	if settings.Issuer != "" {
+		providerConfig.Issuer = settings.Issuer
+	}
+	if settings.AuthURL != "" {
+		providerConfig.AuthURL = settings.AuthURL
+	}
+	if settings.TokenURL != "" {
+		providerConfig.TokenURL = settings.TokenURL
+	}
+	if settings.UserInfoURL != "" {
+		providerConfig.UserInfoURL = settings.UserInfoURL
+	}
+	return providerConfig
+}
+
 // registerWebRoutes registers web routes
 func registerWebRoutes(r *router.Router, cfg *config.Config, authProvider *auth.Provider, authController *auth.Controller) {
 	// Example web route with view rendering
@@ -5,16 +5,20 @@ import (
 	"time"
 
 	"github.com/example/goframe/auth"
+	"github.com/example/goframe/auth/oauth"
 	"github.com/example/goframe/config"
 	"github.com/example/goframe/controllers"
+	"github.com/example/goframe/middleware"
+	"github.com/example/goframe/models"
 	"github.com/example/goframe/router"
 	"github.com/example/goframe/view"
 )
 
 // RegisterWebRoutes registers web routes
-func RegisterWebRoutes(r *router.Router, cfg *config.Config, authProvider *auth.Provider, authController *auth.Controller) {
+func RegisterWebRoutes(r *router.Router, cfg *config.Config, authProvider *auth.Provider, authController *auth.Controller, oauthController *oauth.Controller, posts *models.PostRepository) {
 	// Create web controller
 	webController := controllers.NewWebController()
+	webController.SetLoginProviders(oauthController.Providers())
 	
 	// Register routes
 	r.Get("/", webController.Home)
@@ -25,8 +29,35 @@ func RegisterWebRoutes(r *router.Router, cfg *config.Config, authProvider *auth.
 	r.Get("/contact", webController.Contact)
 	
 	// Auth routes
+	r.Get("/login", webController.Login)
 	r.Post("/login", authController.Login)
 	r.Post("/register", authController.Register)
+	r.Post("/token/refresh", authController.Refresh)
+	r.Post("/password/forgot", authController.ForgotPassword)
+	r.Get("/password/reset", webController.ResetPassword)
+	r.Post("/password/reset", authController.ResetPassword)
+	r.Post("/login/magic", authController.RequestMagicLink)
+	r.Get("/login/magic/verify", authController.MagicLinkLogin)
+	r.Get("/email/verify", authController.VerifyEmail)
+	r.Get("/.well-known/jwks.json", authProvider.JWKS)
+	r.Get("/two-factor-challenge", webController.TwoFactorChallenge)
+	r.Post("/two-factor-challenge", authController.TwoFactorChallenge)
+
+	// Social login, one pair of routes per configured provider
+	for _, name := range oauthController.Providers() {
+		r.Get("/auth/"+name+"/redirect", oauthController.Redirect(name))
+		r.Get("/auth/"+name+"/callback", oauthController.Callback(name))
+	}
+
+	authenticated := r.Group("")
+	authenticated.Use(authProvider.Guard("api", "web"))
+	authenticated.Post("/logout", authController.Logout)
+	authenticated.Post("/email/verification-notification", authController.ResendVerification)
+	authenticated.Post("/user/two-factor", authController.EnableTwoFactor)
+	authenticated.Delete("/user/two-factor", authController.DisableTwoFactor)
+	authenticated.Get("/user/two-factor/qr-code", authController.TwoFactorQRCode)
+	authenticated.Post("/user/two-factor/confirm", authController.ConfirmTwoFactor)
+	authenticated.Post("/user/two-factor/recovery-codes", authController.RegenerateRecoveryCodes)
 	
 	// Static files
 	r.Static("/assets", "./public/assets")
@@ -35,7 +66,8 @@ func RegisterWebRoutes(r *router.Router, cfg *config.Config, authProvider *auth.
 	
 	// Protected routes
 	protected := r.Group("/dashboard")
-	protected.Use(authProvider.Middleware())
+	protected.Use(authProvider.Guard("web"))
+	protected.Use(middleware.Verified())
 	
 	protected.Get("", func(w http.ResponseWriter, r *http.Request) {
 		user := auth.GetUser(r.Context())
@@ -43,11 +75,26 @@ func RegisterWebRoutes(r *router.Router, cfg *config.Config, authProvider *auth.
 			http.Error(w, "Not authenticated", http.StatusUnauthorized)
 			return
 		}
-		
+
+		// The template decides per post, through the can function, which
+		// edit and delete controls to show
+		var recent []*models.Post
+		err := posts.Query().
+			WithContext(r.Context()).
+			Where("user_id = ?", user.ID).
+			OrderBy("created_at DESC").
+			Limit(5).
+			Find(&recent)
+		if err != nil {
+			http.Error(w, "Failed to load posts", http.StatusInternalServerError)
+			return
+		}
+
 		data := map[string]interface{}{
 			"title":       "Dashboard",
 			"currentYear": time.Now().Year(),
 			"user":        user,
+			"posts":       recent,
 		}
 		
 		view.Render(w, "pages/dashboard", data)
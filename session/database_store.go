@@ -0,0 +1,59 @@
+package session
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/example/goframe/db"
+)
+
+// DatabaseStore keeps sessions in the sessions table
+type DatabaseStore struct {
+	db    *db.Database
+	table string
+}
+
+// NewDatabaseStore creates a store backed by the sessions table
+func NewDatabaseStore(database *db.Database) *DatabaseStore {
+	return &DatabaseStore{db: database, table: "sessions"}
+}
+
+// Read returns the data saved under id
+func (s *DatabaseStore) Read(id string) ([]byte, error) {
+	var payload string
+	err := s.db.QueryRow(
+		"SELECT payload FROM "+s.table+" WHERE id = ? AND expires_at > ?",
+		storageKey(id), time.Now(),
+	).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(payload), nil
+}
+
+// Write saves data under id
+func (s *DatabaseStore) Write(id string, data []byte, expiresAt time.Time) error {
+	return db.NewQueryBuilder(s.db, s.table).Upsert(map[string]interface{}{
+		"id":         storageKey(id),
+		"payload":    string(data),
+		"expires_at": expiresAt,
+	}, []string{"id"})
+}
+
+// Destroy removes the data saved under id
+func (s *DatabaseStore) Destroy(id string) error {
+	return db.NewQueryBuilder(s.db, s.table).
+		Where("id", "=", storageKey(id)).
+		Delete()
+}
+
+// GC removes expired sessions
+func (s *DatabaseStore) GC(now time.Time) error {
+	return db.NewQueryBuilder(s.db, s.table).
+		Where("expires_at", "<=", now).
+		Delete()
+}
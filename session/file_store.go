@@ -0,0 +1,119 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// FileStore keeps each session in its own file. The first line of a file holds
+// the expiry as a Unix timestamp and the rest holds the session data.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store that writes sessions into dir
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		dir = filepath.Join("storage", "sessions")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Read returns the data saved under id
+func (s *FileStore) Read(id string) ([]byte, error) {
+	content, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, data, ok := parseSessionFile(content)
+	if !ok || time.Now().After(expiresAt) {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// Write saves data under id. The file is written under a temporary name and
+// renamed so concurrent readers never see a partial session.
+func (s *FileStore) Write(id string, data []byte, expiresAt time.Time) error {
+	var buf bytes.Buffer
+	buf.WriteString(strconv.FormatInt(expiresAt.Unix(), 10))
+	buf.WriteByte('\n')
+	buf.Write(data)
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(id))
+}
+
+// Destroy removes the data saved under id
+func (s *FileStore) Destroy(id string) error {
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// GC removes expired session files
+func (s *FileStore) GC(now time.Time) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if expiresAt, _, ok := parseSessionFile(content); !ok || now.After(expiresAt) {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, storageKey(id))
+}
+
+// parseSessionFile splits a session file into its expiry and data
+func parseSessionFile(content []byte) (time.Time, []byte, bool) {
+	header, data, ok := bytes.Cut(content, []byte("\n"))
+	if !ok {
+		return time.Time{}, nil, false
+	}
+	unix, err := strconv.ParseInt(string(header), 10, 64)
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	return time.Unix(unix, 0), data, true
+}
@@ -0,0 +1,269 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/example/goframe/db"
+)
+
+// Config represents the session configuration
+type Config struct {
+	Driver      string        // "cookie", "memory", "file" or "database"
+	Cookie      string        // Name of the session cookie
+	IdleTimeout time.Duration // Sessions expire after this long without a request
+	Lifetime    time.Duration // Sessions expire this long after they start, however active
+	Secure      bool          // Only send the cookie over HTTPS
+	Secret      string        // Key material for the cookie driver
+	Path        string        // Directory for the file driver
+	GCInterval  time.Duration // How often expired sessions are removed
+}
+
+// Manager loads and saves sessions around each request
+type Manager struct {
+	store  Store
+	config Config
+}
+
+// New creates a session manager for the configured driver. The database is
+// only used by the database driver.
+func New(cfg Config, database *db.Database) (*Manager, error) {
+	var store Store
+	var err error
+
+	switch cfg.Driver {
+	case "", "cookie":
+		store, err = NewCookieStore(cfg.Secret)
+	case "memory":
+		store = NewMemoryStore()
+	case "file":
+		store, err = NewFileStore(cfg.Path)
+	case "database":
+		store = NewDatabaseStore(database)
+	default:
+		return nil, fmt.Errorf("unsupported session driver: %s", cfg.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewManager(store, cfg), nil
+}
+
+// NewManager creates a session manager backed by store
+func NewManager(store Store, cfg Config) *Manager {
+	if cfg.Cookie == "" {
+		cfg.Cookie = "goframe_session"
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 2 * time.Hour
+	}
+	if cfg.Lifetime == 0 {
+		cfg.Lifetime = 12 * time.Hour
+	}
+	if cfg.GCInterval == 0 {
+		cfg.GCInterval = 30 * time.Minute
+	}
+
+	return &Manager{store: store, config: cfg}
+}
+
+// Middleware loads the request's session into its context and saves it
+// before the response is written. Sessions that hold no data are never
+// stored and set no cookie, so API clients are unaffected.
+func (m *Manager) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := m.Load(r)
+			sw := &sessionWriter{ResponseWriter: w, save: func(w http.ResponseWriter) {
+				if err := m.Save(w, s); err != nil {
+					log.Printf("Failed to save session: %v", err)
+				}
+			}}
+
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), sessionKey{}, s)))
+
+			sw.commit()
+		})
+	}
+}
+
+// Load restores the session named by the request's cookie, or starts a new
+// one if there is none or it has expired
+func (m *Manager) Load(r *http.Request) *Session {
+	now := time.Now()
+	client, isClient := m.store.(ClientStore)
+
+	cookie, err := r.Cookie(m.config.Cookie)
+	if err == nil && cookie.Value != "" {
+		var data []byte
+		if isClient {
+			data, err = client.Decode(cookie.Value)
+		} else {
+			data, err = m.store.Read(cookie.Value)
+		}
+
+		if err == nil && data != nil {
+			s, err := decodeSession(data)
+			if err == nil && !m.expired(s, now) && (isClient || s.id == cookie.Value) {
+				s.loaded = true
+				s.hadCookie = true
+				s.lastActivity = now
+				return s
+			}
+		}
+
+		if !isClient {
+			m.store.Destroy(cookie.Value)
+		}
+	}
+
+	// A fresh ID is only stored if the session is given data, so an
+	// unusable random ID here costs nothing
+	id, _ := newID()
+	s := newSession(id, now)
+	s.hadCookie = cookie != nil
+	return s
+}
+
+// Save persists the session and writes its cookie. Empty sessions are
+// destroyed and their cookie cleared.
+func (m *Manager) Save(w http.ResponseWriter, s *Session) error {
+	client, isClient := m.store.(ClientStore)
+
+	s.mu.Lock()
+	stale := s.staleIDs
+	s.staleIDs = nil
+	s.mu.Unlock()
+
+	if !isClient {
+		for _, id := range stale {
+			if err := m.store.Destroy(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := s.encode()
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		if s.loaded && !isClient {
+			if err := m.store.Destroy(s.ID()); err != nil {
+				return err
+			}
+		}
+		if s.hadCookie {
+			m.setCookie(w, "", time.Unix(0, 0))
+		}
+		return nil
+	}
+
+	expiresAt := m.expiresAt(s)
+	value := s.ID()
+	if isClient {
+		value, err = client.Encode(data)
+		if err != nil {
+			return err
+		}
+	} else if err := m.store.Write(value, data, expiresAt); err != nil {
+		return err
+	}
+
+	m.setCookie(w, value, expiresAt)
+	return nil
+}
+
+// GC removes expired sessions from the store
+func (m *Manager) GC() error {
+	return m.store.GC(time.Now())
+}
+
+// StartGC removes expired sessions every GCInterval until stop is called
+func (m *Manager) StartGC() (stop func()) {
+	ticker := time.NewTicker(m.config.GCInterval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.GC(); err != nil {
+					log.Printf("Session garbage collection failed: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// expired reports whether the session has passed its idle or absolute timeout
+func (m *Manager) expired(s *Session, now time.Time) bool {
+	return now.Sub(s.lastActivity) > m.config.IdleTimeout ||
+		now.Sub(s.createdAt) > m.config.Lifetime
+}
+
+// expiresAt returns when the session will expire if no further requests arrive
+func (m *Manager) expiresAt(s *Session) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idle := s.lastActivity.Add(m.config.IdleTimeout)
+	absolute := s.createdAt.Add(m.config.Lifetime)
+	if absolute.Before(idle) {
+		return absolute
+	}
+	return idle
+}
+
+// setCookie writes the session cookie
+func (m *Manager) setCookie(w http.ResponseWriter, value string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.config.Cookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   m.config.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sessionWriter saves the session just before the response headers are
+// sent, since the cookie cannot be set afterwards
+type sessionWriter struct {
+	http.ResponseWriter
+	save      func(w http.ResponseWriter)
+	committed bool
+}
+
+func (w *sessionWriter) commit() {
+	if !w.committed {
+		w.committed = true
+		w.save(w.ResponseWriter)
+	}
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	w.commit()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
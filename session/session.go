@@ -0,0 +1,250 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type sessionKey struct{}
+
+// Session holds the data for one visitor between requests. Values are stored
+// as JSON, so numbers come back as float64 from Get; use the typed getters.
+type Session struct {
+	mu sync.Mutex
+
+	id           string
+	values       map[string]interface{}
+	newFlash     []string // Keys flashed during this request
+	oldFlash     []string // Keys flashed during the previous request
+	createdAt    time.Time
+	lastActivity time.Time
+
+	loaded    bool     // The session was restored from an earlier request
+	hadCookie bool     // The request carried a session cookie
+	staleIDs  []string // IDs to destroy on save after Regenerate or Invalidate
+}
+
+// record is the serialised form of a session
+type record struct {
+	ID           string                 `json:"id"`
+	Values       map[string]interface{} `json:"values"`
+	Flash        []string               `json:"flash,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+	LastActivity time.Time              `json:"last_activity"`
+}
+
+// newSession creates an empty session with the given ID
+func newSession(id string, now time.Time) *Session {
+	return &Session{
+		id:           id,
+		values:       make(map[string]interface{}),
+		createdAt:    now,
+		lastActivity: now,
+	}
+}
+
+// decodeSession restores a session saved by encode. Keys flashed by the
+// previous request become readable for this one and are removed on save.
+func decodeSession(data []byte) (*Session, error) {
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	if rec.Values == nil {
+		rec.Values = make(map[string]interface{})
+	}
+
+	return &Session{
+		id:           rec.ID,
+		values:       rec.Values,
+		oldFlash:     rec.Flash,
+		createdAt:    rec.CreatedAt,
+		lastActivity: rec.LastActivity,
+	}, nil
+}
+
+// encode ages flash data and serialises the session. It returns nil when
+// the session holds nothing worth keeping.
+func (s *Session) encode() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.oldFlash {
+		if !contains(s.newFlash, key) {
+			delete(s.values, key)
+		}
+	}
+	s.oldFlash = nil
+
+	if len(s.values) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(record{
+		ID:           s.id,
+		Values:       s.values,
+		Flash:        s.newFlash,
+		CreatedAt:    s.createdAt,
+		LastActivity: s.lastActivity,
+	})
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// Get returns the value stored under key, or nil
+func (s *Session) Get(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// GetString returns the string stored under key, or ""
+func (s *Session) GetString(key string) string {
+	value, _ := s.Get(key).(string)
+	return value
+}
+
+// GetInt returns the integer stored under key, or 0
+func (s *Session) GetInt(key string) int {
+	switch value := s.Get(key).(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case uint:
+		return int(value)
+	case float64:
+		return int(value)
+	}
+	return 0
+}
+
+// GetBool returns the boolean stored under key, or false
+func (s *Session) GetBool(key string) bool {
+	value, _ := s.Get(key).(bool)
+	return value
+}
+
+// GetTime returns the time stored under key, or the zero time
+func (s *Session) GetTime(key string) time.Time {
+	switch value := s.Get(key).(type) {
+	case time.Time:
+		return value
+	case string:
+		t, _ := time.Parse(time.RFC3339Nano, value)
+		return t
+	}
+	return time.Time{}
+}
+
+// Has reports whether a value is stored under key
+func (s *Session) Has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.values[key]
+	return ok
+}
+
+// Put stores a value under key. Values must be JSON-serialisable.
+func (s *Session) Put(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+// Pull returns the value stored under key and removes it
+func (s *Session) Pull(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value := s.values[key]
+	delete(s.values, key)
+	return value
+}
+
+// Forget removes the values stored under keys
+func (s *Session) Forget(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.values, key)
+	}
+}
+
+// Flash stores a value that is only available during the next request
+func (s *Session) Flash(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	if !contains(s.newFlash, key) {
+		s.newFlash = append(s.newFlash, key)
+	}
+}
+
+// Reflash keeps the previous request's flash data for one more request
+func (s *Session) Reflash() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.oldFlash {
+		if !contains(s.newFlash, key) {
+			s.newFlash = append(s.newFlash, key)
+		}
+	}
+}
+
+// Regenerate gives the session a new ID while keeping its data. Call it
+// whenever the user's privilege level changes, such as on login.
+func (s *Session) Regenerate() error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staleIDs = append(s.staleIDs, s.id)
+	s.id = id
+	return nil
+}
+
+// Invalidate discards all session data and issues a new ID
+func (s *Session) Invalidate() error {
+	if err := s.Regenerate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = make(map[string]interface{})
+	s.newFlash = nil
+	s.oldFlash = nil
+	s.createdAt = time.Now()
+	return nil
+}
+
+// FromContext returns the session stored in ctx by the middleware, or nil
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// FromRequest returns the request's session, or nil
+func FromRequest(r *http.Request) *Session {
+	return FromContext(r.Context())
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
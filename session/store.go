@@ -0,0 +1,108 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Store persists session data on the server, keyed by session ID
+type Store interface {
+	// Read returns the data saved under id, or nil if there is none or it has expired
+	Read(id string) ([]byte, error)
+
+	// Write saves data under id until expiresAt
+	Write(id string, data []byte, expiresAt time.Time) error
+
+	// Destroy removes the data saved under id
+	Destroy(id string) error
+
+	// GC removes every session that expired before now
+	GC(now time.Time) error
+}
+
+// ClientStore is implemented by stores that keep the whole session in the
+// cookie. The manager puts the encoded session in the cookie instead of
+// calling Read and Write.
+type ClientStore interface {
+	Store
+	Encode(data []byte) (string, error)
+	Decode(value string) ([]byte, error)
+}
+
+// newID generates a random session ID
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// storageKey derives the key a session is stored under, so that IDs never
+// reach disk or the database and cannot be used to build file paths
+func storageKey(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// memoryEntry is a session held by MemoryStore
+type memoryEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on restart
+// and are not shared between instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+// Read returns the data saved under id
+func (s *MemoryStore) Read(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.sessions[id]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, nil
+	}
+	return entry.data, nil
+}
+
+// Write saves data under id
+func (s *MemoryStore) Write(id string, data []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = memoryEntry{data: data, expiresAt: expiresAt}
+	return nil
+}
+
+// Destroy removes the data saved under id
+func (s *MemoryStore) Destroy(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// GC removes expired sessions
+func (s *MemoryStore) GC(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, entry := range s.sessions {
+		if now.After(entry.expiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}